@@ -0,0 +1,402 @@
+// Package checklist keeps a todo database in sync with a GitHub style
+// Markdown checklist, such as a TASKS.md file in a repository.
+//
+// The items are written one per line, grouped under a heading per tag
+// (the first tag of an item) with subtasks indented below their parent:
+//
+//	## backend
+//
+//	- [ ] Fix login <!-- todo:3 done:0 title:5d41402a -->
+//	  - [x] Write a test <!-- todo:4 done:1 title:7d793037 -->
+//
+// The hidden comment holds the id of the item and its state when the
+// file was written, which tells edits made to the file apart from changes
+// made to the database since: a line whose box or title differs from the
+// state in its comment was edited and the edit is applied to the item,
+// otherwise the database wins.  Lines without a comment are new items,
+// tagged with the heading they are under.  Removing a line does not
+// delete the item, it is written back the next time.
+//
+// Everything in the file other than the items is replaced.  A file that
+// has no linked lines yet was not written by Sync, so before it is
+// rewritten the first time it is copied to <file>.orig.
+package checklist
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// Untagged is the heading of the items without tags
+const Untagged = "Untagged"
+
+const header = `# Tasks
+
+<!-- Written by 'todo sync-md'.  Check boxes, rename items or add new
+     "- [ ] ..." lines under a heading and run it again.  The comments
+     at the end of the lines link them to the items, keep them. -->
+`
+
+// Line is a checklist item read from a Markdown file
+type Line struct {
+	Number  int    // line number in the file, counting from 1
+	Indent  int    // number of leading spaces
+	Section string // the ## heading the line is under
+	Done    bool
+	Title   string
+	Id      int // -1 for lines that were added by hand
+
+	// Parent is the index of the line this one is indented under, -1
+	// for top level lines
+	Parent int
+
+	// The state of the item when the file was written, unknown for
+	// lines without (or with a hand written) comment
+	synced    bool
+	syncDone  bool
+	syncTitle string
+}
+
+// Result summarizes the changes Sync made to the database
+type Result struct {
+	Added     []int
+	Renamed   []int
+	Completed []int
+	Reopened  []int
+
+	// Missing are the ids of lines whose item is no longer in the
+	// database, they are dropped from the file
+	Missing []int
+
+	// Backup is the copy of a file that had no linked lines before it
+	// was rewritten, empty if there was no need for one
+	Backup string
+}
+
+var (
+	itemPattern    = regexp.MustCompile(`^(\s*)[-*+] \[([ xX])\] (.*?)\s*(?:<!--\s*todo:(\d+)(?:\s+done:([01]))?(?:\s+title:([0-9a-f]+))?\s*-->)?\s*$`)
+	sectionPattern = regexp.MustCompile(`^##\s+(.*?)\s*#*\s*$`)
+)
+
+// Parse reads the checklist items of a Markdown file, other lines are
+// ignored
+func Parse(r io.Reader) ([]Line, error) {
+	var lines []Line
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.ReplaceAll(scanner.Text(), "\t", "    ")
+		if m := sectionPattern.FindStringSubmatch(text); m != nil {
+			section = m[1]
+			continue
+		}
+		m := itemPattern.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+
+		line := Line{
+			Number:  number,
+			Indent:  len(m[1]),
+			Section: section,
+			Done:    m[2] != " ",
+			Title:   m[3],
+			Id:      -1,
+			Parent:  -1,
+		}
+		if m[4] != "" {
+			line.Id, _ = strconv.Atoi(m[4])
+		}
+		if m[5] != "" && m[6] != "" {
+			line.synced = true
+			line.syncDone = m[5] == "1"
+			line.syncTitle = m[6]
+		}
+		for i := len(lines) - 1; i >= 0; i-- {
+			if lines[i].Section != section {
+				break
+			}
+			if lines[i].Indent < line.Indent {
+				line.Parent = i
+				break
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// Render writes the items as a Markdown checklist, grouped by their first
+// tag and ordered by rank
+func Render(items []db.ToDoItem) []byte {
+	sorted := append([]db.ToDoItem(nil), items...)
+	db.SortByRank(sorted)
+
+	byId := make(map[int]db.ToDoItem, len(sorted))
+	for _, item := range sorted {
+		byId[item.Id] = item
+	}
+	children := make(map[int][]db.ToDoItem)
+	sections := make(map[string][]db.ToDoItem)
+	for _, item := range sorted {
+		if belowParent(item, byId) {
+			children[item.ParentId] = append(children[item.ParentId], item)
+			continue
+		}
+		sections[sectionOf(item)] = append(sections[sectionOf(item)], item)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	var write func(item db.ToDoItem, indent int)
+	write = func(item db.ToDoItem, indent int) {
+		buf.WriteString(formatLine(item, indent))
+		for _, child := range children[item.Id] {
+			write(child, indent+2)
+		}
+	}
+	for _, name := range sectionNames(sections) {
+		fmt.Fprintf(&buf, "\n## %s\n\n", name)
+		for _, item := range sections[name] {
+			write(item, 0)
+		}
+	}
+	return buf.Bytes()
+}
+
+// belowParent reports whether an item is written below its parent, which
+// is the case when its parents lead to a top level item.  Items whose
+// parents form a cycle are written at the top level.
+func belowParent(item db.ToDoItem, byId map[int]db.ToDoItem) bool {
+	seen := map[int]bool{item.Id: true}
+	for parent, ok := byId[item.ParentId]; ok; parent, ok = byId[parent.ParentId] {
+		if seen[parent.Id] {
+			return false
+		}
+		seen[parent.Id] = true
+	}
+	_, ok := byId[item.ParentId]
+	return ok
+}
+
+// Sync applies the edits made to the checklist file to the database and
+// writes the database back into the file.  A missing file is created.
+func Sync(todo *db.ToDo, fileName string) (Result, error) {
+	var result Result
+
+	data, err := os.ReadFile(fileName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return result, err
+	}
+	lines, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return result, err
+	}
+
+	if needsBackup(data, lines) {
+		result.Backup = fileName + ".orig"
+		if err := backup(result.Backup, data); err != nil {
+			return result, err
+		}
+	}
+
+	//The ids of the items of the lines, for the parents of new subtasks
+	lineIds := make([]int, len(lines))
+	seen := make(map[int]bool)
+	for i, line := range lines {
+		if line.Id < 0 {
+			id, err := addLine(todo, line, lineIds)
+			if err != nil {
+				return result, err
+			}
+			if id >= 0 {
+				lineIds[i] = id
+				result.Added = append(result.Added, id)
+			}
+			continue
+		}
+
+		//A copied line refers to the same item, only the first one counts
+		if seen[line.Id] {
+			continue
+		}
+		seen[line.Id] = true
+		lineIds[i] = line.Id
+		if err := applyLine(todo, line, &result); err != nil {
+			return result, err
+		}
+	}
+
+	items, err := todo.GetAllItems()
+	if err != nil {
+		return result, err
+	}
+	return result, writeFile(fileName, Render(items))
+}
+
+// needsBackup reports whether a file has content but no linked lines and
+// was not written by Sync
+func needsBackup(data []byte, lines []Line) bool {
+	if len(bytes.TrimSpace(data)) == 0 || bytes.HasPrefix(data, []byte(header)) {
+		return false
+	}
+	for _, line := range lines {
+		if line.Id >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// backup copies a file before Sync rewrites it the first time.  An
+// existing backup is never overwritten, it may be the only copy of what
+// was in the file.
+func backup(fileName string, data []byte) error {
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s exists, move it away to sync a file that has no todo items yet", fileName)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// addLine adds the item of a new line and returns its id, -1 for lines
+// without a title
+func addLine(todo *db.ToDo, line Line, lineIds []int) (int, error) {
+	title := strings.TrimSpace(line.Title)
+	if title == "" {
+		return -1, nil
+	}
+	id, err := todo.NextId()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	item := db.ToDoItem{Id: id, Title: title, CreatedAt: &now}
+	if line.Section != "" && line.Section != Untagged {
+		item.Tags = []string{line.Section}
+	}
+	if line.Parent >= 0 {
+		item.ParentId = lineIds[line.Parent]
+	}
+	if err := todo.AddItem(item); err != nil {
+		return 0, err
+	}
+	if line.Done {
+		if err := todo.ChangeItemDoneStatus(id, true); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// applyLine applies the edits made to the line of an existing item
+func applyLine(todo *db.ToDo, line Line, result *Result) error {
+	item, err := todo.GetItem(line.Id)
+	if err != nil {
+		result.Missing = append(result.Missing, line.Id)
+		return nil
+	}
+
+	title := strings.TrimSpace(line.Title)
+	titleEdited := !line.synced || titleHash(title) != line.syncTitle
+	if titleEdited && title != "" && title != item.Title {
+		item.Title = title
+		if err := todo.UpdateItem(item); err != nil {
+			return err
+		}
+		result.Renamed = append(result.Renamed, item.Id)
+	}
+
+	doneEdited := !line.synced || line.Done != line.syncDone
+	if doneEdited && line.Done != item.IsDone {
+		if err := todo.ChangeItemDoneStatus(item.Id, line.Done); err != nil {
+			return err
+		}
+		if line.Done {
+			result.Completed = append(result.Completed, item.Id)
+		} else {
+			result.Reopened = append(result.Reopened, item.Id)
+		}
+	}
+	return nil
+}
+
+func formatLine(item db.ToDoItem, indent int) string {
+	check, done := " ", 0
+	if item.IsDone {
+		check, done = "x", 1
+	}
+	title := strings.Join(strings.Fields(item.Title), " ")
+	return fmt.Sprintf("%s- [%s] %s <!-- todo:%d done:%d title:%s -->\n", strings.Repeat(" ", indent), check, title, item.Id, done, titleHash(title))
+}
+
+// titleHash identifies the title an item had when the file was written
+func titleHash(title string) string {
+	sum := sha256.Sum256([]byte(title))
+	return hex.EncodeToString(sum[:4])
+}
+
+func sectionOf(item db.ToDoItem) string {
+	if len(item.Tags) == 0 {
+		return Untagged
+	}
+	return item.Tags[0]
+}
+
+// sectionNames returns the tags in alphabetical order, the untagged items
+// last
+func sectionNames(sections map[string][]db.ToDoItem) []string {
+	var names []string
+	for name := range sections {
+		if name != Untagged {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := sections[Untagged]; ok {
+		names = append(names, Untagged)
+	}
+	return names
+}
+
+// writeFile replaces the file through a temporary file, so an editor
+// never sees it half written
+func writeFile(fileName string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
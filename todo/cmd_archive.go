@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/duration"
+)
+
+var (
+	archiveOlderThanFlag string
+	archiveCmd           = &cobra.Command{
+		Use:   "archive",
+		Short: "Move completed items to the archive",
+		Long: `Move done items out of the database into the archive file next to it.
+Archived items no longer show up in the list, but can still be listed and
+searched with 'todo archive list' and 'todo archive search'.`,
+		Args: cobra.NoArgs,
+		RunE: runArchive,
+	}
+	archiveListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List all archived items",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printArchive("")
+		},
+	}
+	archiveSearchCmd = &cobra.Command{
+		Use:   "search TEXT",
+		Short: "Search the titles of the archived items",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printArchive(args[0])
+		},
+	}
+)
+
+func init() {
+	archiveCmd.Flags().StringVar(&archiveOlderThanFlag, "older-than", "", "Only archive items completed at least this long ago, for example 30d")
+	archiveCmd.AddCommand(archiveListCmd, archiveSearchCmd)
+	rootCmd.AddCommand(archiveCmd)
+}
+
+func runArchive(cmd *cobra.Command, args []string) error {
+	var olderThan time.Duration
+	if archiveOlderThanFlag != "" {
+		var err error
+		if olderThan, err = duration.Parse(archiveOlderThanFlag); err != nil {
+			return err
+		}
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	archived, err := todo.Archive(olderThan)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Archived", len(archived), "items")
+	fmt.Println("Ok")
+	return nil
+}
+
+// printArchive prints the archived items whose title contains query, or
+// all of them if query is empty
+func printArchive(query string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+
+	var entries []db.ArchiveEntry
+	if query == "" {
+		entries, err = todo.ArchivedItems()
+	} else {
+		entries, err = todo.SearchArchive(query)
+	}
+	if err != nil {
+		return err
+	}
+
+	if cfg.Get("output.format") == "json" {
+		printJson(os.Stdout, entries)
+		return nil
+	}
+	for _, entry := range entries {
+		fmt.Println(formatItemLine(entry.Item), " archived", formatDate(entry.ArchivedAt))
+	}
+	fmt.Println("THERE ARE", len(entries), "ARCHIVED ITEMS")
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/db"
+)
+
+var (
+	attachLinkFlag bool
+	openPrintFlag  bool
+	attachCmd      = &cobra.Command{
+		Use:   "attach ID PATH|URL",
+		Short: "Attach a url, a file or a link to a local path to an item",
+		Long: fmt.Sprintf(`Attach a url, a file or a link to a local path to an item.  Files are
+copied into the database (up to %d MB, next to the database file in
+<db>.attachments), use --link to only link them.  Directories are always
+linked.  A copied file is removed once no item (also in the trash or the
+archive) has it attached any more.  See 'todo open'.`, db.MaxAttachmentSize>>20),
+		Args: cobra.ExactArgs(2),
+		RunE: runAttach,
+	}
+	detachCmd = &cobra.Command{
+		Use:   "detach ID N",
+		Short: "Remove the N-th attachment from an item",
+		Args:  cobra.ExactArgs(2),
+		RunE:  runDetach,
+	}
+	openCmd = &cobra.Command{
+		Use:   "open ID [N]",
+		Short: "List the attachments of an item, or open one of them",
+		Long: `Without N, list the attachments of an item.  With N, open the N-th
+attachment with open.command (xdg-open by default), or only print its url
+or path with --print.  Attached files are opened from a copy in the
+temporary directory.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: runOpen,
+	}
+)
+
+func init() {
+	attachCmd.Flags().BoolVar(&attachLinkFlag, "link", false, "Link a local file instead of copying it")
+	openCmd.Flags().BoolVar(&openPrintFlag, "print", false, "Print the url or path instead of opening it")
+	rootCmd.AddCommand(attachCmd, detachCmd, openCmd)
+}
+
+func runAttach(cmd *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", args[0])
+	}
+	target := args[1]
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	var a db.Attachment
+	if strings.Contains(target, "://") || strings.HasPrefix(target, "mailto:") {
+		a, err = todo.AttachURL(id, target)
+	} else if info, statErr := os.Stat(target); statErr == nil && (attachLinkFlag || info.IsDir()) {
+		a, err = todo.AttachPath(id, target)
+	} else {
+		a, err = todo.AttachFile(id, target)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Attached %s %s to item %d\n", a.Kind, a, id)
+	return nil
+}
+
+func runDetach(cmd *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", args[0])
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid attachment number %q", args[1])
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	a, err := todo.Detach(id, n)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s %s from item %d\n", a.Kind, a, id)
+	return nil
+}
+
+func runOpen(cmd *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", args[0])
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	item, err := todo.GetItem(id)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		printAttachments(item)
+		return nil
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid attachment number %q", args[1])
+	}
+	if n < 1 || n > len(item.Attachments) {
+		return fmt.Errorf("item %d has no attachment %d", id, n)
+	}
+	a := item.Attachments[n-1]
+
+	target := a.Target
+	if a.Kind == db.AttachmentFile {
+		if target, err = extractAttachment(todo, a); err != nil {
+			return err
+		}
+	}
+	if openPrintFlag {
+		fmt.Println(target)
+		return nil
+	}
+	return openTarget(target)
+}
+
+func printAttachments(item db.ToDoItem) {
+	if cfg.Get("output.format") == "json" {
+		attachments := item.Attachments
+		if attachments == nil {
+			attachments = []db.Attachment{}
+		}
+		printJson(os.Stdout, attachments)
+		return
+	}
+	fmt.Println(formatItemLine(item))
+	for i, a := range item.Attachments {
+		line := fmt.Sprintf("  %2d  %-4s  %s", i+1, a.Kind, a)
+		if a.Kind == db.AttachmentFile {
+			line += fmt.Sprintf("  (%s)", formatSize(a.Size))
+		}
+		fmt.Println(line)
+	}
+}
+
+// extractAttachment copies an attached file out of the database into a
+// new private temporary directory, under its original name so that it
+// opens with the right program.  The program opening it may still be
+// starting when todo exits, so the copy is left behind.  For an encrypted
+// database that is a decrypted copy, which the user is warned about.
+func extractAttachment(todo *db.ToDo, a db.Attachment) (string, error) {
+	data, err := todo.ReadAttachment(a)
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "todo-attachment-")
+	if err != nil {
+		return "", err
+	}
+	fileName := filepath.Join(dir, filepath.Base(a.Name))
+	if err := os.WriteFile(fileName, data, 0600); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	if todo.IsEncrypted() {
+		fmt.Fprintf(os.Stderr, "Warning: %s is a decrypted copy of the attachment, remove it when you are done\n", fileName)
+	}
+	return fileName, nil
+}
+
+// openTarget opens a url or a file with open.command, or the opener of the
+// platform
+func openTarget(target string) error {
+	var cmd *exec.Cmd
+	if command := cfg.Get("open.command"); command != "" {
+		cmd = exec.Command("/bin/sh", "-c", command+` "$@"`, "sh", target)
+	} else {
+		switch runtime.GOOS {
+		case "darwin":
+			cmd = exec.Command("open", target)
+		case "windows":
+			cmd = exec.Command("cmd", "/c", "start", "", target)
+		default:
+			cmd = exec.Command("xdg-open", target)
+		}
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("opening %s: %w", target, err)
+	}
+	return nil
+}
+
+// formatSize formats a number of bytes, for example 12.3 KB
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/duration"
+)
+
+// The flags selecting the items of the bulk commands, shared by all of
+// them
+var (
+	filterIdFlags       []int
+	filterTagFlags      []string
+	filterStatusFlag    string
+	filterDoneFlag      bool
+	filterOpenFlag      bool
+	filterOlderThanFlag string
+	filterDueBeforeFlag string
+	filterMatchFlag     string
+	filterAllFlag       bool
+	bulkYesFlag         bool
+	bulkDryRunFlag      bool
+)
+
+const bulkLong = `
+The items are selected with filters, all of which have to match:
+
+  --id 3 --id 4        the items with these ids
+  --tag sprint-12      items with the tag (repeat for several tags)
+  --status review      items in a state of the workflow
+  --done, --open       done or open items
+  --older-than 30d     items completed (open items: created) at least 30 days ago
+  --due-before DATE    open items due before a date (YYYY-MM-DD, today,
+                       tomorrow) or a duration from now such as 3d
+  --match TEXT         items with the text in the title
+  --all                every item, when no other filter is given
+
+The selected items are listed and the change has to be confirmed, unless
+--yes is given.  --dry-run only lists them.  The change is applied to all
+items at once: if it fails for one item (for example because a hook
+rejects it) no item is changed.`
+
+var (
+	doneCmd = &cobra.Command{
+		Use:   "done [FILTERS]",
+		Short: "Mark all items matching the filters done, for example 'todo done --tag sprint-12'",
+		Long:  "Mark all items matching the filters done." + bulkLong,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBulkUpdate("mark done", func(todo *db.ToDo, item db.ToDoItem) db.ToDoItem {
+				return todo.WithDone(item, true)
+			})
+		},
+	}
+	reopenCmd = &cobra.Command{
+		Use:   "reopen [FILTERS]",
+		Short: "Mark all items matching the filters not done",
+		Long:  "Mark all items matching the filters not done." + bulkLong,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBulkUpdate("reopen", func(todo *db.ToDo, item db.ToDoItem) db.ToDoItem {
+				return todo.WithDone(item, false)
+			})
+		},
+	}
+	deleteCmd = &cobra.Command{
+		Use:   "delete [FILTERS]",
+		Short: "Delete all items matching the filters, for example 'todo delete --done --older-than 30d'",
+		Long:  "Delete all items matching the filters, they are moved to the trash." + bulkLong,
+		Args:  cobra.NoArgs,
+		RunE:  runBulkDelete,
+	}
+	tagCmd = &cobra.Command{
+		Use:   "tag",
+		Short: "Add or remove a tag on all items matching filters",
+	}
+	tagAddCmd = &cobra.Command{
+		Use:   "add TAG [FILTERS]",
+		Short: "Add a tag to all items matching the filters, for example 'todo tag add urgent --due-before tomorrow'",
+		Long:  "Add a tag to all items matching the filters." + bulkLong,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBulkUpdate("tag "+args[0], func(todo *db.ToDo, item db.ToDoItem) db.ToDoItem {
+				if !hasTag(item, args[0]) {
+					item.Tags = append(append([]string(nil), item.Tags...), args[0])
+				}
+				return item
+			})
+		},
+	}
+	tagRemoveCmd = &cobra.Command{
+		Use:   "remove TAG [FILTERS]",
+		Short: "Remove a tag from all items matching the filters",
+		Long:  "Remove a tag from all items matching the filters." + bulkLong,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBulkUpdate("untag "+args[0], func(todo *db.ToDo, item db.ToDoItem) db.ToDoItem {
+				var tags []string
+				for _, tag := range item.Tags {
+					if tag != args[0] {
+						tags = append(tags, tag)
+					}
+				}
+				item.Tags = tags
+				return item
+			})
+		},
+	}
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{doneCmd, reopenCmd, deleteCmd, tagAddCmd, tagRemoveCmd} {
+		flags := cmd.Flags()
+		flags.IntSliceVar(&filterIdFlags, "id", nil, "Select the items with these ids")
+		flags.StringArrayVar(&filterTagFlags, "tag", nil, "Select the items with this tag, can be repeated")
+		flags.StringVar(&filterStatusFlag, "status", "", "Select the items in this state")
+		flags.BoolVar(&filterDoneFlag, "done", false, "Select done items")
+		flags.BoolVar(&filterOpenFlag, "open", false, "Select open items")
+		flags.StringVar(&filterOlderThanFlag, "older-than", "", "Select items completed (open items: created) at least this long ago, for example 30d")
+		flags.StringVar(&filterDueBeforeFlag, "due-before", "", "Select open items due before a date, today, tomorrow or a duration from now")
+		flags.StringVar(&filterMatchFlag, "match", "", "Select the items with this text in the title")
+		flags.BoolVar(&filterAllFlag, "all", false, "Select all items")
+		flags.BoolVarP(&bulkYesFlag, "yes", "y", false, "Don't ask for confirmation")
+		flags.BoolVar(&bulkDryRunFlag, "dry-run", false, "Only list the items that would be changed")
+	}
+	tagCmd.AddCommand(tagAddCmd, tagRemoveCmd)
+	rootCmd.AddCommand(doneCmd, reopenCmd, deleteCmd, tagCmd)
+}
+
+// bulkFilter builds the filter from the command line flags
+func bulkFilter() (db.Filter, error) {
+	f := db.Filter{
+		Ids:    filterIdFlags,
+		Tags:   filterTagFlags,
+		Status: filterStatusFlag,
+		Done:   filterDoneFlag,
+		Open:   filterOpenFlag,
+		Text:   filterMatchFlag,
+	}
+	if f.Done && f.Open {
+		return f, errors.New("--done and --open exclude each other")
+	}
+	if f.Status != "" && !workflow.Has(f.Status) {
+		return f, fmt.Errorf("unknown state %q, the states are %s", f.Status, strings.Join(workflow.States, ", "))
+	}
+	if filterOlderThanFlag != "" {
+		d, err := duration.Parse(filterOlderThanFlag)
+		if err != nil {
+			return f, fmt.Errorf("invalid --older-than: %w", err)
+		}
+		f.OlderThan = d
+	}
+	if filterDueBeforeFlag != "" {
+		due, err := parseDay(filterDueBeforeFlag, time.Now())
+		if err != nil {
+			return f, fmt.Errorf("invalid --due-before: %w", err)
+		}
+		f.DueBefore = &due
+	}
+	if f.IsZero() && !filterAllFlag {
+		return f, errors.New("select the items with a filter such as --tag or --done, or use --all for every item")
+	}
+	return f, nil
+}
+
+// parseDay accepts a date (YYYY-MM-DD, today or tomorrow, meaning the
+// start of the day in the local time zone) or a duration from now
+func parseDay(s string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch s {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return date, nil
+	}
+	d, err := duration.Parse(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date such as 2024-06-01, today, tomorrow or a duration such as 3d", s)
+	}
+	return now.Add(d), nil
+}
+
+// runBulkUpdate changes all selected items with change, items change
+// leaves as they are are skipped
+func runBulkUpdate(verb string, change func(*db.ToDo, db.ToDoItem) db.ToDoItem) error {
+	todo, selected, err := selectItems()
+	if err != nil {
+		return err
+	}
+
+	var before, after []db.ToDoItem
+	for _, item := range selected {
+		changed := change(todo, item)
+		if !reflect.DeepEqual(changed, item) {
+			before = append(before, item)
+			after = append(after, changed)
+		}
+	}
+	if ok, err := confirmBulk(verb, before); !ok || err != nil {
+		return err
+	}
+
+	if err := todo.UpdateItems(after); err != nil {
+		return err
+	}
+	fmt.Println("Ok,", len(after), "items changed")
+	return nil
+}
+
+func runBulkDelete(cmd *cobra.Command, args []string) error {
+	todo, selected, err := selectItems()
+	if err != nil {
+		return err
+	}
+	if ok, err := confirmBulk("delete", selected); !ok || err != nil {
+		return err
+	}
+
+	ids := make([]int, len(selected))
+	for i, item := range selected {
+		ids[i] = item.Id
+	}
+	if err := todo.DeleteItems(ids); err != nil {
+		return err
+	}
+	fmt.Println("Ok,", len(ids), "items moved to the trash")
+	return nil
+}
+
+func selectItems() (*db.ToDo, []db.ToDoItem, error) {
+	f, err := bulkFilter()
+	if err != nil {
+		return nil, nil, err
+	}
+	todo, err := openDB()
+	if err != nil {
+		return nil, nil, err
+	}
+	selected, err := todo.Select(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return todo, selected, nil
+}
+
+// confirmBulk lists the items that are about to change and asks for
+// confirmation, it reports whether the change should be applied
+func confirmBulk(verb string, items []db.ToDoItem) (bool, error) {
+	if len(items) == 0 {
+		fmt.Println("No items to", verb)
+		return false, nil
+	}
+	for _, item := range items {
+		fmt.Println(formatItemLine(item))
+	}
+	if bulkDryRunFlag {
+		fmt.Println("Would", verb, len(items), "items, nothing changed (--dry-run)")
+		return false, nil
+	}
+	if bulkYesFlag {
+		return true, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, errors.New("not running in a terminal, use --yes to confirm the change")
+	}
+
+	answer, err := prompt(bufio.NewReader(os.Stdin), os.Stdout, fmt.Sprintf("%s %d items? [y/N] ", capitalize(verb), len(items)), "y", "n", "")
+	if err != nil {
+		return false, errors.New("no answer given, nothing changed")
+	}
+	if answer != "y" {
+		fmt.Println("Nothing changed")
+		return false, nil
+	}
+	return true, nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
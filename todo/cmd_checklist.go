@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/checklist"
+)
+
+var syncMdCmd = &cobra.Command{
+	Use:   "sync-md [FILE]",
+	Short: "Sync the items with a Markdown checklist such as TASKS.md",
+	Long: `Write the items into a GitHub style Markdown checklist (default
+TASKS.md), grouped by their first tag, and read back the edits made to the
+file since: checked and unchecked boxes, changed titles and new "- [ ] ..."
+lines, which are added with the tag of the heading they are under.  The
+ids of the items are kept in hidden comments at the end of the lines.
+
+Edits made to a line win over changes made to the item in the database,
+lines that were not edited are rewritten from the database.  Removing a
+line doesn't delete the item.  Everything else in the file is replaced,
+a file that has no linked lines yet is copied to FILE.orig first.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSyncMd,
+}
+
+func init() {
+	rootCmd.AddCommand(syncMdCmd)
+}
+
+func runSyncMd(cmd *cobra.Command, args []string) error {
+	fileName := "TASKS.md"
+	if len(args) == 1 {
+		fileName = args[0]
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	result, err := checklist.Sync(todo, fileName)
+	if err != nil {
+		return err
+	}
+
+	if result.Backup != "" {
+		fmt.Println("Kept the original file as", result.Backup)
+	}
+	fmt.Println("Added:    ", len(result.Added))
+	fmt.Println("Renamed:  ", len(result.Renamed))
+	fmt.Println("Completed:", len(result.Completed))
+	fmt.Println("Reopened: ", len(result.Reopened))
+	if len(result.Missing) > 0 {
+		fmt.Println("Dropped lines of deleted items:", result.Missing)
+	}
+	fmt.Println("Wrote", fileName)
+	return nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/config"
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/templates"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish|powershell",
+	Short: "Print the shell completion script",
+	Long: `Print the completion script for a shell.  Besides commands and flags it
+completes the ids of the items in the current database (with their titles
+as descriptions), tags, workflow states, template names and config keys.
+
+  bash:        source <(todo completion bash)
+  zsh:         todo completion zsh > "${fpath[1]}/_todo"
+  fish:        todo completion fish > ~/.config/fish/completions/todo.fish
+  powershell:  todo completion powershell | Out-String | Invoke-Expression`,
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+	RunE:      runCompletion,
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
+
+// registerCompletions sets up the completion of arguments and flag values.
+// It has to run after all flags are defined, the flags of the root
+// command are only defined in processCmdLineFlags.
+func registerCompletions() {
+	for _, cmd := range []*cobra.Command{snoozeCmd, startCmd, logCmd} {
+		cmd.ValidArgsFunction = withConfig(completeItemIds(true))
+	}
+	moveCmd.ValidArgsFunction = withConfig(completeArgs(completeItemIds(true), completeStates))
+	assignCmd.ValidArgsFunction = withConfig(completeArgs(completeItemIds(false), completeUsers))
+	unassignCmd.ValidArgsFunction = withConfig(completeArgs(completeItemIds(false), completeUsers))
+	dependCmd.ValidArgsFunction = withConfig(completeAllArgs(completeItemIds(false)))
+	syncMdCmd.ValidArgsFunction = completeArgs(completeFiles)
+	attachCmd.ValidArgsFunction = withConfig(completeArgs(completeItemIds(false), completeFiles))
+	for _, cmd := range []*cobra.Command{commentCmd, activityCmd, detachCmd, openCmd, estimateCmd} {
+		cmd.ValidArgsFunction = withConfig(completeArgs(completeItemIds(false)))
+	}
+	registerFlagCompletion(moveCmd, "before", completeItemIds(false))
+	registerFlagCompletion(moveCmd, "after", completeItemIds(false))
+	for _, cmd := range []*cobra.Command{topCmd, bottomCmd} {
+		cmd.ValidArgsFunction = withConfig(completeArgs(completeItemIds(false)))
+	}
+	trashRestoreCmd.ValidArgsFunction = withConfig(completeTrashIds)
+	templateApplyCmd.ValidArgsFunction = withConfig(completeArgs(completeTemplates))
+	for _, cmd := range []*cobra.Command{configGetCmd, configSetCmd, configUnsetCmd} {
+		cmd.ValidArgsFunction = completeArgs(completeConfigKeys)
+	}
+
+	registerFlagCompletion(rootCmd, "format", cobra.FixedCompletions([]string{"json", "text"}, cobra.ShellCompDirectiveNoFileComp))
+	registerFlagCompletion(rootCmd, "query", completeItemIds(false))
+	registerFlagCompletion(rootCmd, "delete", completeItemIds(false))
+	registerFlagCompletion(listCmd, "status", completeStates)
+	registerFlagCompletion(listCmd, "tag", completeTags)
+	registerFlagCompletion(listCmd, "sort", cobra.FixedCompletions([]string{"id", "rank"}, cobra.ShellCompDirectiveNoFileComp))
+	for _, cmd := range []*cobra.Command{doneCmd, reopenCmd, deleteCmd, tagAddCmd, tagRemoveCmd} {
+		registerFlagCompletion(cmd, "id", completeItemIds(false))
+		registerFlagCompletion(cmd, "tag", completeTags)
+		registerFlagCompletion(cmd, "status", completeStates)
+	}
+	tagRemoveCmd.ValidArgsFunction = withConfig(completeArgs(completeTags))
+	registerFlagCompletion(timesheetCmd, "by", cobra.FixedCompletions([]string{"day", "tag"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// registerFlagCompletion completes the value of a flag with f.  The value
+// doesn't depend on the positional arguments, f is called without them.
+func registerFlagCompletion(cmd *cobra.Command, flag string, f completionFunc) {
+	complete := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return f(cmd, nil, toComplete)
+	}
+	if err := cmd.RegisterFlagCompletionFunc(flag, withConfig(complete)); err != nil {
+		panic(err)
+	}
+}
+
+func runCompletion(cmd *cobra.Command, args []string) error {
+	switch args[0] {
+	case "bash":
+		return rootCmd.GenBashCompletionV2(os.Stdout, true)
+	case "zsh":
+		return rootCmd.GenZshCompletion(os.Stdout)
+	case "fish":
+		return rootCmd.GenFishCompletion(os.Stdout, true)
+	case "powershell":
+		return rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+	}
+	return fmt.Errorf("unknown shell %q", args[0])
+}
+
+type completionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// withConfig reloads the configuration before completing.  The config is
+// loaded before the flags of the completed command line are parsed, so
+// without this a --db or --format on the command line would be ignored.
+func withConfig(f completionFunc) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if err := loadConfig(cmd); err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		return f(cmd, args, toComplete)
+	}
+}
+
+// completeArgs completes every positional argument with its own function,
+// arguments after the last function are not completed
+func completeArgs(funcs ...completionFunc) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= len(funcs) {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return funcs[len(args)](cmd, args, toComplete)
+	}
+}
+
+// completeAllArgs completes every positional argument with the same
+// function, as if it were the first
+func completeAllArgs(f completionFunc) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return f(cmd, nil, toComplete)
+	}
+}
+
+// completeItemIds completes the ids of the items in the database, with
+// their titles as descriptions.  With open only items that are not done
+// are suggested.  Only the first argument is an item id.
+func completeItemIds(open bool) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		items, err := completionItems()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		var ids []string
+		for _, item := range items {
+			if open && item.IsDone {
+				continue
+			}
+			ids = append(ids, strconv.Itoa(item.Id)+"\t"+item.Title)
+		}
+		return ids, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+	}
+}
+
+func completeTrashIds(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	todo, err := completionDB()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	if todo == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	entries, err := todo.Trash()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var ids []string
+	for _, entry := range entries {
+		ids = append(ids, strconv.Itoa(entry.Item.Id)+"\t"+entry.Item.Title)
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeFiles leaves the completion to the shell, which completes file
+// names
+func completeFiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveDefault
+}
+
+// completeTags completes the tags used by the items in the database
+func completeTags(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	items, err := completionItems()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	seen := make(map[string]bool)
+	var tags []string
+	for _, item := range items {
+		for _, tag := range item.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeUsers completes the assignees and comment authors of the items
+// in the database and the current user
+func completeUsers(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	items, err := completionItems()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	seen := make(map[string]bool)
+	if me, err := currentUser(); err == nil {
+		seen[me] = true
+	}
+	for _, item := range items {
+		for _, assignee := range item.Assignees {
+			seen[assignee] = true
+		}
+		for _, entry := range item.Activity {
+			seen[entry.Author] = true
+		}
+	}
+	users := make([]string, 0, len(seen))
+	for user := range seen {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	return users, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeStates completes the states of the workflow, in workflow order
+func completeStates(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return workflow.States, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+func completeTemplates(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	dir, err := templatesDir()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	names, err := templates.List(dir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var keys []string
+	for _, name := range config.Keys() {
+		key, _ := config.Lookup(name)
+		keys = append(keys, name+"\t"+key.Description)
+	}
+	return keys, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionItems returns the items of the current database, sorted by id
+func completionItems() ([]db.ToDoItem, error) {
+	todo, err := completionDB()
+	if err != nil || todo == nil {
+		return nil, err
+	}
+	items, err := todo.GetAllItems()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Id < items[j].Id
+	})
+	return items, nil
+}
+
+// completionDB opens the database like openDB, but without the side
+// effects a completion must not have.  It returns nil when the database
+// doesn't exist yet instead of creating it, it never asks for the
+// passphrase of an encrypted database, which would block the shell on a
+// hidden prompt, and it doesn't empty expired entries out of the trash.
+func completionDB() (*db.ToDo, error) {
+	loc, err := dbLocation()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(loc.Path); err != nil {
+		return nil, nil
+	}
+	opts := dbOptions()
+	opts.Passphrase = promptlessPassphrase
+	opts.TrashRetention = 0
+	return db.NewWithOptions(loc.Path, opts)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/config"
+	"drexel.edu/todo/workspace"
+)
+
+var (
+	configGlobalFlag bool
+	configCmd        = &cobra.Command{
+		Use:   "config",
+		Short: "Show and change the configuration",
+		Long: `Settings are taken from, in order of increasing precedence: the built-in
+defaults, the global config file (~/.config/todo/config.yaml), the config
+file of the current workspace (.todo/config.yaml), TODO_* environment
+variables (for example TODO_OUTPUT_FORMAT for output.format) and command
+line flags.`,
+	}
+	configGetCmd = &cobra.Command{
+		Use:   "get KEY",
+		Short: "Print the effective value of a setting",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runConfigGet,
+	}
+	configSetCmd = &cobra.Command{
+		Use:   "set KEY VALUE",
+		Short: "Store a setting in the workspace (or global) config file",
+		Args:  cobra.ExactArgs(2),
+		RunE:  runConfigSet,
+	}
+	configUnsetCmd = &cobra.Command{
+		Use:   "unset KEY",
+		Short: "Remove a setting from the workspace (or global) config file",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runConfigUnset,
+	}
+	configListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List all settings with their effective value and origin",
+		Args:  cobra.NoArgs,
+		RunE:  runConfigList,
+	}
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{configSetCmd, configUnsetCmd} {
+		cmd.Flags().BoolVar(&configGlobalFlag, "global", false, "Use the global config file even inside of a workspace")
+	}
+	configCmd.AddCommand(configGetCmd, configSetCmd, configUnsetCmd, configListCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigGet(cmd *cobra.Command, args []string) error {
+	if _, ok := config.Lookup(args[0]); !ok {
+		return fmt.Errorf("unknown config key %q", args[0])
+	}
+	fmt.Println(cfg.Get(args[0]))
+	return nil
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) error {
+	file, err := configFileToChange(args[0])
+	if err != nil {
+		return err
+	}
+	if err := config.SetInFile(file, args[0], args[1]); err != nil {
+		return err
+	}
+	fmt.Println("Set", args[0], "in", file)
+	return nil
+}
+
+func runConfigUnset(cmd *cobra.Command, args []string) error {
+	file, err := configFileToChange(args[0])
+	if err != nil {
+		return err
+	}
+	if err := config.SetInFile(file, args[0], ""); err != nil {
+		return err
+	}
+	fmt.Println("Removed", args[0], "from", file)
+	return nil
+}
+
+func runConfigList(cmd *cobra.Command, args []string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE\tDESCRIPTION")
+	for _, name := range config.Keys() {
+		key, _ := config.Lookup(name)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, cfg.Get(name), cfg.Source(name), key.Description)
+	}
+	return w.Flush()
+}
+
+// configFileToChange returns the config file set and unset write to: the
+// workspace config file inside of a workspace, the global one otherwise
+// and for trusted settings
+func configFileToChange(name string) (string, error) {
+	if key, _ := config.Lookup(name); !configGlobalFlag && !key.Trusted {
+		loc, err := workspace.Find(".")
+		if err != nil {
+			return "", err
+		}
+		if file := loc.ConfigFile(); file != "" {
+			return file, nil
+		}
+	}
+	return config.GlobalFile()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// passphraseEnv is the environment variable holding the passphrase of an
+// encrypted database.  It is not a config key on purpose, passphrases
+// should not end up in config files.
+const passphraseEnv = "TODO_PASSPHRASE"
+
+var (
+	encryptCmd = &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt the database file and its backups",
+		Long: `Encrypt the database file, its .bak file and the automatic backups with
+a passphrase (AES-256-GCM, key derived with scrypt).  All commands keep
+working on the encrypted database.  The passphrase is taken from the
+TODO_PASSPHRASE environment variable, the file set in crypto.key_file, or
+asked for on the terminal.`,
+		Args: cobra.NoArgs,
+		RunE: runEncrypt,
+	}
+	decryptCmd = &cobra.Command{
+		Use:   "decrypt",
+		Short: "Decrypt the database file and its backups",
+		Args:  cobra.NoArgs,
+		RunE:  runDecrypt,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(encryptCmd)
+	rootCmd.AddCommand(decryptCmd)
+}
+
+func runEncrypt(cmd *cobra.Command, args []string) error {
+	loc, err := dbLocation()
+	if err != nil {
+		return err
+	}
+	opts := dbOptions()
+	opts.Passphrase = newPassphrase
+	todo, err := newDB(loc, opts)
+	if err != nil {
+		return err
+	}
+	if err := todo.Encrypt(); err != nil {
+		return err
+	}
+	fmt.Println("Encrypted", loc.Path)
+	return nil
+}
+
+func runDecrypt(cmd *cobra.Command, args []string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if err := todo.Decrypt(); err != nil {
+		return err
+	}
+	fmt.Println("Decrypted the database")
+	return nil
+}
+
+// passphrase returns the passphrase of an encrypted database from the
+// environment, the configured key file or, as a last resort, by asking on
+// the terminal
+func passphrase() (string, error) {
+	if p, ok, err := storedPassphrase(); ok || err != nil {
+		return p, err
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("the database is encrypted, set %s or crypto.key_file", passphraseEnv)
+	}
+	return readPassword("Passphrase: ")
+}
+
+// promptlessPassphrase works like passphrase, but never asks on the
+// terminal
+func promptlessPassphrase() (string, error) {
+	if p, ok, err := storedPassphrase(); ok || err != nil {
+		return p, err
+	}
+	return "", fmt.Errorf("the database is encrypted, set %s or crypto.key_file", passphraseEnv)
+}
+
+// storedPassphrase returns the passphrase from the environment or the
+// configured key file, ok is false if neither is set
+func storedPassphrase() (p string, ok bool, err error) {
+	if p, ok := os.LookupEnv(passphraseEnv); ok {
+		return p, true, nil
+	}
+	if keyFile := cfg.Get("crypto.key_file"); keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return "", false, err
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+	return "", false, nil
+}
+
+// newPassphrase works like passphrase, but a passphrase typed on the
+// terminal has to be entered twice
+func newPassphrase() (string, error) {
+	_, fromEnv := os.LookupEnv(passphraseEnv)
+	if fromEnv || cfg.Get("crypto.key_file") != "" || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return passphrase()
+	}
+
+	first, err := readPassword("New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	second, err := readPassword("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if first != second {
+		return "", errors.New("passphrases do not match")
+	}
+	return first, nil
+}
+
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
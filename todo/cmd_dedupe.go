@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"drexel.edu/todo/db"
+)
+
+var (
+	dedupeSimilarityFlag int
+	dedupeDoneFlag       bool
+	dedupeYesFlag        bool
+	dedupeCmd            = &cobra.Command{
+		Use:   "dedupe",
+		Short: "Find items with similar titles and merge them",
+		Long: `List groups of open items with similar titles (case, punctuation and
+the order of the words don't matter) and merge each group into one item.
+For every group you pick the item to keep, the others are merged into it
+and moved to the trash.  The kept item gets their tags, notes, assignees,
+attachments, logged time, activity and subtasks, the earliest due date and
+the highest priority.
+
+Without a terminal the groups are only listed, unless --yes is given,
+which keeps the oldest item of every group.  dedupe.similarity sets how
+similar titles have to be, add.duplicates what 'todo -a' does with a
+title like the title of an open item.`,
+		Args: cobra.NoArgs,
+		RunE: runDedupe,
+	}
+)
+
+func init() {
+	dedupeCmd.Flags().IntVar(&dedupeSimilarityFlag, "similarity", 0, "How similar in percent titles have to be (default dedupe.similarity)")
+	dedupeCmd.Flags().BoolVar(&dedupeDoneFlag, "done", false, "Also compare done items")
+	dedupeCmd.Flags().BoolVarP(&dedupeYesFlag, "yes", "y", false, "Merge every group into its oldest item without asking")
+	rootCmd.AddCommand(dedupeCmd)
+}
+
+// similarityThreshold returns dedupe.similarity as a fraction
+func similarityThreshold() float64 {
+	return float64(cfg.Int("dedupe.similarity")) / 100
+}
+
+// checkDuplicates applies add.duplicates to a new item: it warns about or
+// refuses items whose title is like the title of an open item
+func checkDuplicates(todo *db.ToDo, item db.ToDoItem) error {
+	policy := cfg.Get("add.duplicates")
+	if policy == "off" {
+		return nil
+	}
+	found, err := todo.Duplicates(item.Title, similarityThreshold())
+	if err != nil || len(found) == 0 {
+		return err
+	}
+
+	var names []string
+	for _, dup := range found {
+		names = append(names, fmt.Sprintf("#%d '%s'", dup.Id, dup.Title))
+	}
+	if policy == "refuse" {
+		return fmt.Errorf("the item looks like a duplicate of %s, use --force to add it anyway", strings.Join(names, ", "))
+	}
+	fmt.Fprintln(os.Stderr, "Warning: the item looks like a duplicate of", strings.Join(names, ", ")+", see 'todo dedupe'")
+	return nil
+}
+
+func runDedupe(cmd *cobra.Command, args []string) error {
+	threshold := similarityThreshold()
+	if cmd.Flags().Changed("similarity") {
+		if dedupeSimilarityFlag < 1 || dedupeSimilarityFlag > 100 {
+			return errors.New("--similarity must be from 1 to 100")
+		}
+		threshold = float64(dedupeSimilarityFlag) / 100
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	clusters, err := todo.DuplicateClusters(threshold, dedupeDoneFlag)
+	if err != nil {
+		return err
+	}
+	if len(clusters) == 0 {
+		fmt.Println("No duplicates found")
+		return nil
+	}
+
+	interactive := !dedupeYesFlag && term.IsTerminal(int(os.Stdin.Fd()))
+	if !interactive && !dedupeYesFlag {
+		for i, cluster := range clusters {
+			printCluster(i, len(clusters), cluster)
+		}
+		fmt.Println("Run 'todo dedupe' in a terminal to merge the groups, or use --yes to keep the oldest item of each")
+		return nil
+	}
+
+	by, _ := currentUser()
+	reader := bufio.NewReader(os.Stdin)
+	merged := 0
+	for i, cluster := range clusters {
+		printCluster(i, len(clusters), cluster)
+		keep := 0
+		if interactive {
+			accepted := []string{"s", "q"}
+			for n := range cluster {
+				accepted = append(accepted, strconv.Itoa(n+1))
+			}
+			answer, err := prompt(reader, os.Stdout, fmt.Sprintf("Keep which item (1-%d), s to skip, q to quit? ", len(cluster)), accepted...)
+			if err != nil || answer == "q" {
+				break
+			}
+			if answer == "s" {
+				continue
+			}
+			keep, _ = strconv.Atoi(answer)
+			keep--
+		}
+
+		var others []int
+		for n, item := range cluster {
+			if n != keep {
+				others = append(others, item.Id)
+			}
+		}
+		if _, err := todo.MergeDuplicates(cluster[keep].Id, others, by); err != nil {
+			return err
+		}
+		fmt.Printf("Merged %d items into item %d\n", len(others), cluster[keep].Id)
+		merged += len(others)
+	}
+	fmt.Println("Ok,", merged, "items merged and moved to the trash")
+	return nil
+}
+
+func printCluster(i int, count int, cluster []db.ToDoItem) {
+	fmt.Printf("Group %d of %d:\n", i+1, count)
+	for n, item := range cluster {
+		fmt.Printf("  %d) %s\n", n+1, formatItemLine(item))
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/db"
+)
+
+var (
+	doctorFixFlag bool
+	doctorCmd     = &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the database file for damage and repair it",
+		Long: `Check the database file for entries that can't be parsed, fields with
+the wrong type or an invalid value, duplicate ids and data after the end
+of the list.  Problems are reported with their line and column in the
+file.
+
+With --fix every item that can be salvaged is written back into a
+repaired file: broken fields are dropped, items with duplicate or missing
+ids get new ids.  The original file is kept next to the database as
+<db>.corrupt-<time>.`,
+		Args: cobra.NoArgs,
+		RunE: runDoctor,
+	}
+)
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorFixFlag, "fix", false, "Repair the file, keeping the original as a backup")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+
+	var backup string
+	result, err := todo.Check()
+	if err == nil && doctorFixFlag {
+		result, backup, err = todo.Repair()
+	}
+	if err != nil {
+		return err
+	}
+
+	if cfg.Get("output.format") == "json" {
+		problems := result.Problems
+		if problems == nil {
+			problems = []db.Problem{}
+		}
+		printJson(os.Stdout, problems)
+	} else {
+		for _, problem := range result.Problems {
+			fmt.Println(problem)
+		}
+	}
+
+	switch {
+	case result.OK():
+		fmt.Fprintln(os.Stderr, todo.FileName(), "is ok,", len(result.Items), "items")
+	case doctorFixFlag:
+		fmt.Fprintln(os.Stderr, "Repaired", todo.FileName()+",", len(result.Items), "of", result.Entries, "entries salvaged, the original is kept in", backup)
+	default:
+		return fmt.Errorf("found %d problems in %s, run 'todo doctor --fix' to repair it", len(result.Problems), todo.FileName())
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	logMaxCountFlag int
+	restoreRevFlag  string
+	showCmd         = &cobra.Command{
+		Use:   "show REV",
+		Short: "Show a commit of the database and the items it changed",
+		Long: `Show a commit of the database made with history.git on, and the items it
+added, changed and deleted.  REV is a commit hash (or a prefix of it),
+HEAD, HEAD~2 and so on.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runShow,
+	}
+	restoreCmd = &cobra.Command{
+		Use:   "restore --rev REV",
+		Short: "Roll the database back to an earlier commit",
+		Long: `Replace all items with the items of an earlier commit of the database.
+The restore is committed like any other change, so the commits after REV
+are kept and the restore itself can be undone.`,
+		Args: cobra.NoArgs,
+		RunE: runRestore,
+	}
+)
+
+func init() {
+	logCmd.Flags().IntVarP(&logMaxCountFlag, "max-count", "n", 0, "Only show this many commits, 0 for all")
+	restoreCmd.Flags().StringVar(&restoreRevFlag, "rev", "", "Commit to restore")
+	restoreCmd.MarkFlagRequired("rev")
+	rootCmd.AddCommand(showCmd, restoreCmd)
+}
+
+func runHistoryLog(cmd *cobra.Command, args []string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	revisions, err := todo.Revisions()
+	if err != nil {
+		return err
+	}
+	if logMaxCountFlag > 0 && len(revisions) > logMaxCountFlag {
+		revisions = revisions[:logMaxCountFlag]
+	}
+
+	if cfg.Get("output.format") == "json" {
+		printJson(os.Stdout, revisions)
+		return nil
+	}
+	for _, rev := range revisions {
+		fmt.Println(rev.Short(), rev.Time.Local().Format("2006-01-02 15:04"), rev.Message)
+	}
+	return nil
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	rev, changes, err := todo.Revision(args[0])
+	if err != nil {
+		return err
+	}
+
+	if cfg.Get("output.format") == "json" {
+		printJson(os.Stdout, struct {
+			Revision interface{} `json:"revision"`
+			Changes  interface{} `json:"changes"`
+		}{rev, changes})
+		return nil
+	}
+	fmt.Println("commit", rev.Hash)
+	fmt.Println("Date:  ", rev.Time.Local().Format("2006-01-02 15:04:05"))
+	fmt.Println()
+	fmt.Println("   ", rev.Message)
+	fmt.Println()
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	return nil
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	rev, err := todo.RestoreRevision(restoreRevFlag)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Restored the database to", rev.Short(), rev.Message)
+	return nil
+}
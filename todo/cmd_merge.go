@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/db"
+)
+
+var (
+	mergeOutputFlag      string
+	mergeInteractiveFlag bool
+	mergeDriverFlag      bool
+	mergeCmd             = &cobra.Command{
+		Use:   "merge BASE OURS THEIRS",
+		Short: "Three-way merge of diverged todo database files",
+		Long: `Merge two todo database files (OURS and THEIRS) that diverged from a
+common ancestor (BASE).  Items are matched on their id and changes to
+different fields are combined automatically.  Fields changed to different
+values on both sides, and items deleted on one side but modified on the
+other, are conflicts.
+
+By default the result is written to OURS.  Conflicts are written as git
+style conflict markers that have to be fixed by hand, or resolved one by
+one with --interactive.
+
+To use todo as a git merge driver for the database file run:
+
+  git config merge.todo.name "todo database merge"
+  git config merge.todo.driver "todo merge --driver %O %A %B"
+  echo "data/todo.json merge=todo" >> .gitattributes`,
+		Args: cobra.ExactArgs(3),
+		RunE: runMerge,
+	}
+)
+
+func init() {
+	mergeCmd.Flags().StringVarP(&mergeOutputFlag, "output", "o", "", "File to write the merged database to (default OURS)")
+	mergeCmd.Flags().BoolVarP(&mergeInteractiveFlag, "interactive", "i", false, "Prompt for the resolution of every conflict")
+	mergeCmd.Flags().BoolVar(&mergeDriverFlag, "driver", false, "Run as a git merge driver, never prompt and exit with status 1 on conflicts")
+	rootCmd.AddCommand(mergeCmd)
+}
+
+func runMerge(cmd *cobra.Command, args []string) error {
+	if mergeDriverFlag && mergeInteractiveFlag {
+		return errors.New("--driver and --interactive cannot be used together")
+	}
+
+	var inputs [3][]db.ToDoItem
+	for i, fileName := range args {
+		items, err := readMergeInput(fileName)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", fileName, err)
+		}
+		inputs[i] = items
+	}
+
+	result, err := db.Merge(inputs[0], inputs[1], inputs[2])
+	if err != nil {
+		return err
+	}
+
+	if mergeInteractiveFlag && len(result.Conflicts) > 0 {
+		if err := resolveInteractively(&result, os.Stdin, os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	output := mergeOutputFlag
+	if output == "" {
+		output = args[1]
+	}
+
+	out, err := db.NewWithOptions(output, dbOptions())
+	if err != nil {
+		return err
+	}
+
+	if len(result.Conflicts) > 0 {
+		// Conflict markers are plain text, writing them would store the
+		// content of an encrypted database unencrypted
+		if out.IsEncrypted() {
+			return fmt.Errorf("%d conflict(s) in an encrypted database, use --interactive to resolve them", len(result.Conflicts))
+		}
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := result.WriteConflictMarkers(f, "ours", "theirs"); err != nil {
+			return err
+		}
+		for _, c := range result.Conflicts {
+			fmt.Fprintln(os.Stderr, "CONFLICT:", describeConflict(c))
+		}
+		return fmt.Errorf("%d conflict(s) written to %s, fix the conflict markers by hand", len(result.Conflicts), output)
+	}
+
+	//A merge driver writes to a temporary file of git, which must not get
+	//a trash, history or backups next to it
+	if mergeDriverFlag {
+		err = out.WriteItems(result.Items)
+	} else {
+		err = out.ReplaceAllItems(result.Items)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !mergeDriverFlag {
+		fmt.Println("Merged", len(result.Items), "items into", output)
+		fmt.Println("Ok")
+	}
+	return nil
+}
+
+// readMergeInput loads the items of a database file used as merge input.
+// Git hands an empty file to the merge driver when there is no common
+// ancestor, that is treated like an empty database.
+func readMergeInput(fileName string) ([]db.ToDoItem, error) {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, nil
+	}
+
+	input, err := db.NewWithOptions(fileName, dbOptions())
+	if err != nil {
+		return nil, err
+	}
+	return input.GetAllItems()
+}
+
+// resolveInteractively asks the user how to settle every conflict of the
+// merge result, reading answers from in and writing prompts to out
+func resolveInteractively(result *db.MergeResult, in io.Reader, out io.Writer) error {
+	reader := bufio.NewReader(in)
+
+	//Resolving modifies result.Conflicts, so work on a copy
+	conflicts := append([]db.MergeConflict(nil), result.Conflicts...)
+	for _, c := range conflicts {
+		fmt.Fprintln(out, "CONFLICT:", describeConflict(c))
+
+		if len(c.Fields) == 0 {
+			survivor := c.Ours
+			if survivor == nil {
+				survivor = c.Theirs
+			}
+			printJson(out, *survivor)
+			answer, err := prompt(reader, out, "Keep the modified item or delete it? [k/d] ", "k", "d")
+			if err != nil {
+				return err
+			}
+			if err := result.ResolveDeletion(c.Id, answer == "k"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		useTheirs := make(map[string]bool)
+		for _, field := range c.Fields {
+			fmt.Fprintf(out, "  %s\n    ours:   %s\n    theirs: %s\n", field,
+				fieldValue(c.Ours, field), fieldValue(c.Theirs, field))
+			answer, err := prompt(reader, out, "  Use ours or theirs? [o/t] ", "o", "t")
+			if err != nil {
+				return err
+			}
+			useTheirs[field] = answer == "t"
+		}
+		if err := result.ResolveFields(c.Id, useTheirs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prompt keeps asking until one of the accepted answers is entered
+func prompt(reader *bufio.Reader, out io.Writer, question string, accepted ...string) (string, error) {
+	for {
+		fmt.Fprint(out, question)
+		line, err := reader.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		for _, a := range accepted {
+			if answer == a {
+				return answer, nil
+			}
+		}
+		if err != nil {
+			return "", errors.New("no answer given, merge aborted")
+		}
+	}
+}
+
+func describeConflict(c db.MergeConflict) string {
+	switch {
+	case len(c.Fields) > 0 && c.Base == nil:
+		return fmt.Sprintf("item %d was added on both sides with different %s", c.Id, strings.Join(c.Fields, ", "))
+	case len(c.Fields) > 0:
+		return fmt.Sprintf("item %d has conflicting changes to %s", c.Id, strings.Join(c.Fields, ", "))
+	case c.Ours == nil:
+		return fmt.Sprintf("item %d was deleted in ours but modified in theirs", c.Id)
+	default:
+		return fmt.Sprintf("item %d was modified in ours but deleted in theirs", c.Id)
+	}
+}
+
+// fieldValue returns the json encoded value of a single field of an item
+func fieldValue(item *db.ToDoItem, field string) string {
+	var fields map[string]json.RawMessage
+	data, _ := json.Marshal(item)
+	json.Unmarshal(data, &fields)
+	if v, ok := fields[field]; ok {
+		return string(v)
+	}
+	return "(not set)"
+}
+
+func printJson(out io.Writer, v interface{}) {
+	jsonBytes, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Fprintln(out, string(jsonBytes))
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/plan"
+	"drexel.edu/todo/stats"
+)
+
+var (
+	dependRemoveFlag bool
+	planCapacityFlag string
+	burndownFromFlag string
+	burndownToFlag   string
+	burndownUnitFlag string
+	estimateCmd      = &cobra.Command{
+		Use:   "estimate ID ESTIMATE",
+		Short: "Estimate the effort of an item, for example 'todo estimate 7 3h' or 'todo estimate 7 5pt'",
+		Long: `Set the estimate of an item in hours, written like a duration (3h, 90m,
+1h30m), or in points (5pt, a plain number is points too).  'none'
+removes the estimate.  See 'todo plan' and 'todo burndown'.`,
+		Args: cobra.ExactArgs(2),
+		RunE: runEstimate,
+	}
+	dependCmd = &cobra.Command{
+		Use:   "depend ID OTHER_ID...",
+		Short: "Make an item depend on other items, which have to be done first",
+		Long: `Make an item depend on other items, which have to be done first.  'todo
+plan' plans an item only after the items it depends on.  Dependencies
+can't form a cycle, use --remove to drop them again.`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: runDepend,
+	}
+	planCmd = &cobra.Command{
+		Use:   "plan",
+		Short: "Propose the open items to work on within a capacity, for example 'todo plan --capacity 30h'",
+		Long: `Propose the open items that fit into a capacity of hours (30h) or points
+(20pt), the default is plan.capacity.  Items are taken by priority, then
+by due date, and every item comes after the open items it depends on.
+Only items estimated in the unit of the capacity are planned, snoozed
+items are not.  The items left out are listed with the reason.`,
+		Args: cobra.NoArgs,
+		RunE: runPlan,
+	}
+	burndownCmd = &cobra.Command{
+		Use:   "burndown",
+		Short: "Show the estimated work remaining per day",
+		Long: `Show per day the estimated work of the items that were open at the start
+or added since, how much of it is done, what remains and what would remain
+at a steady pace that finishes on the last day.  Only items estimated in
+--unit count, with their current estimate.  Archived items are included.`,
+		Args: cobra.NoArgs,
+		RunE: runBurndown,
+	}
+)
+
+func init() {
+	dependCmd.Flags().BoolVar(&dependRemoveFlag, "remove", false, "Remove the dependencies instead")
+	planCmd.Flags().StringVar(&planCapacityFlag, "capacity", "", "Work to plan, hours (30h) or points (20pt) (default plan.capacity)")
+	burndownCmd.Flags().StringVar(&burndownFromFlag, "from", "-13d", "First day, a date such as 2024-06-01 or a duration such as -14d")
+	burndownCmd.Flags().StringVar(&burndownToFlag, "to", "today", "Last day, a date such as 2024-06-14, today or a duration")
+	burndownCmd.Flags().StringVar(&burndownUnitFlag, "unit", "", "Unit of the estimates that count, h or pt (default the unit of plan.capacity, or h)")
+	rootCmd.AddCommand(estimateCmd, dependCmd, planCmd, burndownCmd)
+}
+
+func runEstimate(cmd *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", args[0])
+	}
+	estimate := args[1]
+	if estimate == "none" {
+		estimate = ""
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if err := todo.SetEstimate(id, estimate); err != nil {
+		return err
+	}
+	fmt.Println("Ok")
+	return nil
+}
+
+func runDepend(cmd *cobra.Command, args []string) error {
+	var ids []int
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid item id %q", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	for _, on := range ids[1:] {
+		if dependRemoveFlag {
+			err = todo.RemoveDependency(ids[0], on)
+		} else {
+			err = todo.AddDependency(ids[0], on)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println("Ok")
+	return nil
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	capacity := planCapacityFlag
+	if capacity == "" {
+		capacity = cfg.Get("plan.capacity")
+	}
+	if capacity == "" {
+		return errors.New("give the capacity with --capacity, for example --capacity 30h, or set plan.capacity")
+	}
+	c, err := db.ParseEstimate(capacity)
+	if err != nil {
+		return fmt.Errorf("invalid capacity %q, use for example 30h or 20pt", capacity)
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	items, err := todo.GetAllItems()
+	if err != nil {
+		return err
+	}
+	p := plan.Propose(items, c, time.Now())
+
+	if cfg.Get("output.format") == "json" {
+		printJson(os.Stdout, p)
+		return nil
+	}
+	fmt.Printf("Plan for %s: %s planned, %s left\n", c, amount(p.Planned, c.Unit), amount(p.Left, c.Unit))
+	for i, item := range p.Items {
+		fmt.Printf("  %2d) %s\n", i+1, formatItemLine(item))
+	}
+	if len(p.Skipped) > 0 {
+		fmt.Println()
+		fmt.Println("Not planned:")
+		for _, s := range p.Skipped {
+			fmt.Printf("      %s  - %s\n", formatItemLine(s.Item), s.Reason)
+		}
+	}
+	return nil
+}
+
+func runBurndown(cmd *cobra.Command, args []string) error {
+	now := time.Now()
+	from, err := parseDay(burndownFromFlag, now)
+	if err != nil {
+		return err
+	}
+	to, err := parseDay(burndownToFlag, now)
+	if err != nil {
+		return err
+	}
+	if to.Before(from) {
+		return errors.New("--to is before --from")
+	}
+	if to.Sub(from) > 366*24*time.Hour {
+		return errors.New("a burndown covers at most a year")
+	}
+	unit := burndownUnitFlag
+	if unit == "" {
+		unit = db.UnitHours
+		if c, err := db.ParseEstimate(cfg.Get("plan.capacity")); err == nil {
+			unit = c.Unit
+		}
+	}
+	if unit != db.UnitHours && unit != db.UnitPoints {
+		return fmt.Errorf("invalid unit %q, use h or pt", unit)
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	items, err := todo.GetAllItems()
+	if err != nil {
+		return err
+	}
+	archived, err := todo.ArchivedItems()
+	if err != nil {
+		return err
+	}
+	for _, entry := range archived {
+		items = append(items, entry.Item)
+	}
+	history, err := todo.History()
+	if err != nil {
+		return err
+	}
+	b := stats.ComputeBurndown(items, history, unit, from, to)
+
+	if cfg.Get("output.format") == "json" {
+		printJson(os.Stdout, b)
+		return nil
+	}
+	printBurndown(b)
+	return nil
+}
+
+func printBurndown(b stats.Burndown) {
+	fmt.Printf("Open at the start: %s\n", amount(b.Start, b.Unit))
+	fmt.Printf("  %-10s  %7s  %7s  %7s  %7s\n", "Date", "Scope", "Done", "Left", "Ideal")
+	most := 0.0
+	for _, day := range b.Days {
+		if day.Scope > most {
+			most = day.Scope
+		}
+	}
+	for _, day := range b.Days {
+		bar := ""
+		if most > 0 {
+			bar = strings.Repeat("#", int(day.Remaining/most*30+0.5))
+		}
+		fmt.Printf("  %-10s  %7s  %7s  %7s  %7s  %s\n", day.Date, amount(day.Scope, b.Unit), amount(day.Completed, b.Unit),
+			amount(day.Remaining, b.Unit), amount(day.Ideal, b.Unit), bar)
+	}
+}
+
+// amount formats an amount of work like an estimate, 0 included
+func amount(value float64, unit string) string {
+	if unit == db.UnitPoints {
+		value = float64(int64(value*10+0.5)) / 10
+	}
+	return db.Estimate{Amount: value, Unit: unit}.String()
+}
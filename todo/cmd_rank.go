@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/db"
+)
+
+var (
+	topCmd = &cobra.Command{
+		Use:   "top ID",
+		Short: "Move an item to the top of the list",
+		Long: `Move an item before all other items in the order used by
+'todo list --sort rank'.  Set list.sort to rank to make it the default.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRank(args[0], (*db.ToDo).MoveItemToTop)
+		},
+	}
+	bottomCmd = &cobra.Command{
+		Use:   "bottom ID",
+		Short: "Move an item to the bottom of the list",
+		Long: `Move an item after all other items in the order used by
+'todo list --sort rank'.  Set list.sort to rank to make it the default.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRank(args[0], (*db.ToDo).MoveItemToBottom)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(topCmd, bottomCmd)
+}
+
+func runRank(arg string, move func(*db.ToDo, int) error) error {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", arg)
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if err := move(todo, id); err != nil {
+		return err
+	}
+	fmt.Println("Ok")
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/remind"
+)
+
+var (
+	remindDaemonFlag bool
+	agendaCmd        = &cobra.Command{
+		Use:   "agenda",
+		Short: "Show the overdue items and the items due today and this week",
+		Args:  cobra.NoArgs,
+		RunE:  runAgenda,
+	}
+	remindCmd = &cobra.Command{
+		Use:   "remind",
+		Short: "Send the reminders of items reaching their due date",
+		Long: `Send a reminder for every open item that reached its due date, and an
+early reminder for items with a remind_before offset (or when
+remind.default_offset is set).  Every reminder is sent only once, the
+fired reminders are remembered in a file next to the database.
+
+Reminders run remind.command through the shell, with the item in the
+TODO_ID, TODO_TITLE, TODO_DUE, TODO_REMINDER and TODO_MESSAGE environment
+variables, for example:
+
+  todo config set remind.command 'notify-send "todo" "$TODO_MESSAGE"'
+
+Without a command reminders are written to remind.log, or to stdout.
+
+Without --daemon the reminders are checked once, which suits cron.  With
+--daemon they are checked every remind.interval until interrupted.`,
+		Args: cobra.NoArgs,
+		RunE: runRemind,
+	}
+)
+
+func init() {
+	remindCmd.Flags().BoolVar(&remindDaemonFlag, "daemon", false, "Keep running and check every remind.interval")
+	rootCmd.AddCommand(agendaCmd, remindCmd)
+}
+
+func runAgenda(cmd *cobra.Command, args []string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	items, err := todo.GetAllItems()
+	if err != nil {
+		return err
+	}
+
+	agenda := remind.NewAgenda(items, time.Now())
+	if cfg.Get("output.format") == "json" {
+		printJson(os.Stdout, agenda)
+		return nil
+	}
+
+	groups := []struct {
+		title string
+		items []db.ToDoItem
+	}{
+		{"OVERDUE", agenda.Overdue},
+		{"TODAY", agenda.Today},
+		{"THIS WEEK", agenda.ThisWeek},
+	}
+	for i, group := range groups {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%d)\n", group.title, len(group.items))
+		for _, item := range group.items {
+			fmt.Println(formatItemLine(item))
+		}
+	}
+	return nil
+}
+
+func runRemind(cmd *cobra.Command, args []string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+
+	if !remindDaemonFlag {
+		return checkReminders(todo)
+	}
+
+	interval := cfg.Duration("remind.interval")
+	if interval <= 0 {
+		return fmt.Errorf("remind.interval must be more than 0")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		//A failing check (a DB being rewritten, a broken command) should
+		//not end the daemon, report it and try again later
+		if err := checkReminders(todo); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkReminders sends all pending reminders and records them as fired
+func checkReminders(todo *db.ToDo) error {
+	items, err := todo.GetAllItems()
+	if err != nil {
+		return err
+	}
+	state, err := remind.LoadState(todo.FileName() + remind.StateSuffix)
+	if err != nil {
+		return err
+	}
+	pending, err := remind.Pending(items, state, time.Now(), cfg.Duration("remind.default_offset"))
+	if err != nil {
+		return err
+	}
+
+	log := io.Writer(os.Stdout)
+	if logFile := cfg.Get("remind.log"); logFile != "" && cfg.Get("remind.command") == "" {
+		f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		log = f
+	}
+
+	//Record every reminder that was sent, even if a later one fails, so
+	//nothing is sent twice
+	var notifyErr error
+	for _, r := range pending {
+		if notifyErr = remind.Notify(r, cfg.Get("remind.command"), log); notifyErr != nil {
+			break
+		}
+		state.MarkFired(r.Key(), time.Now())
+	}
+	state.Prune(items)
+	if err := state.Save(); err != nil {
+		return err
+	}
+	return notifyErr
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/scan"
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan [DIR]",
+	Short: "Harvest TODO, FIXME and XXX comments from source code",
+	Long: `Walk the source files below DIR (default the current directory) and
+create an item for every TODO, FIXME and XXX comment.  Rescanning updates
+the existing items instead of adding duplicates, and items whose comment
+was removed from the code are marked done.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runScan,
+}
+
+func init() {
+	rootCmd.AddCommand(scanCmd)
+}
+
+func runScan(cmd *cobra.Command, args []string) error {
+	root := "."
+	if len(args) == 1 {
+		root = args[0]
+	}
+
+	comments, err := scan.Dir(root)
+	if err != nil {
+		return err
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+
+	result, err := scan.Sync(todo, root, comments)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Found", len(comments), "comments in", root)
+	fmt.Println("Added:  ", len(result.Added))
+	fmt.Println("Updated:", len(result.Updated))
+	fmt.Println("Closed: ", len(result.Closed))
+	fmt.Println("Ok")
+	return nil
+}
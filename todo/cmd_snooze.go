@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/duration"
+)
+
+var (
+	snoozeClearFlag bool
+	snoozeCmd       = &cobra.Command{
+		Use:   "snooze ID [DURATION|DATE]",
+		Short: "Hide an item until a later date",
+		Long: `Hide an item from the lists for a while, for example 'todo snooze 3 3d'
+or until a date, 'todo snooze 3 2024-06-01'.  The item shows up again by
+itself when the date passes, use --all to list snoozed items and --clear
+to wake an item up early.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: runSnooze,
+	}
+)
+
+func init() {
+	snoozeCmd.Flags().BoolVar(&snoozeClearFlag, "clear", false, "Show the item again right away")
+	rootCmd.AddCommand(snoozeCmd)
+}
+
+func runSnooze(cmd *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", args[0])
+	}
+	if snoozeClearFlag == (len(args) == 2) {
+		return errors.New("give either a duration or date, or --clear")
+	}
+
+	var until time.Time
+	if !snoozeClearFlag {
+		if until, err = parseSnooze(args[1], time.Now()); err != nil {
+			return err
+		}
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if err := todo.Snooze(id, until); err != nil {
+		return err
+	}
+	if until.IsZero() {
+		fmt.Println("Item", id, "is visible again")
+	} else {
+		fmt.Println("Snoozed item", id, "until", formatDate(until))
+	}
+	return nil
+}
+
+// parseSnooze accepts a duration from now or a date (YYYY-MM-DD, in the
+// local time zone)
+func parseSnooze(s string, now time.Time) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return date, nil
+	}
+	d, err := duration.Parse(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid snooze %q, use a duration such as 3d or a date such as 2024-06-01", s)
+	}
+	if d <= 0 {
+		return time.Time{}, errors.New("the snooze duration must be more than 0")
+	}
+	return now.Add(d), nil
+}
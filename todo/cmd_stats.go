@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/stats"
+)
+
+var (
+	statsDaysFlag int
+	statsHTMLFlag string
+	statsCmd      = &cobra.Command{
+		Use:   "stats",
+		Short: "Show statistics about the todo list",
+		Long: `Show how many items are open and done, the completion rate, the average
+time it takes to complete an item, the overdue items and the breakdown by
+tag and priority.  Archived items count as done.
+
+The numbers are computed from the timestamps of the items and, for items
+without timestamps, from the history of changes.  --html writes the
+report, with charts, to a single HTML file that can be shared as it is.`,
+		Args: cobra.NoArgs,
+		RunE: runStats,
+	}
+)
+
+func init() {
+	statsCmd.Flags().IntVar(&statsDaysFlag, "days", 14, "Number of days covered by the activity per day")
+	statsCmd.Flags().StringVar(&statsHTMLFlag, "html", "", "Also write the report as HTML to this file")
+	rootCmd.AddCommand(statsCmd)
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	if statsDaysFlag < 1 {
+		return errors.New("--days must be at least 1")
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	items, err := todo.GetAllItems()
+	if err != nil {
+		return err
+	}
+	archived, err := todo.ArchivedItems()
+	if err != nil {
+		return err
+	}
+	for _, entry := range archived {
+		items = append(items, entry.Item)
+	}
+	history, err := todo.History()
+	if err != nil {
+		return err
+	}
+
+	report := stats.Compute(items, history, time.Now(), statsDaysFlag)
+
+	if statsHTMLFlag != "" {
+		f, err := os.Create(statsHTMLFlag)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := report.WriteHTML(f); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Get("output.format") == "json" {
+		printJson(os.Stdout, report)
+		return nil
+	}
+	printStats(report)
+	return nil
+}
+
+func printStats(r stats.Report) {
+	fmt.Printf("Items:       %d (%d open, %d done)\n", r.Total, r.Open, r.Done)
+	fmt.Printf("Completed:   %.0f%%\n", r.CompletionRate*100)
+	if r.Completed > 0 {
+		fmt.Printf("Avg. time:   %s (over %d items)\n", stats.FormatDuration(r.AverageTimeToComplete), r.Completed)
+	}
+	fmt.Printf("Overdue:     %d\n", len(r.Overdue))
+	for _, item := range r.Overdue {
+		fmt.Println(" ", formatItemLine(item))
+	}
+
+	if len(r.ByTag) > 0 {
+		fmt.Println()
+		fmt.Println("By tag:")
+		for _, tag := range r.Tags() {
+			c := r.ByTag[tag]
+			fmt.Printf("  %-16s %4d open %4d done\n", tag, c.Open, c.Done)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("By priority:")
+	for _, p := range r.Priorities() {
+		c := r.ByPriority[p]
+		fmt.Printf("  %-16s %4d open %4d done\n", stats.PriorityName(p), c.Open, c.Done)
+	}
+
+	fmt.Println()
+	fmt.Printf("Last %d days:\n", len(r.Days))
+	for _, day := range r.Days {
+		fmt.Printf("  %s  %3d created %3d completed\n", day.Date, day.Created, day.Completed)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/db"
+)
+
+var (
+	convertCmd = &cobra.Command{
+		Use:   "convert json|log",
+		Short: "Store the database in the json or the log format",
+		Long: `Rewrite the database file in another format.  The json format (the
+default) stores the items as a json list that is rewritten on every
+change.  The log format appends every change to the file instead, which
+is much faster for databases with many thousands of items; it is
+compacted from time to time and by 'todo compact'.  Encrypted databases
+have to use the json format.`,
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{db.FormatJSON, db.FormatLog},
+		RunE:      runConvert,
+	}
+	compactCmd = &cobra.Command{
+		Use:   "compact",
+		Short: "Rewrite a database in the log format without the replaced records",
+		Args:  cobra.NoArgs,
+		RunE:  runCompact,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(convertCmd, compactCmd)
+}
+
+func runConvert(cmd *cobra.Command, args []string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if todo.Format() == args[0] {
+		fmt.Println(todo.FileName(), "already uses the", args[0], "format")
+		return nil
+	}
+	if err := todo.Convert(args[0]); err != nil {
+		return err
+	}
+	fmt.Println("Converted", todo.FileName(), "to the", args[0], "format")
+	return nil
+}
+
+func runCompact(cmd *cobra.Command, args []string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if err := todo.Compact(); err != nil {
+		return err
+	}
+	fmt.Println("Ok")
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/db"
+)
+
+var (
+	assignCmd = &cobra.Command{
+		Use:   "assign ID USER",
+		Short: "Assign an item to a user, for example 'todo assign 7 alice'",
+		Long: `Add a user to the assignees of an item.  The first assignee owns the
+item.  The change is recorded in the activity of the item with your name,
+which is user.name or your login name.  Set user.name in the global
+config, not in the config of a shared workspace.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAssign(args, (*db.ToDo).Assign)
+		},
+	}
+	unassignCmd = &cobra.Command{
+		Use:   "unassign ID USER",
+		Short: "Remove a user from the assignees of an item",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAssign(args, (*db.ToDo).Unassign)
+		},
+	}
+	commentCmd = &cobra.Command{
+		Use:   "comment ID TEXT",
+		Short: "Add a comment to the activity of an item, for example 'todo comment 7 \"Waiting for review\"'",
+		Args:  cobra.MinimumNArgs(2),
+		RunE:  runComment,
+	}
+	activityCmd = &cobra.Command{
+		Use:   "activity ID",
+		Short: "Show the comments and assignments of an item",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runActivity,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(assignCmd, unassignCmd, commentCmd, activityCmd)
+}
+
+// currentUser returns the name of the user running the command, user.name
+// or the login name when it is not set
+func currentUser() (string, error) {
+	if name := cfg.Get("user.name"); name != "" {
+		return name, nil
+	}
+	if u, err := user.Current(); err == nil && u.Username != "" && !strings.ContainsAny(u.Username, " \t@\\") {
+		return u.Username, nil
+	}
+	return "", errors.New("your name is unknown, set it with 'todo config set user.name NAME'")
+}
+
+func runAssign(args []string, change func(todo *db.ToDo, id int, user string, by string) error) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", args[0])
+	}
+	by, err := currentUser()
+	if err != nil {
+		return err
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if err := change(todo, id, args[1], by); err != nil {
+		return err
+	}
+	fmt.Println("Ok")
+	return nil
+}
+
+func runComment(cmd *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", args[0])
+	}
+	author, err := currentUser()
+	if err != nil {
+		return err
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if err := todo.Comment(id, author, strings.Join(args[1:], " ")); err != nil {
+		return err
+	}
+	fmt.Println("Ok")
+	return nil
+}
+
+func runActivity(cmd *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", args[0])
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	item, err := todo.GetItem(id)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Get("output.format") == "json" {
+		activity := item.Activity
+		if activity == nil {
+			activity = []db.Activity{}
+		}
+		printJson(os.Stdout, activity)
+		return nil
+	}
+	fmt.Println(formatItemLine(item))
+	for _, entry := range item.Activity {
+		fmt.Printf("  %s %s  %-12s %s\n", formatDate(entry.At), entry.At.Local().Format("15:04"), entry.Author, formatActivity(entry))
+	}
+	return nil
+}
+
+// formatActivity describes an entry of the activity of an item
+func formatActivity(entry db.Activity) string {
+	switch entry.Kind {
+	case db.ActivityAssign:
+		return "assigned @" + entry.Text
+	case db.ActivityUnassign:
+		return "unassigned @" + entry.Text
+	case db.ActivityMerge:
+		return "merged " + entry.Text
+	}
+	return entry.Text
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/templates"
+)
+
+var (
+	templateVarFlags []string
+	templateCmd      = &cobra.Command{
+		Use:   "template",
+		Short: "Create items from templates such as checklists",
+		Long: `Templates are YAML files in the templates directory next to the
+database (or templates.dir), one file per template named <name>.yaml:
+
+  description: Release checklist
+  vars:
+    version: ""
+  items:
+    - title: Release {{version}}
+      tags: [release]
+      due: 7d
+      subtasks:
+        - title: Tag v{{version}}
+        - title: Publish the release notes
+
+Applying a template creates all of its items at once, with new ids and
+the {{placeholders}} replaced by the values given with --var.  Subtasks
+are created with their parent_id set to the item above them.`,
+	}
+	templateListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the available templates",
+		Args:  cobra.NoArgs,
+		RunE:  runTemplateList,
+	}
+	templateApplyCmd = &cobra.Command{
+		Use:   "apply NAME",
+		Short: "Create the items of a template, for example 'todo template apply release --var version=1.2'",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runTemplateApply,
+	}
+)
+
+func init() {
+	templateApplyCmd.Flags().StringArrayVar(&templateVarFlags, "var", nil, "Value of a placeholder as name=value, can be repeated")
+	templateCmd.AddCommand(templateListCmd, templateApplyCmd)
+	rootCmd.AddCommand(templateCmd)
+}
+
+// templatesDir returns the directory with the templates of the database
+func templatesDir() (string, error) {
+	loc, err := dbLocation()
+	if err != nil {
+		return "", err
+	}
+	return sideDir(loc.Path, "templates.dir", "templates"), nil
+}
+
+func runTemplateList(cmd *cobra.Command, args []string) error {
+	dir, err := templatesDir()
+	if err != nil {
+		return err
+	}
+	names, err := templates.List(dir)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Println("No templates in", dir)
+		return nil
+	}
+
+	for _, name := range names {
+		tpl, err := templates.Load(dir, name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "WARNING:", err)
+			continue
+		}
+		line := name
+		if tpl.Description != "" {
+			line += "  " + tpl.Description
+		}
+		if placeholders := tpl.Placeholders(); len(placeholders) > 0 {
+			line += "  (vars: " + strings.Join(placeholders, ", ") + ")"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func runTemplateApply(cmd *cobra.Command, args []string) error {
+	vars := make(map[string]string)
+	for _, v := range templateVarFlags {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid --var %q, use name=value", v)
+		}
+		vars[name] = value
+	}
+
+	dir, err := templatesDir()
+	if err != nil {
+		return err
+	}
+	tpl, err := templates.Load(dir, args[0])
+	if err != nil {
+		return err
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	firstId, err := todo.NextId()
+	if err != nil {
+		return err
+	}
+	items, err := tpl.Instantiate(vars, firstId, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := todo.AddItems(items); err != nil {
+		return err
+	}
+
+	printItems(todo, items)
+	fmt.Println("Created", len(items), "items from template", tpl.Name)
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/duration"
+	"drexel.edu/todo/stats"
+)
+
+var (
+	timesheetDaysFlag int
+	timesheetByFlag   string
+	timesheetCSVFlag  string
+	startCmd          = &cobra.Command{
+		Use:   "start ID",
+		Short: "Start the timer of an item",
+		Long: `Start timing the work on an item.  Only one timer runs at a time, stop
+it with 'todo stop' before starting another one.  The timer is stored in
+the database, so it keeps running if the program or the computer crashes.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runStart,
+	}
+	stopCmd = &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the running timer and log the time on its item",
+		Args:  cobra.NoArgs,
+		RunE:  runStop,
+	}
+	logCmd = &cobra.Command{
+		Use:   "log [ID DURATION]",
+		Short: "Log time on an item ('todo log 3 25m'), or without arguments show the git history of the database",
+		Long: `The log command has two forms.
+
+  todo log ID DURATION   log time spent on an item, for example
+                         'todo log 3 25m'.  The duration needs a unit.
+  todo log               show the commits of the database when
+                         history.git is on, see 'todo show' and
+                         'todo restore' to look at or go back to one.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 && len(args) != 2 {
+				return fmt.Errorf("accepts no arguments or ID DURATION, received %d", len(args))
+			}
+			return nil
+		},
+		RunE: runLog,
+	}
+	timesheetCmd = &cobra.Command{
+		Use:   "timesheet",
+		Short: "Show the time logged per day or per tag",
+		Long: `Show the time logged on items (and archived items) during the last days,
+grouped by day or by tag.  --csv writes one line per item and day, use -
+to write it to stdout.`,
+		Args: cobra.NoArgs,
+		RunE: runTimesheet,
+	}
+)
+
+func init() {
+	timesheetCmd.Flags().IntVar(&timesheetDaysFlag, "days", 7, "Number of days, up to and including today, covered by the timesheet")
+	timesheetCmd.Flags().StringVar(&timesheetByFlag, "by", "day", "Group the time by day or by tag")
+	timesheetCmd.Flags().StringVar(&timesheetCSVFlag, "csv", "", "Write the timesheet as CSV to this file")
+	rootCmd.AddCommand(startCmd, stopCmd, logCmd, timesheetCmd)
+}
+
+func runStart(cmd *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", args[0])
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if err := todo.StartTimer(id); err != nil {
+		return err
+	}
+	fmt.Println("Started the timer of item", id)
+	return nil
+}
+
+func runStop(cmd *cobra.Command, args []string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	item, entry, err := todo.StopTimer()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Logged %s on item %d, %s in total\n", duration.Format(entry.Duration()), item.Id, duration.Format(item.TimeSpent()))
+	return nil
+}
+
+func runLog(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return runHistoryLog(cmd, args)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", args[0])
+	}
+	//A plain number is a number of days to duration.Parse, which is
+	//never what was meant here
+	if _, err := strconv.ParseFloat(strings.TrimSpace(args[1]), 64); err == nil {
+		return fmt.Errorf("duration %q has no unit, use for example 25m or 2h", args[1])
+	}
+	d, err := duration.Parse(args[1])
+	if err != nil {
+		return err
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if err := todo.LogTime(id, d, time.Now()); err != nil {
+		return err
+	}
+	fmt.Println("Ok")
+	return nil
+}
+
+func runTimesheet(cmd *cobra.Command, args []string) error {
+	if timesheetDaysFlag < 1 {
+		return errors.New("--days must be at least 1")
+	}
+	if timesheetByFlag != "day" && timesheetByFlag != "tag" {
+		return fmt.Errorf("--by must be day or tag, not %q", timesheetByFlag)
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	items, err := todo.GetAllItems()
+	if err != nil {
+		return err
+	}
+	archived, err := todo.ArchivedItems()
+	if err != nil {
+		return err
+	}
+	for _, entry := range archived {
+		items = append(items, entry.Item)
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	ts := stats.NewTimesheet(items, today.AddDate(0, 0, 1-timesheetDaysFlag), today.AddDate(0, 0, 1))
+
+	if timesheetCSVFlag != "" {
+		out := os.Stdout
+		if timesheetCSVFlag != "-" {
+			if out, err = os.Create(timesheetCSVFlag); err != nil {
+				return err
+			}
+			defer out.Close()
+		}
+		if err := ts.WriteCSV(out); err != nil {
+			return err
+		}
+		if timesheetCSVFlag == "-" {
+			return nil
+		}
+	}
+
+	if cfg.Get("output.format") == "json" {
+		printJson(os.Stdout, ts)
+		return nil
+	}
+	printTimesheet(ts)
+	return nil
+}
+
+func printTimesheet(ts stats.Timesheet) {
+	if timesheetByFlag == "tag" {
+		for _, total := range ts.ByTag {
+			fmt.Printf("%-20s %8s\n", total.Name, duration.Format(total.Duration))
+		}
+	} else {
+		for _, total := range ts.ByDay {
+			fmt.Printf("%s %8s\n", total.Name, duration.Format(total.Duration))
+			for _, row := range ts.Rows {
+				if row.Date == total.Name {
+					fmt.Printf("  %4d  %-40s %8s\n", row.Id, row.Title, duration.Format(row.Duration))
+				}
+			}
+		}
+	}
+	fmt.Printf("TOTAL %s\n", duration.Format(ts.Total))
+}
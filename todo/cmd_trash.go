@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	trashCmd = &cobra.Command{
+		Use:   "trash",
+		Short: "Show, restore and empty deleted items",
+		Long: `Deleted items are moved to the trash file next to the database.  They
+are kept for trash.retention_days days (see 'todo config') and can be
+restored until then.`,
+	}
+	trashListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the items in the trash",
+		Args:  cobra.NoArgs,
+		RunE:  runTrashList,
+	}
+	trashRestoreCmd = &cobra.Command{
+		Use:   "restore ID",
+		Short: "Move a deleted item back into the database",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runTrashRestore,
+	}
+	trashEmptyCmd = &cobra.Command{
+		Use:   "empty",
+		Short: "Remove all items in the trash for good",
+		Args:  cobra.NoArgs,
+		RunE:  runTrashEmpty,
+	}
+)
+
+func init() {
+	trashCmd.AddCommand(trashListCmd, trashRestoreCmd, trashEmptyCmd)
+	rootCmd.AddCommand(trashCmd)
+}
+
+func runTrashList(cmd *cobra.Command, args []string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	entries, err := todo.Trash()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Get("output.format") == "json" {
+		printJson(os.Stdout, entries)
+		return nil
+	}
+	for _, entry := range entries {
+		fmt.Println(formatItemLine(entry.Item), " deleted", formatDate(entry.DeletedAt))
+	}
+	fmt.Println("THERE ARE", len(entries), "ITEMS IN THE TRASH")
+	return nil
+}
+
+func runTrashRestore(cmd *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", args[0])
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if err := todo.RestoreFromTrash(id); err != nil {
+		return err
+	}
+	fmt.Println("Ok")
+	return nil
+}
+
+func runTrashEmpty(cmd *cobra.Command, args []string) error {
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	removed, err := todo.EmptyTrash()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Removed", len(removed), "items for good")
+	fmt.Println("Ok")
+	return nil
+}
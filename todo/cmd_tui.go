@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/tui"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Interactive full-screen interface",
+	Long: `Show the items in a full-screen terminal interface.  Move with the
+arrow keys or j/k, toggle done with space, add with a, edit the title with
+e, delete with d and filter as you type with /.  Changes made to the
+database by other programs show up automatically.`,
+	Args: cobra.NoArgs,
+	RunE: runTui,
+}
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+}
+
+func runTui(cmd *cobra.Command, args []string) error {
+	//Open the DB first, the passphrase prompt of an encrypted DB does
+	//not work once the screen is taken over
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if _, err := todo.GetAllItems(); err != nil {
+		return err
+	}
+
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+	if err := screen.Init(); err != nil {
+		return err
+	}
+	return tui.New(todo, screen).Run()
+}
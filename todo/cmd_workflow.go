@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/db"
+)
+
+var (
+	listBoardFlag  bool
+	listStatusFlag string
+	listTagFlag    string
+	listAllFlag    bool
+	listSortFlag   string
+	listMineFlag   bool
+	moveBeforeFlag int
+	moveAfterFlag  int
+	moveCmd        = &cobra.Command{
+		Use:   "move ID [STATE]",
+		Short: "Move an item to another state of the workflow or to another place in the list",
+		Long: `Move an item to another state of the workflow.  The states and the
+allowed moves between them are set with workflow.states and
+workflow.transitions.  Moving an item to the last state marks it done.
+
+--before and --after move an item right before or after another one in the
+order used by 'todo list --sort rank', for example 'todo move 7 --before 3'.
+See also 'todo top' and 'todo bottom'.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: runMove,
+	}
+	listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the items, optionally grouped by state or filtered by tag or assignee",
+		Long: `List the items, optionally grouped by state.  Snoozed items are only
+listed with --all.`,
+		Args: cobra.NoArgs,
+		RunE: runList,
+	}
+)
+
+func init() {
+	listCmd.Flags().BoolVar(&listBoardFlag, "board", false, "Group the items by state, like the columns of a kanban board")
+	listCmd.Flags().StringVar(&listStatusFlag, "status", "", "Only list the items in this state")
+	listCmd.Flags().StringVar(&listTagFlag, "tag", "", "Only list the items with this tag")
+	listCmd.Flags().BoolVar(&listAllFlag, "all", false, "Also list snoozed items")
+	listCmd.Flags().BoolVar(&listMineFlag, "mine", false, "Only list the items assigned to you (user.name)")
+	listCmd.Flags().StringVar(&listSortFlag, "sort", "", "Order of the items, id or rank (default list.sort)")
+	moveCmd.Flags().IntVar(&moveBeforeFlag, "before", 0, "Move the item right before this item")
+	moveCmd.Flags().IntVar(&moveAfterFlag, "after", 0, "Move the item right after this item")
+	rootCmd.AddCommand(moveCmd, listCmd)
+}
+
+func runMove(cmd *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid item id %q", args[0])
+	}
+	before, after := cmd.Flags().Changed("before"), cmd.Flags().Changed("after")
+	if before && after {
+		return errors.New("--before and --after exclude each other")
+	}
+	if len(args) < 2 && !before && !after {
+		return errors.New("give the state to move the item to, --before or --after")
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	if len(args) == 2 {
+		if err := todo.MoveItem(id, args[1]); err != nil {
+			return err
+		}
+	}
+	if before {
+		err = todo.MoveItemBefore(id, moveBeforeFlag)
+	} else if after {
+		err = todo.MoveItemAfter(id, moveAfterFlag)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Ok")
+	return nil
+}
+
+// boardColumn is a state with its items, for the json output of --board
+type boardColumn struct {
+	State string        `json:"state"`
+	Items []db.ToDoItem `json:"items"`
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	sortBy := cfg.Get("list.sort")
+	if cmd.Flags().Changed("sort") {
+		if listSortFlag != "id" && listSortFlag != "rank" {
+			return fmt.Errorf("--sort must be id or rank, not %q", listSortFlag)
+		}
+		sortBy = listSortFlag
+	}
+	if listStatusFlag != "" && !workflow.Has(listStatusFlag) {
+		return fmt.Errorf("unknown state %q, the states are %s", listStatusFlag, strings.Join(workflow.States, ", "))
+	}
+
+	me := ""
+	if listMineFlag {
+		var err error
+		if me, err = currentUser(); err != nil {
+			return err
+		}
+	}
+
+	todo, err := openDB()
+	if err != nil {
+		return err
+	}
+	items, err := todo.GetAllItems()
+	if err != nil {
+		return err
+	}
+	if !listAllFlag {
+		items = db.VisibleItems(items, time.Now())
+	}
+	sortItems(items, sortBy)
+
+	byState := make(map[string][]db.ToDoItem)
+	var selected []db.ToDoItem
+	for _, item := range items {
+		state := workflow.StatusOf(item)
+		if listStatusFlag != "" && state != listStatusFlag {
+			continue
+		}
+		if listTagFlag != "" && !hasTag(item, listTagFlag) {
+			continue
+		}
+		if me != "" && !item.IsAssignedTo(me) {
+			continue
+		}
+		byState[state] = append(byState[state], item)
+		selected = append(selected, item)
+	}
+
+	if !listBoardFlag {
+		printSortedItems(todo, selected, sortBy)
+		return nil
+	}
+
+	var board []boardColumn
+	for _, state := range workflow.States {
+		if listStatusFlag == "" || state == listStatusFlag {
+			column := boardColumn{State: state, Items: byState[state]}
+			if column.Items == nil {
+				column.Items = []db.ToDoItem{}
+			}
+			board = append(board, column)
+		}
+	}
+	if cfg.Get("output.format") == "json" {
+		printJson(os.Stdout, board)
+		return nil
+	}
+	for i, column := range board {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%d)\n", strings.ToUpper(column.State), len(column.Items))
+		for _, item := range column.Items {
+			fmt.Println(formatItemLine(item))
+		}
+	}
+	return nil
+}
+
+// hasTag reports whether an item has the given tag
+func hasTag(item db.ToDoItem, tag string) bool {
+	for _, t := range item.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"drexel.edu/todo/workspace"
+)
+
+var (
+	initCmd = &cobra.Command{
+		Use:   "init [DIR]",
+		Short: "Create a todo workspace with its own database",
+		Long: `Create a .todo directory with an empty database in DIR (default the
+current directory).  Every todo command run in DIR or one of its
+subdirectories uses this database unless --db is given.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runInit,
+	}
+	whereCmd = &cobra.Command{
+		Use:   "where",
+		Short: "Show which database file is used and why",
+		Args:  cobra.NoArgs,
+		RunE:  runWhere,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(whereCmd)
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) == 1 {
+		dir = args[0]
+	}
+
+	loc, err := workspace.Init(dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Initialized empty todo database in", loc.Path)
+	return nil
+}
+
+func runWhere(cmd *cobra.Command, args []string) error {
+	loc, err := dbLocation()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(loc.Path)
+	switch loc.Source {
+	case workspace.FromFlag:
+		fmt.Println("(set with --db)")
+	case workspace.FromConfig:
+		fmt.Println("(db.path set in the", string(cfg.Source("db.path")), "config)")
+	case workspace.FromWorkspace:
+		fmt.Println("(workspace in", loc.Root+")")
+	case workspace.FromMarker:
+		fmt.Println("(todo.json in", loc.Root+")")
+	case workspace.FromLegacy:
+		fmt.Println("(data/todo.json in", loc.Root+")")
+	case workspace.FromGlobal:
+		fmt.Println("(global database, no workspace found)")
+	}
+	return nil
+}
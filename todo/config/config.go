@@ -0,0 +1,569 @@
+// Package config implements the layered configuration of the todo CLI.
+//
+// Every setting has a built-in default which can be overridden, in order
+// of increasing precedence, by the global config file
+// (~/.config/todo/config.yaml), the config file of the current workspace
+// (.todo/config.yaml), TODO_* environment variables and command line
+// flags.  Keys are dotted names such as "output.format".  In the config
+// files they are written as nested YAML maps, the environment variable for
+// a key is TODO_ followed by the key in upper case with dots replaced by
+// underscores (TODO_OUTPUT_FORMAT).
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/duration"
+)
+
+const (
+	// FileName is the name of both the global and the workspace config file
+	FileName = "config.yaml"
+
+	envPrefix = "TODO_"
+)
+
+// Source describes which layer a setting was taken from
+type Source string
+
+const (
+	FromDefault   Source = "default"
+	FromGlobal    Source = "global"
+	FromWorkspace Source = "workspace"
+	FromEnv       Source = "env"
+	FromFlag      Source = "flag"
+)
+
+// Key describes a single configuration setting
+type Key struct {
+	Name        string
+	Default     string
+	Description string
+	Validate    func(value string) error
+
+	// Trusted settings run programs, so they are only taken from the
+	// global config, the environment and flags.  A workspace config file
+	// comes with the project it is in and can't set them.
+	Trusted bool
+}
+
+// keys is the registry of all known settings, values for keys that are
+// not in here are rejected
+var keys = map[string]Key{}
+
+func init() {
+	Register(Key{
+		Name:        "db.path",
+		Default:     "",
+		Description: "Database file, empty to find it from the current directory",
+	})
+	Register(Key{
+		Name:        "user.name",
+		Default:     "",
+		Description: "Your name on shared lists, used by assign, comment and list --mine, empty for your login name",
+		Validate:    UserName,
+	})
+	Register(Key{
+		Name:        "output.format",
+		Default:     "json",
+		Description: "Output format of listed items: json or text",
+		Validate:    OneOf("json", "text"),
+	})
+	Register(Key{
+		Name:        "output.date_format",
+		Default:     "2006-01-02",
+		Description: "Go time layout used to print dates",
+		Validate:    NotEmpty,
+	})
+	Register(Key{
+		Name:        "output.color",
+		Default:     "auto",
+		Description: "Use colors in text output: auto, always or never",
+		Validate:    OneOf("auto", "always", "never"),
+	})
+	Register(Key{
+		Name:        "list.sort",
+		Default:     "id",
+		Description: "Order of listed items: id, or rank for the order set with todo move --before/--after, top and bottom",
+		Validate:    OneOf("id", "rank"),
+	})
+	Register(Key{
+		Name:        "add.duplicates",
+		Default:     "warn",
+		Description: "What todo -a does when the title is like the title of an open item: warn, refuse or off",
+		Validate:    OneOf("warn", "refuse", "off"),
+	})
+	Register(Key{
+		Name:        "dedupe.similarity",
+		Default:     "85",
+		Description: "How similar in percent titles have to be to count as duplicates, for todo -a and todo dedupe",
+		Validate:    Percent,
+	})
+	Register(Key{
+		Name:        "plan.capacity",
+		Default:     "",
+		Description: "Work todo plan fills when --capacity is not given, hours (30h) or points (20pt)",
+		Validate:    Estimate,
+	})
+	Register(Key{
+		Name:        "backup.retention",
+		Default:     "0",
+		Description: "Number of automatic backups kept when the database is saved, 0 disables them",
+		Validate:    NonNegativeInt,
+	})
+	Register(Key{
+		Name:        "trash.retention_days",
+		Default:     "30",
+		Description: "Days deleted items are kept in the trash, 0 keeps them until the trash is emptied",
+		Validate:    NonNegativeInt,
+	})
+	Register(Key{
+		Name:        "crypto.key_file",
+		Default:     "",
+		Description: "File holding the passphrase of an encrypted database (TODO_PASSPHRASE takes precedence)",
+	})
+	Register(Key{
+		Name:        "workflow.states",
+		Default:     "todo,in_progress,review,done",
+		Description: "Comma separated states items move through, new items start in the first, done items are in the last",
+		Validate:    NotEmpty,
+	})
+	Register(Key{
+		Name:        "workflow.transitions",
+		Default:     "",
+		Description: "Comma separated allowed moves between states written as from>to (* for any state), empty allows all",
+	})
+	Register(Key{
+		Name:        "history.git",
+		Default:     "false",
+		Description: "Commit the database on every change to a git repository of its own next to it (<db>.git): true or false",
+		Validate:    OneOf("true", "false"),
+	})
+	Register(Key{
+		Name:        "hooks.dir",
+		Default:     "",
+		Description: "Directory with the hooks run when items change, empty for the hooks directory next to the database outside of workspaces",
+		Trusted:     true,
+	})
+	Register(Key{
+		Name:        "templates.dir",
+		Default:     "",
+		Description: "Directory with the item templates, empty for the templates directory next to the database",
+	})
+	Register(Key{
+		Name:        "open.command",
+		Default:     "",
+		Description: "Shell command todo open runs with the url or file as its last argument, empty for xdg-open (open on macOS)",
+		Trusted:     true,
+	})
+	Register(Key{
+		Name:        "remind.command",
+		Default:     "",
+		Description: "Shell command run for every reminder, empty to write reminders to remind.log",
+		Trusted:     true,
+	})
+	Register(Key{
+		Name:        "remind.log",
+		Default:     "",
+		Description: "File reminders are appended to when remind.command is empty, empty for stdout",
+	})
+	Register(Key{
+		Name:        "remind.interval",
+		Default:     "1m",
+		Description: "How often todo remind --daemon checks for due items",
+		Validate:    Duration,
+	})
+	Register(Key{
+		Name:        "remind.default_offset",
+		Default:     "0",
+		Description: "How long before the due date items without remind_before get an early reminder, 0 for none",
+		Validate:    Duration,
+	})
+}
+
+// Register adds a setting to the registry.  It is meant to be called from
+// init functions, registering the same key twice panics.
+func Register(key Key) {
+	if _, exists := keys[key.Name]; exists {
+		panic("config: key registered twice: " + key.Name)
+	}
+	keys[key.Name] = key
+}
+
+// Keys returns the sorted names of all known settings
+func Keys() []string {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the description of a setting
+func Lookup(name string) (Key, bool) {
+	key, ok := keys[name]
+	return key, ok
+}
+
+// Validate checks that name is a known setting and that value is valid
+// for it
+func Validate(name string, value string) error {
+	key, ok := keys[name]
+	if !ok {
+		return fmt.Errorf("unknown config key %q", name)
+	}
+	if key.Validate != nil {
+		if err := key.Validate(value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// EnvName returns the environment variable that overrides a setting
+func EnvName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+}
+
+// Config holds the effective value of every setting
+type Config struct {
+	values   map[string]string
+	sources  map[string]Source
+	lenient  bool
+	warnings []error
+}
+
+// Options tells Load where to find the layers of the configuration
+type Options struct {
+	// GlobalFile is the global config file, see GlobalFile
+	GlobalFile string
+
+	// WorkspaceFile is the config file of the current workspace, it is
+	// skipped if empty
+	WorkspaceFile string
+
+	// Flags holds the settings given on the command line, only flags that
+	// were actually set should be included
+	Flags map[string]string
+
+	// Lenient skips the config files, unknown keys and invalid values Load
+	// would fail on, they are reported by Warnings instead.  The commands
+	// that repair the configuration need this.
+	Lenient bool
+}
+
+// Load builds the effective configuration from all layers.  Missing
+// config files are fine, but unknown keys or invalid values in any layer
+// are reported as errors.
+func Load(opts Options) (*Config, error) {
+	c := &Config{
+		values:  make(map[string]string),
+		sources: make(map[string]Source),
+		lenient: opts.Lenient,
+	}
+	for name, key := range keys {
+		c.values[name] = key.Default
+		c.sources[name] = FromDefault
+	}
+
+	files := []struct {
+		path   string
+		source Source
+	}{
+		{opts.GlobalFile, FromGlobal},
+		{opts.WorkspaceFile, FromWorkspace},
+	}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		values, err := ReadFile(f.path)
+		if err != nil && c.lenient {
+			c.warnings = append(c.warnings, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := c.apply(values, f.source, f.path); err != nil {
+			return nil, err
+		}
+	}
+
+	envValues := make(map[string]string)
+	for name := range keys {
+		if value, ok := os.LookupEnv(EnvName(name)); ok {
+			envValues[name] = value
+		}
+	}
+	if err := c.apply(envValues, FromEnv, "environment"); err != nil {
+		return nil, err
+	}
+
+	if err := c.apply(opts.Flags, FromFlag, "command line"); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Get returns the value of a setting, or an empty string for unknown keys
+func (c *Config) Get(name string) string {
+	return c.values[name]
+}
+
+// Int returns the value of a setting as an int.  Values are validated when
+// they are loaded, so this only fails (returning 0) for keys that are not
+// numeric settings.
+func (c *Config) Int(name string) int {
+	n, _ := strconv.Atoi(c.values[name])
+	return n
+}
+
+// Duration returns the value of a setting as a duration, see Int
+func (c *Config) Duration(name string) time.Duration {
+	d, _ := duration.Parse(c.values[name])
+	return d
+}
+
+// Source returns the layer the value of a setting was taken from
+func (c *Config) Source(name string) Source {
+	return c.sources[name]
+}
+
+// Warnings returns the problems a lenient Load skipped
+func (c *Config) Warnings() []error {
+	return c.warnings
+}
+
+func (c *Config) apply(values map[string]string, source Source, origin string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := values[name]
+		err := Validate(name, value)
+		if err == nil && source == FromWorkspace && keys[name].Trusted {
+			err = fmt.Errorf("%s can only be set in the global config", name)
+		}
+		if err != nil && c.lenient {
+			c.warnings = append(c.warnings, fmt.Errorf("%s: %w, it is ignored", origin, err))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", origin, err)
+		}
+		c.values[name] = value
+		c.sources[name] = source
+	}
+	return nil
+}
+
+//------------------------------------------------------------
+// CONFIG FILES
+//------------------------------------------------------------
+
+// GlobalFile returns the path of the global config file, honoring
+// $XDG_CONFIG_HOME
+func GlobalFile() (string, error) {
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return filepath.Join(configHome, "todo", FileName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.New("cannot find the global config file: " + err.Error())
+	}
+	return filepath.Join(home, ".config", "todo", FileName), nil
+}
+
+// ReadFile reads a config file and returns its settings keyed by their
+// dotted names.  A missing file has no settings.
+func ReadFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	//Decode into nodes to keep the text of the values, yaml would turn an
+	//unquoted 2006-01-02 into a time and 1e3 into a number
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if len(doc.Content) == 0 {
+		return values, nil
+	}
+	if err := flatten("", doc.Content[0], values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// SetInFile validates a setting and stores it in a config file, creating
+// the file and its directory if needed.  An empty value removes the
+// setting from the file, even one that is not a known key.
+func SetInFile(path string, name string, value string) error {
+	if value != "" {
+		if err := Validate(name, value); err != nil {
+			return err
+		}
+	}
+
+	values, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		//Unknown keys can be removed, they are what breaks a config file
+		if _, ok := values[name]; !ok {
+			if _, ok := keys[name]; !ok {
+				return fmt.Errorf("unknown config key %q", name)
+			}
+		}
+		delete(values, name)
+	} else {
+		values[name] = value
+	}
+
+	tree := make(map[string]interface{})
+	for k, v := range values {
+		node := tree
+		parts := strings.Split(k, ".")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = v
+	}
+
+	data, err := yaml.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// flatten turns the nested maps of a config file into dotted keys, the
+// values are kept as they are written
+func flatten(prefix string, node *yaml.Node, values map[string]string) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	switch {
+	case node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name := node.Content[i].Value
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			if err := flatten(name, node.Content[i+1], values); err != nil {
+				return err
+			}
+		}
+	case prefix == "":
+		return errors.New("the config file is not a map of settings")
+	case node.Kind == yaml.SequenceNode:
+		return fmt.Errorf("%s: lists are not supported, use a comma separated string", prefix)
+	case node.Tag == "!!null":
+		values[prefix] = ""
+	default:
+		values[prefix] = node.Value
+	}
+	return nil
+}
+
+//------------------------------------------------------------
+// VALIDATORS
+//------------------------------------------------------------
+
+// OneOf returns a validator that accepts only the given values
+func OneOf(allowed ...string) func(string) error {
+	return func(value string) error {
+		for _, a := range allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", value, strings.Join(allowed, ", "))
+	}
+}
+
+// NotEmpty rejects empty values
+func NotEmpty(value string) error {
+	if value == "" {
+		return errors.New("value must not be empty")
+	}
+	return nil
+}
+
+// UserName accepts a single word without @, or the empty value
+func UserName(value string) error {
+	if strings.ContainsAny(value, " \t\r\n@") {
+		return fmt.Errorf("%q is not a single word without @", value)
+	}
+	return nil
+}
+
+// Percent accepts whole numbers from 0 to 100
+func Percent(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 100 {
+		return fmt.Errorf("%q is not a whole number from 0 to 100", value)
+	}
+	return nil
+}
+
+// Estimate accepts empty values and estimates such as 30h or 20pt
+func Estimate(value string) error {
+	if value == "" {
+		return nil
+	}
+	_, err := db.ParseEstimate(value)
+	return err
+}
+
+// NonNegativeInt accepts whole numbers >= 0
+func NonNegativeInt(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%q is not a whole number >= 0", value)
+	}
+	return nil
+}
+
+// Duration accepts durations such as 30m, 2h, 3d or 1w
+func Duration(value string) error {
+	d, err := duration.Parse(value)
+	if err != nil {
+		return err
+	}
+	if d < 0 {
+		return fmt.Errorf("%q is a negative duration", value)
+	}
+	return nil
+}
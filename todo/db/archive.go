@@ -0,0 +1,131 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+const archiveSuffix = ".archive"
+
+// ArchiveEntry is a completed item in the archive, together with the time
+// it was archived
+type ArchiveEntry struct {
+	Item       ToDoItem  `json:"item"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+// Archive moves completed items from the DB into the archive file, which
+// keeps them out of the way of the open items but still searchable.  Only
+// items completed at least olderThan ago are moved, 0 moves every done
+// item.  Items completed before the completion time was recorded
+// (DoneAt is not set) count as completed long ago.  It returns the ids of
+// the archived items.
+func (t *ToDo) Archive(olderThan time.Duration) ([]int, error) {
+	if err := t.loadDB(); err != nil {
+		return nil, err
+	}
+	entries, err := t.ArchivedItems()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	cutoff := now.Add(-olderThan)
+
+	var archived []int
+	for _, item := range t.toDoMap {
+		if !item.IsDone || (item.DoneAt != nil && item.DoneAt.After(cutoff)) {
+			continue
+		}
+		entries = append(entries, ArchiveEntry{Item: item, ArchivedAt: now})
+		archived = append(archived, item.Id)
+	}
+	if len(archived) == 0 {
+		return nil, nil
+	}
+
+	//Write the archive first, if saving the DB fails afterwards the items
+	//are in both files, but nothing is lost
+	if err := t.writeSideFile(t.dbFileName+archiveSuffix, entries); err != nil {
+		return nil, err
+	}
+	removed := make([]ToDoItem, 0, len(archived))
+	for _, id := range archived {
+		removed = append(removed, t.toDoMap[id])
+		delete(t.toDoMap, id)
+	}
+	if err := t.saveDB(); err != nil {
+		return nil, err
+	}
+
+	for i := range removed {
+		if err := t.recordChange(OpArchive, &removed[i], nil); err != nil {
+			return nil, err
+		}
+	}
+	return archived, nil
+}
+
+// ArchivedItems returns every item in the archive, in the order they were
+// archived
+func (t *ToDo) ArchivedItems() ([]ArchiveEntry, error) {
+	var entries []ArchiveEntry
+	if err := t.readSideFile(t.dbFileName+archiveSuffix, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SearchArchive returns the archived items whose title contains query,
+// ignoring case
+func (t *ToDo) SearchArchive(query string) ([]ArchiveEntry, error) {
+	entries, err := t.ArchivedItems()
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	var found []ArchiveEntry
+	for _, entry := range entries {
+		if strings.Contains(strings.ToLower(entry.Item.Title), query) {
+			found = append(found, entry)
+		}
+	}
+	return found, nil
+}
+
+//------------------------------------------------------------
+// SIDE FILES
+//------------------------------------------------------------
+
+// The archive and the trash are kept in json files next to the DB file.
+// They are encrypted whenever the DB is.
+
+// readSideFile reads a json side file into v, a missing file is treated
+// like an empty one
+func (t *ToDo) readSideFile(fileName string, v interface{}) error {
+	data, err := t.readFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeSideFile stores v as json in a side file
+func (t *ToDo) writeSideFile(fileName string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data, err = t.encode(data)
+	if err != nil {
+		return err
+	}
+	return t.writeFile(fileName, data)
+}
@@ -0,0 +1,285 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Attached files are copied into a directory next to the DB file, named
+// after the DB file with attachmentsSuffix.  Every file is stored once,
+// under the sha256 hash of its contents (ab/ab12...), no matter how many
+// items it is attached to.  The files are encrypted whenever the DB is.
+// A file is removed once no item in the DB, the trash or the archive
+// refers to it any more, see CleanAttachments.
+const attachmentsSuffix = ".attachments"
+
+// MaxAttachmentSize is the size of the largest file that can be attached,
+// larger files can be linked with AttachPath
+const MaxAttachmentSize = 10 << 20
+
+// The kinds of attachments
+const (
+	AttachmentURL  = "url"
+	AttachmentPath = "path"
+	AttachmentFile = "file"
+)
+
+// Attachment is a link or a file attached to an item.  For urls and paths
+// Target is the url or the absolute path, they are only links.  Files are
+// copied into the DB, Name is the name of the original file and Hash the
+// sha256 hash of its contents.
+type Attachment struct {
+	Kind    string    `json:"kind"`
+	Target  string    `json:"target,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Hash    string    `json:"hash,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// String describes the attachment, the url, the path or the name of the
+// file
+func (a Attachment) String() string {
+	if a.Kind == AttachmentFile {
+		return a.Name
+	}
+	return a.Target
+}
+
+// AttachURL attaches a link to a web page (or any other url with a
+// scheme) to an item
+func (t *ToDo) AttachURL(id int, rawURL string) (Attachment, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || len(u.Scheme) < 2 || (u.Host == "" && u.Opaque == "") {
+		return Attachment{}, fmt.Errorf("%q is not a url such as https://example.com.", rawURL)
+	}
+	return t.attach(id, Attachment{Kind: AttachmentURL, Target: rawURL})
+}
+
+// AttachPath attaches a link to a local file or directory to an item, the
+// file is not copied
+func (t *ToDo) AttachPath(id int, path string) (Attachment, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return Attachment{}, err
+	}
+	return t.attach(id, Attachment{Kind: AttachmentPath, Target: abs})
+}
+
+// AttachFile copies a file of up to MaxAttachmentSize bytes into the DB and
+// attaches it to an item
+func (t *ToDo) AttachFile(id int, path string) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if _, err := t.GetItem(id); err != nil {
+		return Attachment{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return Attachment{}, fmt.Errorf("%s is not a regular file, attach it as a path instead.", path)
+	}
+	if info.Size() > MaxAttachmentSize {
+		return Attachment{}, fmt.Errorf("%s is larger than %d MB, attach it as a path instead.", path, MaxAttachmentSize>>20)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	a := Attachment{Kind: AttachmentFile, Name: filepath.Base(path), Hash: hex.EncodeToString(sum[:]), Size: int64(len(data))}
+	if err := t.writeBlob(a.Hash, data); err != nil {
+		return Attachment{}, err
+	}
+	return t.attach(id, a)
+}
+
+// attach adds an attachment to an item, unless the item already has the
+// same link or file
+func (t *ToDo) attach(id int, a Attachment) (Attachment, error) {
+	item, err := t.GetItem(id)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if hasAttachment(item.Attachments, a) {
+		return Attachment{}, fmt.Errorf("%s is already attached to item %d.", a, id)
+	}
+	a.AddedAt = time.Now().UTC()
+	item.Attachments = append(append([]Attachment(nil), item.Attachments...), a)
+	if err := t.UpdateItem(item); err != nil {
+		return Attachment{}, err
+	}
+	return a, nil
+}
+
+func hasAttachment(attachments []Attachment, a Attachment) bool {
+	for _, other := range attachments {
+		if other.Kind == a.Kind && other.Target == a.Target && other.Hash == a.Hash {
+			return true
+		}
+	}
+	return false
+}
+
+// Detach removes the n-th attachment (counting from 1) from an item and
+// returns it.  The copy of an attached file is removed when no other item
+// refers to it.
+func (t *ToDo) Detach(id int, n int) (Attachment, error) {
+	item, err := t.GetItem(id)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if n < 1 || n > len(item.Attachments) {
+		return Attachment{}, fmt.Errorf("Item %d has no attachment %d.", id, n)
+	}
+	a := item.Attachments[n-1]
+	var attachments []Attachment
+	attachments = append(attachments, item.Attachments[:n-1]...)
+	attachments = append(attachments, item.Attachments[n:]...)
+	item.Attachments = attachments
+	if err := t.UpdateItem(item); err != nil {
+		return Attachment{}, err
+	}
+	if a.Kind == AttachmentFile {
+		if _, err := t.CleanAttachments(); err != nil {
+			return a, err
+		}
+	}
+	return a, nil
+}
+
+// ReadAttachment returns the contents of an attached file
+func (t *ToDo) ReadAttachment(a Attachment) ([]byte, error) {
+	if a.Kind != AttachmentFile {
+		return nil, fmt.Errorf("%s is a link, not an attached file.", a)
+	}
+	if !validHash(a.Hash) {
+		return nil, fmt.Errorf("The attached file %s has an invalid hash %q.", a.Name, a.Hash)
+	}
+	data, err := t.readFile(t.blobFile(a.Hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("The copy of the attached file %s is missing.", a.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != a.Hash {
+		return nil, fmt.Errorf("The copy of the attached file %s is damaged.", a.Name)
+	}
+	return data, nil
+}
+
+// CleanAttachments removes the copies of attached files that no item in
+// the DB, the trash or the archive refers to and returns their hashes
+func (t *ToDo) CleanAttachments() ([]string, error) {
+	blobs, err := t.blobs()
+	if err != nil || len(blobs) == 0 {
+		return nil, err
+	}
+
+	if err := t.loadDB(); err != nil {
+		return nil, err
+	}
+	items := make([]ToDoItem, 0, len(t.toDoMap))
+	for _, item := range t.toDoMap {
+		items = append(items, item)
+	}
+	//Read the trash without dropping expired entries, loadTrash cleans
+	//up by itself when it does
+	var trash []TrashEntry
+	if err := t.readSideFile(t.dbFileName+trashSuffix, &trash); err != nil {
+		return nil, err
+	}
+	for _, entry := range trash {
+		items = append(items, entry.Item)
+	}
+	archived, err := t.ArchivedItems()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range archived {
+		items = append(items, entry.Item)
+	}
+
+	used := make(map[string]bool)
+	for _, item := range items {
+		for _, a := range item.Attachments {
+			if a.Kind == AttachmentFile {
+				used[a.Hash] = true
+			}
+		}
+	}
+	var removed []string
+	for _, hash := range blobs {
+		if used[hash] {
+			continue
+		}
+		if err := os.Remove(t.blobFile(hash)); err != nil {
+			return removed, err
+		}
+		//Only succeeds once the directory is empty
+		os.Remove(filepath.Dir(t.blobFile(hash)))
+		removed = append(removed, hash)
+	}
+	return removed, nil
+}
+
+// AttachmentsDir returns the directory holding the copies of attached
+// files
+func (t *ToDo) AttachmentsDir() string {
+	return t.dbFileName + attachmentsSuffix
+}
+
+func (t *ToDo) blobFile(hash string) string {
+	return filepath.Join(t.AttachmentsDir(), hash[:2], hash)
+}
+
+// writeBlob stores the contents of an attached file, unless a file with
+// the same contents is already stored
+func (t *ToDo) writeBlob(hash string, data []byte) error {
+	fileName := t.blobFile(hash)
+	if _, err := os.Stat(fileName); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	data, err := t.encode(data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fileName, data, t.filePerm())
+}
+
+// blobs returns the hashes of the stored files, sorted
+func (t *ToDo) blobs() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(t.AttachmentsDir(), "??", "*"))
+	if err != nil {
+		return nil, err
+	}
+	var hashes []string
+	for _, file := range files {
+		hash := filepath.Base(file)
+		if validHash(hash) && hash[:2] == filepath.Base(filepath.Dir(file)) {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
+
+func validHash(hash string) bool {
+	_, err := hex.DecodeString(hash)
+	return err == nil && len(hash) == 2*sha256.Size
+}
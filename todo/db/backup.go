@@ -0,0 +1,80 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	backupDirSuffix = ".backups"
+	backupExt       = ".bak"
+
+	// Backup names start with a sortable timestamp, so sorting the names
+	// sorts the backups from oldest to newest
+	backupTimeLayout = "20060102T150405.000000000"
+)
+
+// Backups returns the automatic backups of the DB file, oldest first.
+// See Options.BackupRetention.
+func (t *ToDo) Backups() ([]string, error) {
+	dir := t.dbFileName + backupDirSuffix
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), backupExt) {
+			backups = append(backups, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// backupDB copies the current DB file into the <db>.backups directory
+// before it is overwritten, and removes the oldest backups so that no
+// more than Options.BackupRetention are kept.  It does nothing if
+// automatic backups are turned off or the DB file does not exist yet.
+func (t *ToDo) backupDB() error {
+	if t.opts.BackupRetention <= 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(t.dbFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	dir := t.dbFileName + backupDirSuffix
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := time.Now().UTC().Format(backupTimeLayout) + backupExt
+	if err := t.writeFile(filepath.Join(dir, name), data); err != nil {
+		return err
+	}
+
+	backups, err := t.Backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > t.opts.BackupRetention {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package db
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted DB files start with a header that holds everything needed to
+// derive the key from the passphrase, followed by the AES-256-GCM sealed
+// json.  The whole header is authenticated as additional data, so any
+// change to the file is detected when it is decrypted.
+//
+//	magic (8) | log2(N) (1) | r (1) | p (1) | salt (16) | nonce (12) | ciphertext
+const (
+	encMagic     = "TODOENC1"
+	encSaltSize  = 16
+	encKeySize   = 32
+	encHeaderLen = len(encMagic) + 3 + encSaltSize
+
+	// scrypt parameters for new files, see the scrypt package for the
+	// recommended values
+	encLogN = 15
+	encR    = 8
+	encP    = 1
+)
+
+var (
+	// ErrNoPassphrase is returned when an encrypted DB is opened without
+	// Options.Passphrase
+	ErrNoPassphrase = errors.New("The database is encrypted, but no passphrase was provided.")
+
+	// ErrWrongPassphrase is returned when an encrypted file cannot be
+	// decrypted, either because the passphrase is wrong or because the
+	// file was modified
+	ErrWrongPassphrase = errors.New("Couldn't decrypt the database. Wrong passphrase or corrupted file.")
+)
+
+// IsEncrypted reports whether the DB file is stored encrypted
+func (t *ToDo) IsEncrypted() bool {
+	return t.encrypted
+}
+
+// Encrypt converts the DB file, its backup file (the .bak file used by
+// RestoreDB), the automatic backups, the archive, the trash, the attached
+// files and the history to encrypted files.  The passphrase is taken from
+// Options.Passphrase.  All later saves keep the DB encrypted, and the
+// files of an encrypted DB are only readable by their owner.  A DB with a
+// git history (see Options.GitHistory) can't be encrypted, its commits
+// can't be changed; the history starts again after the encryption, with
+// encrypted commits whose messages only name the ids of the items.
+func (t *ToDo) Encrypt() error {
+	if t.encrypted {
+		return errors.New("The database is already encrypted.")
+	}
+	if t.log != nil {
+		return errors.New("The log format doesn't support encryption, convert the database to json first.")
+	}
+	//The commits of the git history hold the items unencrypted
+	if _, err := os.Stat(t.dbFileName + gitSuffix); err == nil {
+		return fmt.Errorf("The git history in %s holds the items unencrypted. Remove it before encrypting the database.", t.dbFileName+gitSuffix)
+	}
+	return t.convertFiles(true)
+}
+
+// Decrypt converts every file converted by Encrypt back to plain json
+func (t *ToDo) Decrypt() error {
+	if !t.encrypted {
+		return errors.New("The database is not encrypted.")
+	}
+	return t.convertFiles(false)
+}
+
+// convertFiles rewrites the DB file and all of its side files in the
+// requested format.  Every file is read before anything is written, so a wrong
+// passphrase leaves all files untouched.
+func (t *ToDo) convertFiles(encrypt bool) error {
+	files := []string{t.dbFileName}
+	for _, suffix := range []string{".bak", archiveSuffix, trashSuffix} {
+		if _, err := os.Stat(t.dbFileName + suffix); err == nil {
+			files = append(files, t.dbFileName+suffix)
+		}
+	}
+	backups, err := t.Backups()
+	if err != nil {
+		return err
+	}
+	files = append(files, backups...)
+	blobs, err := t.blobs()
+	if err != nil {
+		return err
+	}
+	for _, hash := range blobs {
+		files = append(files, t.blobFile(hash))
+	}
+
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		data, err := t.readFile(file)
+		if err != nil {
+			return err
+		}
+		contents[i] = data
+	}
+	history, err := t.History()
+	if err != nil {
+		return err
+	}
+
+	t.encrypted = encrypt
+	for i, file := range files {
+		data, err := t.encode(contents[i])
+		if err != nil {
+			return err
+		}
+		if err := t.writeFile(file, data); err != nil {
+			return err
+		}
+	}
+	return t.rewriteHistory(history)
+}
+
+// filePerm is the permission of the files of the DB, only the owner can
+// read the files of an encrypted DB
+func (t *ToDo) filePerm() os.FileMode {
+	if t.encrypted {
+		return 0600
+	}
+	return 0644
+}
+
+// writeFile writes a file of the DB with the permission of the files of
+// the DB, which an existing file gets as well
+func (t *ToDo) writeFile(fileName string, data []byte) error {
+	if err := os.WriteFile(fileName, data, t.filePerm()); err != nil {
+		return err
+	}
+	return os.Chmod(fileName, t.filePerm())
+}
+
+// readFile reads a DB (or backup) file and decrypts it if needed
+func (t *ToDo) readFile(fileName string) ([]byte, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if !isEncrypted(data) {
+		return data, nil
+	}
+	return t.decrypt(data)
+}
+
+// encode returns the bytes that have to be written to disk for the json
+// data of the DB, which is the data itself for plain DBs
+func (t *ToDo) encode(data []byte) ([]byte, error) {
+	if !t.encrypted {
+		return data, nil
+	}
+	return t.encryptData(data)
+}
+
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encMagic))
+}
+
+func (t *ToDo) decrypt(data []byte) ([]byte, error) {
+	if len(data) < encHeaderLen {
+		return nil, ErrWrongPassphrase
+	}
+	header := data[:encHeaderLen]
+	params := header[len(encMagic) : len(encMagic)+3]
+	salt := header[len(encMagic)+3:]
+
+	gcm, err := t.cipherFor(salt, int(params[0]), int(params[1]), int(params[2]))
+	if err != nil {
+		return nil, err
+	}
+
+	rest := data[encHeaderLen:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	nonce, ciphertext := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+func (t *ToDo) encryptData(plain []byte) ([]byte, error) {
+	//Reuse the salt (and so the key) of the last file we read or wrote,
+	//deriving a key is slow on purpose.  Every save gets a fresh nonce.
+	salt := t.salt
+	if salt == nil {
+		salt = make([]byte, encSaltSize)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return nil, err
+		}
+	}
+	gcm, err := t.cipherFor(salt, encLogN, encR, encP)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, encHeaderLen)
+	header = append(header, encMagic...)
+	header = append(header, encLogN, encR, encP)
+	header = append(header, salt...)
+
+	out := append(header, nonce...)
+	return gcm.Seal(out, nonce, plain, header), nil
+}
+
+// cipherFor returns the AES-GCM cipher for the given salt and scrypt
+// parameters.  The derived key is cached, so the passphrase is only asked
+// for (and the key only derived) once as long as the salt does not change.
+func (t *ToDo) cipherFor(salt []byte, logN, r, p int) (cipher.AEAD, error) {
+	if t.key == nil || !bytes.Equal(t.salt, salt) {
+		if t.opts.Passphrase == nil {
+			return nil, ErrNoPassphrase
+		}
+		if t.passphrase == "" {
+			passphrase, err := t.opts.Passphrase()
+			if err != nil {
+				return nil, err
+			}
+			if passphrase == "" {
+				return nil, ErrNoPassphrase
+			}
+			t.passphrase = passphrase
+		}
+		if logN < 10 || logN > 30 {
+			return nil, ErrWrongPassphrase
+		}
+		key, err := scrypt.Key([]byte(t.passphrase), salt, 1<<logN, r, p, encKeySize)
+		if err != nil {
+			return nil, err
+		}
+		t.key = key
+		t.salt = append([]byte(nil), salt...)
+	}
+
+	block, err := aes.NewCipher(t.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,361 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ActivityMerge is the kind of the activity entry recorded on the item
+// that duplicates were merged into, Text names the merged item
+const ActivityMerge = "merge"
+
+// Similarity returns how alike two titles are, from 0 for nothing in
+// common to 1 for titles that are the same after normalizing them (case,
+// punctuation and spacing are ignored).  It is based on the edit distance
+// of the titles, with the words in their own and in sorted order, so that
+// "Buy milk and eggs" and "buy eggs and milk!" are the same.
+func Similarity(a, b string) float64 {
+	na, nb := normalizeTitle(a), normalizeTitle(b)
+	if na == nb {
+		return 1
+	}
+	s := ratio(na, nb)
+	if sorted := ratio(sortWords(na), sortWords(nb)); sorted > s {
+		s = sorted
+	}
+	return s
+}
+
+// Duplicates returns the open items whose titles are at least threshold
+// similar (see Similarity) to a title, the most similar first
+func (t *ToDo) Duplicates(title string, threshold float64) ([]ToDoItem, error) {
+	if err := t.loadDB(); err != nil {
+		return nil, err
+	}
+	scores := make(map[int]float64)
+	var found []ToDoItem
+	for _, item := range t.toDoMap {
+		if item.IsDone || !couldBeSimilar(title, item.Title, threshold) {
+			continue
+		}
+		if s := Similarity(title, item.Title); s >= threshold {
+			scores[item.Id] = s
+			found = append(found, item)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if scores[a.Id] != scores[b.Id] {
+			return scores[a.Id] > scores[b.Id]
+		}
+		return a.Id < b.Id
+	})
+	return found, nil
+}
+
+// DuplicateClusters groups the items whose titles are at least threshold
+// similar, directly or through other items of the group.  Only open
+// items are compared unless withDone is set.  The groups and the items in
+// them are ordered by id.
+func (t *ToDo) DuplicateClusters(threshold float64, withDone bool) ([][]ToDoItem, error) {
+	if err := t.loadDB(); err != nil {
+		return nil, err
+	}
+	var items []ToDoItem
+	for _, item := range t.toDoMap {
+		if withDone || !item.IsDone {
+			items = append(items, item)
+		}
+	}
+	sortItems(items)
+
+	//Union-find over the indexes of the items
+	parent := make([]int, len(items))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range items {
+		for j := i + 1; j < len(items); j++ {
+			if find(i) == find(j) || !couldBeSimilar(items[i].Title, items[j].Title, threshold) {
+				continue
+			}
+			if Similarity(items[i].Title, items[j].Title) >= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := make(map[int][]ToDoItem)
+	var roots []int
+	for i, item := range items {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], item)
+	}
+	var clusters [][]ToDoItem
+	for _, root := range roots {
+		if len(groups[root]) > 1 {
+			clusters = append(clusters, groups[root])
+		}
+	}
+	return clusters, nil
+}
+
+// MergeDuplicates merges items into the item keep and moves them to the
+// trash.  The kept item gets the tags, notes, assignees, attachments,
+// logged time, activity and dependencies of the merged items, the
+// earliest due date and the highest priority, and the subtasks and
+// dependents of the merged items.  by is the user recorded in the
+// activity of the kept item.  Either the whole merge is saved or, if a
+// pre-hook vetoes one of its changes, nothing.
+func (t *ToDo) MergeDuplicates(keep int, others []int, by string) (ToDoItem, error) {
+	if err := t.loadDB(); err != nil {
+		return ToDoItem{}, err
+	}
+	merged, exists := t.toDoMap[keep]
+	if !exists {
+		return ToDoItem{}, fmt.Errorf("Couldn't merge items. Item %d does not exist in the map.", keep)
+	}
+	if len(others) == 0 {
+		return merged, nil
+	}
+
+	var duplicates []ToDoItem
+	for _, id := range others {
+		item, exists := t.toDoMap[id]
+		if !exists {
+			return ToDoItem{}, fmt.Errorf("Couldn't merge items. Item %d does not exist in the map.", id)
+		}
+		if id == keep || containsId(others[:len(duplicates)], id) {
+			return ToDoItem{}, fmt.Errorf("Couldn't merge items. Item %d is given twice.", id)
+		}
+		duplicates = append(duplicates, item)
+	}
+
+	for _, item := range duplicates {
+		merged = mergeDuplicate(merged, item, by)
+	}
+	sort.SliceStable(merged.TimeEntries, func(i, j int) bool {
+		return merged.TimeEntries[i].Start.Before(merged.TimeEntries[j].Start)
+	})
+	sort.SliceStable(merged.Activity, func(i, j int) bool {
+		return merged.Activity[i].At.Before(merged.Activity[j].At)
+	})
+
+	//The subtasks and the dependents of the merged items move to the
+	//kept item
+	merged.DependsOn = replaceIds(merged.DependsOn, others, 0)
+	changed := []ToDoItem{merged}
+	for _, item := range t.toDoMap {
+		if item.Id == keep || containsId(others, item.Id) {
+			continue
+		}
+		moved := containsId(others, item.ParentId)
+		if moved {
+			item.ParentId = keep
+		}
+		for _, dep := range item.DependsOn {
+			if containsId(others, dep) {
+				item.DependsOn = replaceIds(item.DependsOn, others, keep)
+				moved = true
+				break
+			}
+		}
+		if moved {
+			changed = append(changed, item)
+		}
+	}
+	sortItems(changed[1:])
+
+	//Like UpdateItems and DeleteItems, but in a single save, so that a
+	//vetoed or failed merge doesn't leave the kept item merged with items
+	//that are still there
+	before := make([]ToDoItem, len(changed))
+	for i := range changed {
+		before[i] = t.toDoMap[changed[i].Id]
+		if err := t.preHooks(&before[i], &changed[i]); err != nil {
+			return ToDoItem{}, err
+		}
+	}
+	for i := range duplicates {
+		if err := t.preHooks(&duplicates[i], nil); err != nil {
+			return ToDoItem{}, err
+		}
+	}
+
+	if err := t.moveToTrash(duplicates...); err != nil {
+		return ToDoItem{}, err
+	}
+	for _, item := range changed {
+		t.toDoMap[item.Id] = item
+	}
+	for _, id := range others {
+		delete(t.toDoMap, id)
+	}
+	if err := t.saveDB(); err != nil {
+		return ToDoItem{}, err
+	}
+
+	for i := range changed {
+		if err := t.changed(OpUpdate, &before[i], &changed[i]); err != nil {
+			return ToDoItem{}, err
+		}
+	}
+	for i := range duplicates {
+		if err := t.changed(OpDelete, &duplicates[i], nil); err != nil {
+			return ToDoItem{}, err
+		}
+	}
+	return merged, nil
+}
+
+// mergeDuplicate adds what item has to keep
+func mergeDuplicate(keep ToDoItem, item ToDoItem, by string) ToDoItem {
+	for _, tag := range item.Tags {
+		if !containsTag(keep.Tags, tag) {
+			keep.Tags = append(append([]string(nil), keep.Tags...), tag)
+		}
+	}
+	if item.Notes != "" && !strings.Contains(keep.Notes, item.Notes) {
+		if keep.Notes != "" {
+			keep.Notes += "\n\n"
+		}
+		keep.Notes += item.Notes
+	}
+	for _, assignee := range item.Assignees {
+		if !keep.IsAssignedTo(assignee) {
+			keep.Assignees = append(append([]string(nil), keep.Assignees...), assignee)
+		}
+	}
+	for _, a := range item.Attachments {
+		if !hasAttachment(keep.Attachments, a) {
+			keep.Attachments = append(append([]Attachment(nil), keep.Attachments...), a)
+		}
+	}
+	for _, dep := range item.DependsOn {
+		if dep != keep.Id && !containsId(keep.DependsOn, dep) {
+			keep.DependsOn = append(append([]int(nil), keep.DependsOn...), dep)
+		}
+	}
+	keep.TimeEntries = append(append([]TimeEntry(nil), keep.TimeEntries...), item.TimeEntries...)
+	keep.Activity = append(append([]Activity(nil), keep.Activity...), item.Activity...)
+	keep.Activity = addActivity(keep.Activity, by, ActivityMerge, fmt.Sprintf("#%d %s", item.Id, item.Title))
+
+	if item.Due != nil && (keep.Due == nil || item.Due.Before(*keep.Due)) {
+		keep.Due = item.Due
+	}
+	if item.Priority > 0 && (keep.Priority == 0 || item.Priority < keep.Priority) {
+		keep.Priority = item.Priority
+	}
+	if item.CreatedAt != nil && (keep.CreatedAt == nil || item.CreatedAt.Before(*keep.CreatedAt)) {
+		keep.CreatedAt = item.CreatedAt
+	}
+	if keep.Estimate == "" {
+		keep.Estimate = item.Estimate
+	}
+	if keep.RemindBefore == "" {
+		keep.RemindBefore = item.RemindBefore
+	}
+	if keep.Source == nil {
+		keep.Source = item.Source
+	}
+	return keep
+}
+
+// replaceIds replaces the ids of old in ids by id, which is left out if
+// it is 0 or already there
+func replaceIds(ids []int, old []int, id int) []int {
+	var replaced []int
+	for _, other := range ids {
+		if containsId(old, other) {
+			other = id
+		}
+		if other != 0 && !containsId(replaced, other) {
+			replaced = append(replaced, other)
+		}
+	}
+	return replaced
+}
+
+// normalizeTitle lower cases a title and keeps only its words, separated
+// by single spaces
+func normalizeTitle(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+func sortWords(s string) string {
+	words := strings.Fields(s)
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// couldBeSimilar rules out titles whose lengths alone make them less
+// similar than threshold, which saves computing the edit distance: at
+// least the difference of the lengths has to be inserted
+func couldBeSimilar(a, b string, threshold float64) bool {
+	la, lb := len([]rune(normalizeTitle(a))), len([]rune(normalizeTitle(b)))
+	if la > lb {
+		la, lb = lb, la
+	}
+	return lb == 0 || float64(la)/float64(lb) >= threshold
+}
+
+// ratio turns the edit distance of two strings into a similarity from 0
+// to 1
+func ratio(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein returns the number of single character insertions,
+// deletions and substitutions that turn a into b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,355 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"drexel.edu/todo/duration"
+)
+
+const corruptSuffix = ".corrupt-"
+
+// Problem is something wrong with the DB file found by Check.  Line and
+// Column give the position of the entry in the (decrypted) json, they
+// are 0 for problems that are not about a single entry.
+type Problem struct {
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Id      int    `json:"id,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("line %d, column %d: %s", p.Line, p.Column, p.Message)
+}
+
+// CheckResult is the outcome of Check.  Items holds every item that could
+// be salvaged from the file, with the problems fixed, which is what Repair
+// writes back.
+type CheckResult struct {
+	Entries  int
+	Items    []ToDoItem
+	Problems []Problem
+}
+
+// OK reports whether the file has no problems
+func (r CheckResult) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Check validates the DB file without loading it.  Unlike the other
+// functions it does not give up on the first json error, it reports
+// entries that can't be parsed, fields with the wrong type or an invalid
+// value, duplicate ids, subtasks of missing items and data after the end
+// of the list, and salvages everything else.
+func (t *ToDo) Check() (CheckResult, error) {
+	if t.log != nil {
+		return CheckResult{}, errors.New("Only DBs in the json format can be checked, the log format skips damaged records by itself.")
+	}
+	data, err := t.readFile(t.dbFileName)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	return checkData(data, t.Workflow()), nil
+}
+
+// Repair replaces the DB file with the items salvaged by Check.  The
+// original file is kept as <db>.corrupt-<time> next to it and its name is
+// returned, nothing is written if the file has no problems.  Hooks and
+// history are skipped, the repair is not a change of the items.
+func (t *ToDo) Repair() (CheckResult, string, error) {
+	result, err := t.Check()
+	if err != nil || result.OK() {
+		return result, "", err
+	}
+
+	original, err := os.ReadFile(t.dbFileName)
+	if err != nil {
+		return result, "", err
+	}
+	backup := t.dbFileName + corruptSuffix + time.Now().UTC().Format(backupTimeLayout)
+	if err := t.writeFile(backup, original); err != nil {
+		return result, "", err
+	}
+
+	t.toDoMap = make(DbMap, len(result.Items))
+	for _, item := range result.Items {
+		t.toDoMap[item.Id] = item
+	}
+	if err := t.saveDB(); err != nil {
+		return result, backup, err
+	}
+	return result, backup, nil
+}
+
+// rawEntry is an element of the top level json array and its offset
+type rawEntry struct {
+	offset int
+	data   []byte
+}
+
+func checkData(data []byte, workflow Workflow) CheckResult {
+	var result CheckResult
+	report := func(offset int, id int, format string, args ...interface{}) {
+		p := Problem{Id: id, Message: fmt.Sprintf(format, args...)}
+		if offset >= 0 {
+			p.Line, p.Column = position(data, offset)
+		}
+		result.Problems = append(result.Problems, p)
+	}
+
+	entries := splitEntries(data, report)
+	result.Entries = len(entries)
+
+	type salvaged struct {
+		item   ToDoItem
+		offset int
+	}
+	var items []salvaged
+	for _, entry := range entries {
+		item, ok := decodeEntry(entry, report)
+		if ok {
+			items = append(items, salvaged{item, entry.offset})
+		}
+	}
+
+	//Ids have to be unique and can't be negative, items that break this
+	//keep their place in the file but get new ids after the highest one
+	maxId := 0
+	for _, s := range items {
+		if s.item.Id > maxId {
+			maxId = s.item.Id
+		}
+	}
+	firstAt := make(map[int]int)
+	for i := range items {
+		s := &items[i]
+		id := s.item.Id
+		if prev, dup := firstAt[id]; dup && id >= 0 {
+			maxId++
+			line, _ := position(data, prev)
+			report(s.offset, id, "duplicate id %d, the item at line %d has it too, it gets id %d", id, line, maxId)
+			s.item.Id = maxId
+		} else if id < 0 {
+			maxId++
+			report(s.offset, id, "the item has no valid id, it gets id %d", maxId)
+			s.item.Id = maxId
+		}
+		firstAt[s.item.Id] = s.offset
+	}
+
+	for i := range items {
+		s := &items[i]
+		if s.item.Title == "" {
+			s.item.Title = fmt.Sprintf("(untitled #%d)", s.item.Id)
+			report(s.offset, s.item.Id, "the item has no title, it gets the title %q", s.item.Title)
+		}
+		if s.item.ParentId != 0 {
+			if _, exists := firstAt[s.item.ParentId]; !exists || s.item.ParentId == s.item.Id {
+				report(s.offset, s.item.Id, "parent_id %d is not an item, the item becomes a top level item", s.item.ParentId)
+				s.item.ParentId = 0
+			}
+		}
+		if s.item.Status != "" && !workflow.Has(s.item.Status) {
+			report(s.offset, s.item.Id, "status %q is not a state of the workflow, it is dropped", s.item.Status)
+			s.item.Status = ""
+		}
+		result.Items = append(result.Items, s.item)
+	}
+	sortItems(result.Items)
+
+	//Report in the order of the file, problems of the whole file last
+	sort.SliceStable(result.Problems, func(i, j int) bool {
+		pi, pj := result.Problems[i], result.Problems[j]
+		if (pi.Line == 0) != (pj.Line == 0) {
+			return pj.Line == 0
+		}
+		return pi.Line < pj.Line || (pi.Line == pj.Line && pi.Column < pj.Column)
+	})
+	return result
+}
+
+// splitEntries splits the top level json array into its elements without
+// parsing them, so that one broken element doesn't hide the others
+func splitEntries(data []byte, report func(int, int, string, ...interface{})) []rawEntry {
+	var entries []rawEntry
+
+	i := skipSpace(data, 0)
+	if i == len(data) {
+		report(-1, 0, "the file is empty, it should hold a json list")
+		return nil
+	}
+	inArray := data[i] == '['
+	if inArray {
+		i++
+	} else {
+		report(i, 0, "the file does not start with a json list")
+	}
+
+	for {
+		i = skipSpace(data, i)
+		for i < len(data) && data[i] == ',' {
+			i = skipSpace(data, i+1)
+		}
+		if i == len(data) {
+			if inArray {
+				report(-1, 0, "the list is not closed, the file may be cut off")
+			}
+			return entries
+		}
+		if data[i] == ']' && inArray {
+			if end := skipSpace(data, i+1); end < len(data) {
+				report(end, 0, "unexpected data after the end of the list")
+			}
+			return entries
+		}
+
+		end, complete := scanValue(data, i)
+		if !complete {
+			report(i, 0, "the entry is not complete, the file may be cut off")
+			return entries
+		}
+		if end == i {
+			//A stray closing bracket
+			end++
+		}
+		if data[i] == '{' {
+			entries = append(entries, rawEntry{offset: i, data: data[i:end]})
+		} else {
+			report(i, 0, "unexpected %s in the list, entries have to be json objects", quoteData(data[i:end]))
+		}
+		i = end
+	}
+}
+
+// scanValue returns the end of the json value starting at data[start],
+// matching brackets and skipping strings.  complete is false if the data
+// ends inside the value.
+func scanValue(data []byte, start int) (end int, complete bool) {
+	depth := 0
+	inString := false
+	for i := start; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+				if depth == 0 {
+					return i + 1, true
+				}
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			if depth == 0 {
+				return i, true
+			}
+			depth--
+			if depth == 0 {
+				return i + 1, true
+			}
+		case depth == 0 && (c == ',' || isSpace(c)):
+			return i, true
+		}
+	}
+	return len(data), depth == 0 && !inString
+}
+
+// decodeEntry decodes an element of the list field by field, fields that
+// can't be decoded are reported and left empty
+func decodeEntry(entry rawEntry, report func(int, int, string, ...interface{})) (ToDoItem, bool) {
+	var item ToDoItem
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(entry.data, &fields); err != nil {
+		report(entry.offset, 0, "the entry can't be parsed: %v", err)
+		return item, false
+	}
+
+	//Decode the id first, so that the other problems can name the item
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == "id") != (names[j] == "id") {
+			return names[i] == "id"
+		}
+		return names[i] < names[j]
+	})
+	//An id that is not a number counts as missing, decoding it would
+	//leave id 0 and take the place of item 0
+	if raw, ok := fields["id"]; !ok || string(raw) == "null" {
+		item.Id = -1
+	}
+	for _, name := range names {
+		single, err := json.Marshal(map[string]json.RawMessage{name: fields[name]})
+		if err != nil {
+			return item, false
+		}
+		if err := json.Unmarshal(single, &item); err != nil {
+			if name == "id" {
+				item.Id = -1
+			}
+			report(entry.offset, item.Id, "field %q has an invalid value %s, it is dropped", name, quoteData(fields[name]))
+		}
+	}
+
+	if item.RemindBefore != "" {
+		if _, err := duration.Parse(item.RemindBefore); err != nil {
+			report(entry.offset, item.Id, "remind_before %q is not a duration, it is dropped", item.RemindBefore)
+			item.RemindBefore = ""
+		}
+	}
+	if item.Rank != "" && !validRank(item.Rank) {
+		report(entry.offset, item.Id, "rank %q is not a rank, it is dropped", item.Rank)
+		item.Rank = ""
+	}
+	if item.Estimate != "" {
+		if _, err := ParseEstimate(item.Estimate); err != nil {
+			report(entry.offset, item.Id, "estimate %q is not an estimate, it is dropped", item.Estimate)
+			item.Estimate = ""
+		}
+	}
+	return item, true
+}
+
+// position converts a byte offset into a line and column, both counting
+// from 1
+func position(data []byte, offset int) (line int, column int) {
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && isSpace(data[i]) {
+		i++
+	}
+	return i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// quoteData shortens raw json for messages
+func quoteData(data []byte) string {
+	const max = 30
+	if len(data) > max {
+		return fmt.Sprintf("%q...", data[:max])
+	}
+	return fmt.Sprintf("%q", data)
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/duration"
+)
+
+// The units of estimates
+const (
+	UnitHours  = "h"
+	UnitPoints = "pt"
+)
+
+// Estimate is the effort an item is expected to take, a number of hours
+// or of (story) points
+type Estimate struct {
+	Amount float64 `json:"amount"`
+	Unit   string  `json:"unit"`
+}
+
+// ParseEstimate reads an estimate in hours, written like a duration
+// ("3h", "90m", "1h30m"), or in points ("5pt", "5p", "5 points").  A plain
+// number is a number of points.
+func ParseEstimate(s string) (Estimate, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	e := Estimate{Unit: UnitPoints}
+	var err error
+	number := s
+	for _, suffix := range []string{"points", "point", "pts", "pt", "p"} {
+		if strings.HasSuffix(s, suffix) {
+			number = strings.TrimSpace(strings.TrimSuffix(s, suffix))
+			break
+		}
+	}
+	if e.Amount, err = strconv.ParseFloat(number, 64); err != nil {
+		var d time.Duration
+		if d, err = time.ParseDuration(s); err == nil {
+			e = Estimate{Amount: d.Hours(), Unit: UnitHours}
+		}
+	}
+	if err != nil || !(e.Amount > 0) || math.IsInf(e.Amount, 0) {
+		return Estimate{}, fmt.Errorf("%q is not an estimate such as 3h, 90m or 5pt.", s)
+	}
+	return e, nil
+}
+
+// String writes the estimate the way ParseEstimate reads it, hours in
+// hours and minutes
+func (e Estimate) String() string {
+	if e.Unit == UnitHours {
+		return duration.Format(time.Duration(e.Amount * float64(time.Hour)))
+	}
+	return strconv.FormatFloat(e.Amount, 'f', -1, 64) + UnitPoints
+}
+
+// Effort returns the estimate of an item, the bool is false for items
+// without a (valid) estimate
+func (item ToDoItem) Effort() (Estimate, bool) {
+	if item.Estimate == "" {
+		return Estimate{}, false
+	}
+	e, err := ParseEstimate(item.Estimate)
+	return e, err == nil
+}
+
+// SetEstimate sets the estimate of an item, an empty estimate removes it
+func (t *ToDo) SetEstimate(id int, estimate string) error {
+	item, err := t.GetItem(id)
+	if err != nil {
+		return err
+	}
+	item.Estimate = ""
+	if estimate != "" {
+		e, err := ParseEstimate(estimate)
+		if err != nil {
+			return err
+		}
+		item.Estimate = e.String()
+	}
+	return t.UpdateItem(item)
+}
+
+// AddDependency makes the item id depend on the item on, which has to be
+// done first.  Dependencies can't form a cycle.
+func (t *ToDo) AddDependency(id int, on int) error {
+	item, err := t.GetItem(id)
+	if err != nil {
+		return err
+	}
+	if _, err := t.GetItem(on); err != nil {
+		return err
+	}
+	if id == on {
+		return fmt.Errorf("Item %d can't depend on itself.", id)
+	}
+	if containsId(item.DependsOn, on) {
+		return fmt.Errorf("Item %d already depends on item %d.", id, on)
+	}
+	if t.dependsOn(on, id) {
+		return fmt.Errorf("Item %d depends on item %d, directly or through other items.", on, id)
+	}
+	item.DependsOn = append(append([]int(nil), item.DependsOn...), on)
+	return t.UpdateItem(item)
+}
+
+// RemoveDependency undoes AddDependency
+func (t *ToDo) RemoveDependency(id int, on int) error {
+	item, err := t.GetItem(id)
+	if err != nil {
+		return err
+	}
+	if !containsId(item.DependsOn, on) {
+		return fmt.Errorf("Item %d does not depend on item %d.", id, on)
+	}
+	var dependsOn []int
+	for _, other := range item.DependsOn {
+		if other != on {
+			dependsOn = append(dependsOn, other)
+		}
+	}
+	item.DependsOn = dependsOn
+	return t.UpdateItem(item)
+}
+
+// dependsOn reports whether the item id depends on the item on, directly
+// or through other items.  The DB has to be loaded.
+func (t *ToDo) dependsOn(id int, on int) bool {
+	seen := make(map[int]bool)
+	var visit func(id int) bool
+	visit = func(id int) bool {
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+		for _, dep := range t.toDoMap[id].DependsOn {
+			if dep == on || visit(dep) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(id)
+}
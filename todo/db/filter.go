@@ -0,0 +1,209 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Filter selects the items of the bulk operations.  Every field that is
+// set has to match, the zero Filter matches all items.
+type Filter struct {
+	// Ids limits the selection to these items
+	Ids []int
+
+	// Tags are tags the items must all have
+	Tags []string
+
+	// Status is the state of the workflow the items are in
+	Status string
+
+	// Done selects only done items, Open only items that are not done
+	Done bool
+	Open bool
+
+	// OlderThan selects items completed (or, for open items, created) at
+	// least this long ago.  Items without the time count as old.
+	OlderThan time.Duration
+
+	// DueBefore selects open items that are due before this time
+	DueBefore *time.Time
+
+	// Text has to be in the title, ignoring case
+	Text string
+}
+
+// IsZero reports whether the filter matches all items
+func (f Filter) IsZero() bool {
+	return len(f.Ids) == 0 && len(f.Tags) == 0 && f.Status == "" && !f.Done && !f.Open &&
+		f.OlderThan == 0 && f.DueBefore == nil && f.Text == ""
+}
+
+// Match reports whether an item is selected by the filter at now
+func (f Filter) Match(w Workflow, item ToDoItem, now time.Time) bool {
+	if len(f.Ids) > 0 && !containsId(f.Ids, item.Id) {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !containsTag(item.Tags, tag) {
+			return false
+		}
+	}
+	if f.Status != "" && w.StatusOf(item) != f.Status {
+		return false
+	}
+	if (f.Done && !item.IsDone) || (f.Open && item.IsDone) {
+		return false
+	}
+	if f.OlderThan > 0 {
+		since := item.CreatedAt
+		if item.IsDone {
+			since = item.DoneAt
+		}
+		if since != nil && since.After(now.Add(-f.OlderThan)) {
+			return false
+		}
+	}
+	if f.DueBefore != nil && (item.IsDone || item.Due == nil || !item.Due.Before(*f.DueBefore)) {
+		return false
+	}
+	if f.Text != "" && !strings.Contains(strings.ToLower(item.Title), strings.ToLower(f.Text)) {
+		return false
+	}
+	return true
+}
+
+// Select returns the items matching the filter, ordered by id
+func (t *ToDo) Select(f Filter) ([]ToDoItem, error) {
+	if err := t.loadDB(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	w := t.Workflow()
+
+	var selected []ToDoItem
+	for _, item := range t.toDoMap {
+		if f.Match(w, item, now) {
+			selected = append(selected, item)
+		}
+	}
+	sortItems(selected)
+	return selected, nil
+}
+
+// UpdateItems updates several items at once.  Either all items are
+// updated or, if one of them doesn't exist or is vetoed by a pre-hook,
+// none.
+func (t *ToDo) UpdateItems(items []ToDoItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	if err := t.loadDB(); err != nil {
+		return err
+	}
+
+	before := make([]ToDoItem, len(items))
+	for i := range items {
+		prev, exists := t.toDoMap[items[i].Id]
+		if !exists {
+			return fmt.Errorf("Couldn't update items. Item %d does not exist in the map.", items[i].Id)
+		}
+		before[i] = prev
+	}
+	//Like AddItems, the pre-hooks only run for a valid batch
+	for i := range items {
+		if err := t.preHooks(&before[i], &items[i]); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range items {
+		t.toDoMap[item.Id] = item
+	}
+	if err := t.saveDB(); err != nil {
+		return err
+	}
+
+	for i := range items {
+		if err := t.changed(OpUpdate, &before[i], &items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteItems deletes several items at once and moves them to the trash.
+// Either all items are deleted or, if one of them doesn't exist or is
+// vetoed by a pre-delete hook, none.
+func (t *ToDo) DeleteItems(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := t.loadDB(); err != nil {
+		return err
+	}
+
+	items := make([]ToDoItem, len(ids))
+	for i, id := range ids {
+		item, exists := t.toDoMap[id]
+		if !exists {
+			return fmt.Errorf("Couldn't remove items. Item %d doesn't exist in the map.", id)
+		}
+		items[i] = item
+	}
+	for i := range items {
+		if err := t.preHooks(&items[i], nil); err != nil {
+			return err
+		}
+	}
+
+	//Like DeleteItem, save the items in the trash first so that a failure
+	//leaves them in both places instead of losing them
+	if err := t.moveToTrash(items...); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		delete(t.toDoMap, id)
+	}
+	if err := t.saveDB(); err != nil {
+		return err
+	}
+
+	for i := range items {
+		if err := t.changed(OpDelete, &items[i], nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithDone returns the item marked done (or open again), moved to the
+// last (or first) state of the workflow
+func (t *ToDo) WithDone(item ToDoItem, done bool) ToDoItem {
+	w := t.Workflow()
+	if done {
+		return setStatus(w, item, w.Terminal())
+	}
+	if item.IsDone {
+		return setStatus(w, item, w.Initial())
+	}
+	return item
+}
+
+func containsId(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
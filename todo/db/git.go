@@ -0,0 +1,406 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// gitSuffix names the repository holding the history of a DB, next to the
+// DB file
+const gitSuffix = ".git"
+
+// The author of the commits made for Options.GitHistory
+const (
+	gitAuthorName  = "todo"
+	gitAuthorEmail = "todo@localhost"
+)
+
+// maxMessageChanges is the number of changes named in a commit message,
+// commits with more changes only count the rest
+const maxMessageChanges = 3
+
+// ErrNoGitHistory is returned by the functions reading the git history of
+// a DB that is not in a git repository
+var ErrNoGitHistory = errors.New("The DB is not versioned with git. Set history.git to true to turn it on.")
+
+// Revision is a commit of the DB file
+type Revision struct {
+	Hash    string    `json:"hash"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// Short returns the abbreviated hash of the revision
+func (r Revision) Short() string {
+	if len(r.Hash) > 7 {
+		return r.Hash[:7]
+	}
+	return r.Hash
+}
+
+// ItemChange is the change of a single item between two revisions.  Before
+// is nil for added items and After is nil for deleted items.
+type ItemChange struct {
+	Action string    `json:"action"`
+	Id     int       `json:"id"`
+	Before *ToDoItem `json:"before,omitempty"`
+	After  *ToDoItem `json:"after,omitempty"`
+	Fields []string  `json:"fields,omitempty"`
+}
+
+// String describes the change the way commit messages do, for example
+// add #12 'Learn Go'
+func (c ItemChange) String() string {
+	item := c.After
+	if item == nil {
+		item = c.Before
+	}
+	s := fmt.Sprintf("%s #%d '%s'", c.Action, c.Id, item.Title)
+	if c.Action == OpUpdate && len(c.Fields) > 0 {
+		s += " (" + strings.Join(c.Fields, ", ") + ")"
+	}
+	return s
+}
+
+// Revisions returns the commits of the DB file, newest first
+func (t *ToDo) Revisions() ([]Revision, error) {
+	repo, err := t.openRepo()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := repo.Log(&git.LogOptions{})
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []Revision
+	err = commits.ForEach(func(c *object.Commit) error {
+		revisions = append(revisions, revisionOf(c))
+		return nil
+	})
+	return revisions, err
+}
+
+// Revision returns a commit of the DB file and the changes of the items
+// it made.  rev is anything git understands, like a (short) hash, HEAD~2
+// or a branch name.
+func (t *ToDo) Revision(rev string) (Revision, []ItemChange, error) {
+	repo, err := t.openRepo()
+	if err != nil {
+		return Revision{}, nil, err
+	}
+	commit, err := resolveCommit(repo, rev)
+	if err != nil {
+		return Revision{}, nil, err
+	}
+	after, err := t.itemsInCommit(commit)
+	if err != nil {
+		return Revision{}, nil, err
+	}
+
+	var before []ToDoItem
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return Revision{}, nil, err
+		}
+		if before, err = t.itemsInCommit(parent); err != nil {
+			return Revision{}, nil, err
+		}
+	}
+	return revisionOf(commit), diffItems(before, after), nil
+}
+
+// ItemsAt returns the items of the DB in a revision
+func (t *ToDo) ItemsAt(rev string) ([]ToDoItem, error) {
+	repo, err := t.openRepo()
+	if err != nil {
+		return nil, err
+	}
+	commit, err := resolveCommit(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+	return t.itemsInCommit(commit)
+}
+
+// RestoreRevision replaces all items with the items of a revision.  This
+// is a change like any other, see ReplaceAllItems: it is saved as a new
+// commit, the revisions after rev are kept and items added since go to
+// the trash.
+func (t *ToDo) RestoreRevision(rev string) (Revision, error) {
+	repo, err := t.openRepo()
+	if err != nil {
+		return Revision{}, err
+	}
+	commit, err := resolveCommit(repo, rev)
+	if err != nil {
+		return Revision{}, err
+	}
+	items, err := t.itemsInCommit(commit)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	restored := revisionOf(commit)
+	t.commitMessage = "restore " + restored.Short()
+	defer func() { t.commitMessage = "" }()
+	if err := t.ReplaceAllItems(items); err != nil {
+		return Revision{}, err
+	}
+	return restored, nil
+}
+
+// commitDB commits the DB file after it was saved, with a message
+// describing the changes since the last commit.  The history is kept in a
+// bare repository of its own next to the DB file (<db>.git), which is
+// created the first time.  Every commit holds the DB file and nothing
+// else, so a DB inside the work tree of a project never commits any of
+// the project's files.
+func (t *ToDo) commitDB(items []ToDoItem) error {
+	dir := t.dbFileName + gitSuffix
+	repo, err := git.PlainOpen(dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = git.PlainInit(dir, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(t.dbFileName)
+	if err != nil {
+		return err
+	}
+	blob := repo.Storer.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	w, err := blob.Writer()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	blobHash, err := repo.Storer.SetEncodedObject(blob)
+	if err != nil {
+		return err
+	}
+	tree := object.Tree{Entries: []object.TreeEntry{{Name: filepath.Base(t.dbFileName), Mode: filemode.Regular, Hash: blobHash}}}
+	treeHash, err := storeObject(repo, &tree)
+	if err != nil {
+		return err
+	}
+
+	var parents []plumbing.Hash
+	var before []ToDoItem
+	if head, err := repo.Head(); err == nil {
+		parent, err := repo.CommitObject(head.Hash())
+		if err != nil {
+			return err
+		}
+		if parent.TreeHash == treeHash {
+			return nil
+		}
+		if before, err = t.itemsInCommit(parent); err != nil {
+			return err
+		}
+		parents = append(parents, parent.Hash)
+	} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return err
+	}
+
+	message := t.commitMessage
+	if message == "" {
+		message = commitMessage(diffItems(before, items), !t.encrypted)
+	}
+	signature := object.Signature{Name: gitAuthorName, Email: gitAuthorEmail, When: time.Now()}
+	commit := object.Commit{
+		Author:       signature,
+		Committer:    signature,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+	commitHash, err := storeObject(repo, &commit)
+	if err != nil {
+		return err
+	}
+
+	//Move the branch HEAD points to
+	head, err := repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return err
+	}
+	branch := head.Name()
+	if head.Type() == plumbing.SymbolicReference {
+		branch = head.Target()
+	}
+	return repo.Storer.SetReference(plumbing.NewHashReference(branch, commitHash))
+}
+
+// storeObject writes a tree or a commit into the repository
+func storeObject(repo *git.Repository, o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := repo.Storer.NewEncodedObject()
+	if err := o.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+func (t *ToDo) openRepo() (*git.Repository, error) {
+	repo, err := git.PlainOpen(t.dbFileName + gitSuffix)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return nil, ErrNoGitHistory
+	}
+	return repo, err
+}
+
+// itemsInCommit reads the DB file as it was in a commit
+func (t *ToDo) itemsInCommit(commit *object.Commit) ([]ToDoItem, error) {
+	file, err := commit.File(filepath.Base(t.dbFileName))
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	data := []byte(contents)
+	if isEncrypted(data) {
+		if data, err = t.decrypt(data); err != nil {
+			return nil, err
+		}
+	}
+	if isLog(data) {
+		items, err := replayLog(data)
+		if err != nil {
+			return nil, fmt.Errorf("The DB file in revision %s is damaged: %v.", commit.Hash.String()[:7], err)
+		}
+		return items, nil
+	}
+	var items []ToDoItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("The DB file in revision %s is damaged: %v.", commit.Hash.String()[:7], err)
+	}
+	return items, nil
+}
+
+func resolveCommit(repo *git.Repository, rev string) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("Unknown revision %q.", rev)
+	}
+	return repo.CommitObject(*hash)
+}
+
+func revisionOf(c *object.Commit) Revision {
+	return Revision{
+		Hash:    c.Hash.String(),
+		Time:    c.Author.When,
+		Message: strings.TrimSpace(c.Message),
+	}
+}
+
+// diffItems returns the changes from before to after, ordered by id
+func diffItems(before, after []ToDoItem) []ItemChange {
+	old := make(map[int]ToDoItem, len(before))
+	for _, item := range before {
+		old[item.Id] = item
+	}
+
+	var changes []ItemChange
+	for i := range after {
+		item := after[i]
+		prev, existed := old[item.Id]
+		delete(old, item.Id)
+		switch {
+		case !existed:
+			changes = append(changes, ItemChange{Action: OpAdd, Id: item.Id, After: &item})
+		case len(changedFields(prev, item)) == 0:
+		case !prev.IsDone && item.IsDone:
+			changes = append(changes, ItemChange{Action: "done", Id: item.Id, Before: &prev, After: &item})
+		case prev.IsDone && !item.IsDone:
+			changes = append(changes, ItemChange{Action: "reopen", Id: item.Id, Before: &prev, After: &item})
+		default:
+			changes = append(changes, ItemChange{Action: OpUpdate, Id: item.Id, Before: &prev, After: &item, Fields: changedFields(prev, item)})
+		}
+	}
+	for i := range before {
+		if item, deleted := old[before[i].Id]; deleted {
+			changes = append(changes, ItemChange{Action: OpDelete, Id: item.Id, Before: &item})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Id < changes[j].Id
+	})
+	return changes
+}
+
+// changedFields returns the json names of the fields that differ.  The
+// items are compared in their json form, items read from the file and
+// items in memory differ in details like the location of times.
+func changedFields(before, after ToDoItem) []string {
+	b, a := itemFields(before), itemFields(after)
+	var fields []string
+	itemType := reflect.TypeOf(before)
+	for i := 0; i < itemType.NumField(); i++ {
+		name, _, _ := strings.Cut(itemType.Field(i).Tag.Get("json"), ",")
+		if string(b[name]) != string(a[name]) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func itemFields(item ToDoItem) map[string]json.RawMessage {
+	var fields map[string]json.RawMessage
+	data, _ := json.Marshal(item)
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// commitMessage names the first changes, for example
+// add #12 'Learn Go', done #3 'Read the docs'.  Commit messages are not
+// encrypted, without titles they only name the ids: add #12, done #3.
+func commitMessage(changes []ItemChange, titles bool) string {
+	if len(changes) == 0 {
+		return "save"
+	}
+	var parts []string
+	for i, change := range changes {
+		if i == maxMessageChanges {
+			parts = append(parts, fmt.Sprintf("and %d more", len(changes)-i))
+			break
+		}
+		if titles {
+			parts = append(parts, change.String())
+		} else {
+			parts = append(parts, fmt.Sprintf("%s #%d", change.Action, change.Id))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
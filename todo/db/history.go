@@ -0,0 +1,139 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+const historySuffix = ".history"
+
+// The operations recorded in the history
+const (
+	OpAdd     = "add"
+	OpUpdate  = "update"
+	OpDelete  = "delete"
+	OpArchive = "archive"
+	OpRestore = "restore"
+)
+
+// HistoryEntry records a single change of an item.  Before is nil for
+// added items and After is nil for items that left the DB (deleted or
+// archived).
+type HistoryEntry struct {
+	Time   time.Time `json:"time"`
+	Op     string    `json:"op"`
+	Id     int       `json:"id"`
+	Before *ToDoItem `json:"before,omitempty"`
+	After  *ToDoItem `json:"after,omitempty"`
+}
+
+// History returns every recorded change of the DB, oldest first.  Changes
+// are recorded by all functions that modify items, in a file next to the
+// DB file with one json entry per line, so recording a change never has
+// to rewrite the file.  For encrypted DBs every line is encrypted on its
+// own.
+func (t *ToDo) History() ([]HistoryEntry, error) {
+	f, err := os.Open(t.dbFileName + historySuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	lines := bufio.NewScanner(f)
+	lines.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lines.Scan() {
+		line := bytes.TrimSpace(lines.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '{' {
+			//Encrypted entries are base64 encoded to keep them on one line
+			raw, err := base64.StdEncoding.DecodeString(string(line))
+			if err != nil {
+				return nil, err
+			}
+			if line, err = t.decrypt(raw); err != nil {
+				return nil, err
+			}
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// rewriteHistory replaces the history file with the given entries, in
+// the current (plain or encrypted) format of the DB
+func (t *ToDo) rewriteHistory(entries []HistoryEntry) error {
+	if entries == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		line, err := t.historyLine(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return t.writeFile(t.dbFileName+historySuffix, buf.Bytes())
+}
+
+// recordChange appends an entry to the history file.  It is called by
+// every function that modifies items, after the DB was saved, directly or
+// through changed (see hooks.go) for changes that run hooks.
+func (t *ToDo) recordChange(op string, before, after *ToDoItem) error {
+	entry := HistoryEntry{Time: time.Now().UTC(), Op: op, Before: before, After: after}
+	if after != nil {
+		entry.Id = after.Id
+	} else if before != nil {
+		entry.Id = before.Id
+	}
+
+	line, err := t.historyLine(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(t.dbFileName+historySuffix, os.O_APPEND|os.O_CREATE|os.O_WRONLY, t.filePerm())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// historyLine encodes a single entry of the history file
+func (t *ToDo) historyLine(entry HistoryEntry) ([]byte, error) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	if !t.encrypted {
+		return line, nil
+	}
+	sealed, err := t.encryptData(line)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+}
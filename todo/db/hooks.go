@@ -0,0 +1,139 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Hooks are executable files named after the moment and the event they
+// run for (pre-add, post-done, ...) in Options.HooksDir.  They are run
+// by AddItem, UpdateItem (and so ChangeItemDoneStatus) and DeleteItem.
+// Pre-hooks run before the DB is changed, if one exits with a non-zero
+// status the change is not made and a HookError is returned.  Post-hooks
+// run after the change was saved, their failures are only reported on
+// stderr because the change cannot be taken back.
+//
+// The events hooks can be installed for.  An item marked done is an
+// update as well, so its update hooks run before its done hooks.
+const (
+	EventAdd    = "add"
+	EventUpdate = "update"
+	EventDelete = "delete"
+	EventDone   = "done"
+)
+
+// HookError is returned when a pre-hook vetoes a change, Message is what
+// the hook printed
+type HookError struct {
+	Hook    string
+	Message string
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("The %s hook rejected the change: %s", e.Hook, e.Message)
+}
+
+// HookInput is the json a hook receives on stdin.  Before is nil for
+// added items and After is nil for deleted items.
+type HookInput struct {
+	Event  string    `json:"event"`
+	Before *ToDoItem `json:"before"`
+	After  *ToDoItem `json:"after"`
+}
+
+// preHooks runs the pre-hooks of a change and returns a HookError if
+// one of them vetoes it
+func (t *ToDo) preHooks(before, after *ToDoItem) error {
+	for _, event := range hookEvents(before, after) {
+		if err := t.runHook("pre-"+event, event, before, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changed is called by every function that modified an item after the
+// DB was saved, it records the change in the history and runs the
+// post-hooks
+func (t *ToDo) changed(op string, before, after *ToDoItem) error {
+	if err := t.recordChange(op, before, after); err != nil {
+		return err
+	}
+	for _, event := range hookEvents(before, after) {
+		if err := t.runHook("post-"+event, event, before, after); err != nil {
+			fmt.Fprintln(os.Stderr, "WARNING:", err)
+		}
+	}
+	return nil
+}
+
+// hookEvents returns the events of a change
+func hookEvents(before, after *ToDoItem) []string {
+	switch {
+	case before == nil:
+		return []string{EventAdd}
+	case after == nil:
+		return []string{EventDelete}
+	case after.IsDone && !before.IsDone:
+		return []string{EventUpdate, EventDone}
+	default:
+		return []string{EventUpdate}
+	}
+}
+
+// runHook runs a single hook if it is installed.  The output of a hook
+// goes to stderr, so it does not mix with the output of the command, the
+// output of a failing hook becomes the message of the error.
+func (t *ToDo) runHook(name, event string, before, after *ToDoItem) error {
+	if t.opts.HooksDir == "" {
+		return nil
+	}
+	path := filepath.Join(t.opts.HooksDir, name)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	//Like git, hooks that are not executable are ignored
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return nil
+	}
+
+	input, err := json.Marshal(HookInput{Event: event, Before: before, After: after})
+	if err != nil {
+		return err
+	}
+	dbFile, err := filepath.Abs(t.dbFileName)
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "TODO_EVENT="+event, "TODO_HOOK="+name, "TODO_DB="+dbFile)
+	stdout, err := cmd.Output()
+	if err == nil {
+		os.Stderr.Write(stdout)
+		os.Stderr.Write(stderr.Bytes())
+		return nil
+	}
+
+	message := strings.TrimSpace(stderr.String())
+	if message == "" {
+		message = strings.TrimSpace(string(stdout))
+	}
+	if message == "" {
+		message = err.Error()
+	}
+	return &HookError{Hook: name, Message: message}
+}
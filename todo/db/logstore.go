@@ -0,0 +1,509 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The log format is an alternative to the json array file for large DBs.
+// Instead of rewriting the whole file on every change, a change appends a
+// single line to it:
+//
+//	TODO-LOG 1 index=0000000000000120
+//	{"put":{"id":1,"title":"Learn Go","done":false}}
+//	{"put":{"id":2,"title":"Write tests","done":false}}
+//	{"index":{"1":[34,43],"2":[78,46]}}
+//	{"put":{"id":2,"title":"Write more tests","done":false}}
+//	{"del":1}
+//
+// The header holds the offset of the index, which lists the offset and
+// length of the record of every item at the time of the last compaction.
+// Opening a DB only reads the index and the records appended after it,
+// items are decoded when they are needed.  Compacting rewrites the file
+// with one record per item and a new index.  It happens when the whole DB
+// is saved (by the functions that work on all items, see saveDB) and by
+// itself once the records that were replaced by later ones outnumber the
+// items.
+//
+// An incomplete last line, left by a crash while appending, is ignored.
+// Encryption is not supported for the log format.
+const (
+	FormatJSON = "json"
+	FormatLog  = "log"
+
+	logMagic     = "TODO-LOG 1"
+	logHeader    = logMagic + " index="
+	logHeaderLen = len(logHeader) + 16 + 1
+
+	// logCompactMin is the number of replaced records that have to pile
+	// up before the log is compacted by itself
+	logCompactMin = 1000
+)
+
+// logRecord is a line of the log, exactly one of the fields is set.  Del
+// is a pointer because 0 is a valid id.
+type logRecord struct {
+	Put   *ToDoItem        `json:"put,omitempty"`
+	Del   *int             `json:"del,omitempty"`
+	Index map[int][2]int64 `json:"index,omitempty"`
+}
+
+// logRef is the position of the record holding the current version of an
+// item
+type logRef struct {
+	offset int64
+	length int64
+}
+
+// logStore reads and writes a DB file in the log format
+type logStore struct {
+	fileName string
+
+	// info and size describe the file as far as it has been read, a
+	// file with a different identity was compacted by another program
+	info os.FileInfo
+	size int64
+
+	refs  map[int]logRef
+	items map[int]ToDoItem
+	dead  int
+}
+
+func isLog(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(logMagic))
+}
+
+func openLog(fileName string) (*logStore, error) {
+	s := &logStore{fileName: fileName}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload reads the index and the records after it
+func (s *logStore) reload() error {
+	f, err := os.Open(s.fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, logHeaderLen)
+	if _, err := io.ReadFull(f, header); err != nil || !bytes.HasPrefix(header, []byte(logHeader)) {
+		return fmt.Errorf("The DB file %s is not a valid log file.", s.fileName)
+	}
+	indexAt, err := strconv.ParseInt(strings.TrimSpace(string(header[len(logHeader):])), 10, 64)
+	if err != nil || indexAt > info.Size() {
+		return fmt.Errorf("The DB file %s is not a valid log file.", s.fileName)
+	}
+
+	s.refs = make(map[int]logRef)
+	s.items = make(map[int]ToDoItem)
+	s.dead = 0
+	start := int64(logHeaderLen)
+	if indexAt > 0 {
+		line, err := bufio.NewReader(io.NewSectionReader(f, indexAt, info.Size()-indexAt)).ReadBytes('\n')
+		if err != nil {
+			return fmt.Errorf("The index of the DB file %s is damaged: %v.", s.fileName, err)
+		}
+		var record logRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("The index of the DB file %s is damaged: %v.", s.fileName, err)
+		}
+		for id, ref := range record.Index {
+			s.refs[id] = logRef{offset: ref[0], length: ref[1]}
+		}
+		start = indexAt + int64(len(line))
+	}
+
+	s.info = info
+	s.size = start
+	return s.readFrom(f, info.Size())
+}
+
+// sync reads the records appended by other programs since the file was
+// read last
+func (s *logStore) sync() error {
+	info, err := os.Stat(s.fileName)
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, s.info) || info.Size() < s.size {
+		return s.reload()
+	}
+	if info.Size() == s.size {
+		return nil
+	}
+
+	f, err := os.Open(s.fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s.info = info
+	return s.readFrom(f, info.Size())
+}
+
+// readFrom applies the complete records between s.size and end
+func (s *logStore) readFrom(f *os.File, end int64) error {
+	r := bufio.NewReader(io.NewSectionReader(f, s.size, end-s.size))
+	offset := s.size
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			//An incomplete record is left for the next read, it may
+			//still be in the middle of being written
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var record logRecord
+		if json.Unmarshal(line, &record) != nil {
+			s.dead++
+		} else if record.Put != nil {
+			if _, exists := s.refs[record.Put.Id]; exists {
+				s.dead++
+			}
+			s.refs[record.Put.Id] = logRef{offset: offset, length: int64(len(line) - 1)}
+			s.items[record.Put.Id] = *record.Put
+		} else if record.Del != nil {
+			if _, exists := s.refs[*record.Del]; exists {
+				s.dead++
+			}
+			s.dead++
+			delete(s.refs, *record.Del)
+			delete(s.items, *record.Del)
+		}
+		offset += int64(len(line))
+		s.size = offset
+	}
+}
+
+// replayLog returns the items of a log file held in memory, ordered by id
+func replayLog(data []byte) ([]ToDoItem, error) {
+	lines := bytes.Split(data, []byte("\n"))
+	if len(lines) < 2 || !bytes.HasPrefix(lines[0], []byte(logHeader)) {
+		return nil, errors.New("not a valid log file")
+	}
+	items := make(DbMap)
+	for _, line := range lines[1 : len(lines)-1] {
+		var record logRecord
+		if json.Unmarshal(line, &record) != nil {
+			continue
+		}
+		if record.Put != nil {
+			items[record.Put.Id] = *record.Put
+		} else if record.Del != nil {
+			delete(items, *record.Del)
+		}
+	}
+	list := make([]ToDoItem, 0, len(items))
+	for _, item := range items {
+		list = append(list, item)
+	}
+	sortItems(list)
+	return list, nil
+}
+
+// get returns the current version of an item
+func (s *logStore) get(id int) (ToDoItem, bool, error) {
+	if item, ok := s.items[id]; ok {
+		return item, true, nil
+	}
+	if _, ok := s.refs[id]; !ok {
+		return ToDoItem{}, false, nil
+	}
+
+	f, err := os.Open(s.fileName)
+	if err != nil {
+		return ToDoItem{}, false, err
+	}
+	defer f.Close()
+	item, err := s.read(f, id)
+	return item, err == nil, err
+}
+
+// all returns every item, the map belongs to the caller
+func (s *logStore) all() (DbMap, error) {
+	items := make(DbMap, len(s.refs))
+	var data []byte
+	for id, ref := range s.refs {
+		item, ok := s.items[id]
+		if !ok {
+			//Read the file once instead of every record on its own
+			if data == nil {
+				var err error
+				if data, err = os.ReadFile(s.fileName); err != nil {
+					return nil, err
+				}
+			}
+			if ref.offset+ref.length > int64(len(data)) {
+				return nil, fmt.Errorf("The record of item %d in the DB file %s is damaged.", id, s.fileName)
+			}
+			var err error
+			if item, err = s.decode(id, data[ref.offset:ref.offset+ref.length]); err != nil {
+				return nil, err
+			}
+		}
+		items[id] = item
+	}
+	return items, nil
+}
+
+// read reads the record of an item from the file
+func (s *logStore) read(f *os.File, id int) (ToDoItem, error) {
+	ref := s.refs[id]
+	line := make([]byte, ref.length)
+	if _, err := f.ReadAt(line, ref.offset); err != nil {
+		return ToDoItem{}, err
+	}
+	return s.decode(id, line)
+}
+
+// decode decodes the record of an item and caches the item
+func (s *logStore) decode(id int, line []byte) (ToDoItem, error) {
+	var record logRecord
+	if err := json.Unmarshal(line, &record); err != nil || record.Put == nil || record.Put.Id != id {
+		return ToDoItem{}, fmt.Errorf("The record of item %d in the DB file %s is damaged.", id, s.fileName)
+	}
+	s.items[id] = *record.Put
+	return *record.Put, nil
+}
+
+// append adds records to the end of the file
+func (s *logStore) append(records ...logRecord) error {
+	var buf bytes.Buffer
+	if info, err := os.Stat(s.fileName); err != nil {
+		return err
+	} else if info.Size() > s.size {
+		//Close the incomplete record of a crashed write, so that it
+		//stays a single damaged line instead of damaging this record
+		if err := s.sync(); err != nil {
+			return err
+		}
+		if s.info.Size() > s.size {
+			buf.WriteByte('\n')
+		}
+	}
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	f, err := os.OpenFile(s.fileName, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	//Read the records back instead of guessing their offsets, another
+	//program may have appended records in the meantime
+	return s.sync()
+}
+
+// needsCompaction reports whether the replaced records outnumber the items
+func (s *logStore) needsCompaction() bool {
+	return s.dead >= logCompactMin && s.dead > len(s.refs)
+}
+
+// compact rewrites the file with one record per item (in the given
+// order) and an index
+func (s *logStore) compact(items []ToDoItem) error {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, logHeaderLen))
+	index := make(map[int][2]int64, len(items))
+	for i := range items {
+		line, err := json.Marshal(logRecord{Put: &items[i]})
+		if err != nil {
+			return err
+		}
+		index[items[i].Id] = [2]int64{int64(buf.Len()), int64(len(line))}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	indexAt := buf.Len()
+	line, err := json.Marshal(logRecord{Index: index})
+	if err != nil {
+		return err
+	}
+	buf.Write(line)
+	buf.WriteByte('\n')
+	copy(buf.Bytes(), fmt.Sprintf("%s%016d\n", logHeader, indexAt))
+
+	if err := writeFileAtomic(s.fileName, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := s.reload(); err != nil {
+		return err
+	}
+	for _, item := range items {
+		s.items[item.Id] = item
+	}
+	return nil
+}
+
+//------------------------------------------------------------
+// THE LOG FORMAT VERSIONS OF THE ITEM FUNCTIONS
+//------------------------------------------------------------
+
+// Format returns the format of the DB file, FormatJSON or FormatLog
+func (t *ToDo) Format() string {
+	if t.log != nil {
+		return FormatLog
+	}
+	return FormatJSON
+}
+
+// Convert rewrites the DB file in another format, FormatJSON or FormatLog
+func (t *ToDo) Convert(format string) error {
+	if format != FormatJSON && format != FormatLog {
+		return fmt.Errorf("Unknown format %q, use %s or %s.", format, FormatJSON, FormatLog)
+	}
+	if format == t.Format() {
+		return nil
+	}
+	if format == FormatLog && t.encrypted {
+		return errors.New("The log format doesn't support encryption, decrypt the DB first.")
+	}
+
+	if err := t.loadDB(); err != nil {
+		return err
+	}
+	if format == FormatLog {
+		t.log = &logStore{fileName: t.dbFileName}
+	} else {
+		t.log = nil
+	}
+	return t.saveDB()
+}
+
+// Compact rewrites a DB file in the log format with only the current
+// version of every item
+func (t *ToDo) Compact() error {
+	if t.log == nil {
+		return errors.New("Only DBs in the log format can be compacted.")
+	}
+	if err := t.loadDB(); err != nil {
+		return err
+	}
+	return t.saveDB()
+}
+
+// putLog adds (op is OpAdd) or updates an item by appending a record
+func (t *ToDo) putLog(op string, item ToDoItem) error {
+	if err := t.log.sync(); err != nil {
+		return err
+	}
+	before, exists, err := t.log.get(item.Id)
+	if err != nil {
+		return err
+	}
+	if op == OpAdd && exists {
+		return errors.New("Couldn't add item. Item already exists in the map.")
+	}
+	if op == OpUpdate && !exists {
+		return errors.New("Couldn't update item. Item does not exist in the map.")
+	}
+
+	var prev *ToDoItem
+	if exists {
+		prev = &before
+	}
+	if err := t.preHooks(prev, &item); err != nil {
+		return err
+	}
+	if err := t.appendLog(logRecord{Put: &item}); err != nil {
+		return err
+	}
+	return t.changed(op, prev, &item)
+}
+
+// deleteLog deletes an item by appending a record
+func (t *ToDo) deleteLog(id int) error {
+	if err := t.log.sync(); err != nil {
+		return err
+	}
+	item, exists, err := t.log.get(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("Couldn't remove item. Item doesn't exist in the map.")
+	}
+	if err := t.preHooks(&item, nil); err != nil {
+		return err
+	}
+	if err := t.moveToTrash(item); err != nil {
+		return err
+	}
+	if err := t.appendLog(logRecord{Del: &id}); err != nil {
+		return err
+	}
+	return t.changed(OpDelete, &item, nil)
+}
+
+// getLog returns an item without reading the other items
+func (t *ToDo) getLog(id int) (ToDoItem, error) {
+	if err := t.log.sync(); err != nil {
+		return ToDoItem{}, err
+	}
+	item, exists, err := t.log.get(id)
+	if err != nil {
+		return ToDoItem{}, err
+	}
+	if !exists {
+		return ToDoItem{}, errors.New("Couldn't get item. Item does not exist in the map.")
+	}
+	return item, nil
+}
+
+// appendLog appends records and takes care of what saveDB does after
+// writing the file.  Automatic backups are only made when the log is
+// compacted, copying the whole file on every append would defeat the
+// purpose of the format.
+func (t *ToDo) appendLog(records ...logRecord) error {
+	if err := t.log.append(records...); err != nil {
+		return err
+	}
+	if t.log.needsCompaction() {
+		return t.Compact()
+	}
+	if t.opts.GitHistory {
+		items, err := t.log.all()
+		if err != nil {
+			return err
+		}
+		list := make([]ToDoItem, 0, len(items))
+		for _, item := range items {
+			list = append(list, item)
+		}
+		sortItems(list)
+		return t.commitDB(list)
+	}
+	return nil
+}
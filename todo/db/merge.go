@@ -0,0 +1,529 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// MergeConflict describes a single item that was changed in incompatible
+// ways on both sides of a three-way merge.  Base, Ours and Theirs hold the
+// version of the item from each input, a nil pointer means the item does
+// not exist on that side (it was never added, or it was deleted).
+//
+// Fields lists the json names of the fields that were changed to different
+// values on both sides.  It is empty when the conflict is about the item
+// as a whole, i.e. one side deleted the item while the other modified it.
+type MergeConflict struct {
+	Id     int
+	Fields []string
+	Base   *ToDoItem
+	Ours   *ToDoItem
+	Theirs *ToDoItem
+}
+
+// MergeResult is the outcome of Merge.  Items contains every item of the
+// merged database sorted by id.  For items listed in Conflicts, Items
+// holds the automatically merged version where every conflicting field
+// (or the whole item for delete/modify conflicts) was taken from "ours",
+// so that a caller can still decide to keep the result as is.
+type MergeResult struct {
+	Items     []ToDoItem
+	Conflicts []MergeConflict
+}
+
+// Merge performs an item level three-way merge of two databases (ours and
+// theirs) that diverged from a common ancestor (base).  Items are matched
+// on ToDoItem.Id and merged field by field:
+//
+//   - a field changed on only one side takes the changed value
+//   - a field changed to the same value on both sides takes that value
+//   - a field changed to different values on both sides is a conflict
+//
+// Items added or deleted on one side only are added or deleted in the
+// result.  Deleting an item on one side while modifying it on the other,
+// or adding two different items with the same id, is reported as a
+// conflict.
+func Merge(base, ours, theirs []ToDoItem) (MergeResult, error) {
+	baseMap := itemsToMap(base)
+	oursMap := itemsToMap(ours)
+	theirsMap := itemsToMap(theirs)
+
+	//Collect every id that appears on any side
+	ids := make(map[int]bool)
+	for _, m := range []DbMap{baseMap, oursMap, theirsMap} {
+		for id := range m {
+			ids[id] = true
+		}
+	}
+
+	var result MergeResult
+	for id := range ids {
+		b, inBase := baseMap[id]
+		o, inOurs := oursMap[id]
+		t, inTheirs := theirsMap[id]
+
+		switch {
+		case inOurs && inTheirs:
+			//Present on both sides, merge field by field.  If the item
+			//was added on both sides the base is simply empty.
+			var basePtr *ToDoItem
+			if inBase {
+				basePtr = &b
+			}
+			merged, fields, err := mergeItem(basePtr, o, t)
+			if err != nil {
+				return MergeResult{}, err
+			}
+			result.Items = append(result.Items, merged)
+			if len(fields) > 0 {
+				result.Conflicts = append(result.Conflicts, MergeConflict{
+					Id: id, Fields: fields, Base: basePtr, Ours: &o, Theirs: &t,
+				})
+			}
+		case inOurs && !inTheirs:
+			if !inBase {
+				//Added by us
+				result.Items = append(result.Items, o)
+				break
+			}
+			//Deleted by them, that is only clean if we did not touch it
+			changed, err := itemsDiffer(b, o)
+			if err != nil {
+				return MergeResult{}, err
+			}
+			if changed {
+				result.Items = append(result.Items, o)
+				result.Conflicts = append(result.Conflicts, MergeConflict{
+					Id: id, Base: &b, Ours: &o,
+				})
+			}
+		case !inOurs && inTheirs:
+			if !inBase {
+				//Added by them
+				result.Items = append(result.Items, t)
+				break
+			}
+			//Deleted by us, that is only clean if they did not touch it
+			changed, err := itemsDiffer(b, t)
+			if err != nil {
+				return MergeResult{}, err
+			}
+			if changed {
+				result.Conflicts = append(result.Conflicts, MergeConflict{
+					Id: id, Base: &b, Theirs: &t,
+				})
+			}
+		default:
+			//Deleted on both sides, nothing to do
+		}
+	}
+
+	sortItems(result.Items)
+	sort.Slice(result.Conflicts, func(i, j int) bool {
+		return result.Conflicts[i].Id < result.Conflicts[j].Id
+	})
+
+	return result, nil
+}
+
+// ResolveFields settles a field conflict in the merge result.  For every
+// field listed in the conflict, useTheirs selects whether the value from
+// "theirs" replaces the value from "ours".  Fields missing from useTheirs
+// keep the value from "ours".  The conflict is removed from r.Conflicts.
+func (r *MergeResult) ResolveFields(id int, useTheirs map[string]bool) error {
+	idx, err := r.conflictIndex(id)
+	if err != nil {
+		return err
+	}
+	c := r.Conflicts[idx]
+	if len(c.Fields) == 0 {
+		return fmt.Errorf("Merge conflict for item %d is a delete/modify conflict.", id)
+	}
+
+	oursFields, err := itemToFields(c.Ours)
+	if err != nil {
+		return err
+	}
+	theirsFields, err := itemToFields(c.Theirs)
+	if err != nil {
+		return err
+	}
+	for i := range r.Items {
+		if r.Items[i].Id != id {
+			continue
+		}
+		merged, err := itemToFields(&r.Items[i])
+		if err != nil {
+			return err
+		}
+		for _, field := range c.Fields {
+			src := oursFields
+			if useTheirs[field] {
+				src = theirsFields
+			}
+			if v, ok := src[field]; ok {
+				merged[field] = v
+			} else {
+				delete(merged, field)
+			}
+		}
+		item, err := fieldsToItem(merged)
+		if err != nil {
+			return err
+		}
+		r.Items[i] = item
+	}
+
+	r.Conflicts = append(r.Conflicts[:idx], r.Conflicts[idx+1:]...)
+	return nil
+}
+
+// ResolveDeletion settles a delete/modify conflict in the merge result.
+// If keep is true the item is kept as modified by the side that did not
+// delete it, otherwise it is removed.  The conflict is removed from
+// r.Conflicts.
+func (r *MergeResult) ResolveDeletion(id int, keep bool) error {
+	idx, err := r.conflictIndex(id)
+	if err != nil {
+		return err
+	}
+	c := r.Conflicts[idx]
+	if len(c.Fields) != 0 {
+		return fmt.Errorf("Merge conflict for item %d is not a delete/modify conflict.", id)
+	}
+
+	kept := c.Ours
+	if kept == nil {
+		kept = c.Theirs
+	}
+	r.Items = removeItem(r.Items, id)
+	if keep {
+		r.Items = append(r.Items, *kept)
+		sortItems(r.Items)
+	}
+
+	r.Conflicts = append(r.Conflicts[:idx], r.Conflicts[idx+1:]...)
+	return nil
+}
+
+func (r *MergeResult) conflictIndex(id int) (int, error) {
+	for i, c := range r.Conflicts {
+		if c.Id == id {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("No merge conflict for item %d.", id)
+}
+
+// WriteConflictMarkers writes the merge result in the same layout as the
+// database file, except that every conflicting item is written as a git
+// style conflict block containing the "ours" and "theirs" versions of the
+// item.  The output is only valid JSON when there are no conflicts, which
+// is intentional: the file has to be edited by hand before it can be
+// loaded again.
+func (r MergeResult) WriteConflictMarkers(w io.Writer, oursLabel, theirsLabel string) error {
+	conflicts := make(map[int]MergeConflict)
+	for _, c := range r.Conflicts {
+		conflicts[c.Id] = c
+	}
+
+	//Items that were deleted on one side are not part of r.Items, but the
+	//conflict block still has to be written at the correct position
+	ids := make([]int, 0, len(r.Items))
+	seen := make(map[int]bool)
+	items := make(map[int]ToDoItem)
+	for _, item := range r.Items {
+		ids = append(ids, item.Id)
+		seen[item.Id] = true
+		items[item.Id] = item
+	}
+	for id := range conflicts {
+		if !seen[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	//Either side of a conflict may be empty, so the comma that separates
+	//an item from the next one only goes after items that are followed by
+	//an item without conflict.  The items after the last of those get it
+	//in front instead.  Only if every item has a conflict and the first
+	//one is dropped the comma in front of the next one has to go by hand.
+	last := -1
+	for i, id := range ids {
+		if _, conflicted := conflicts[id]; !conflicted {
+			last = i
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteString("[\n")
+	for i, id := range ids {
+		before, after := "", ""
+		switch {
+		case i < last:
+			after = ","
+		case i > last && i > 0:
+			before = ", "
+		}
+		c, conflicted := conflicts[id]
+		if !conflicted {
+			if err := writeIndentedItem(&buf, items[id], before, after); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(&buf, "<<<<<<< %s\n", oursLabel)
+		if c.Ours != nil {
+			if err := writeIndentedItem(&buf, *c.Ours, before, after); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("=======\n")
+		if c.Theirs != nil {
+			if err := writeIndentedItem(&buf, *c.Theirs, before, after); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(&buf, ">>>>>>> %s\n", theirsLabel)
+	}
+	buf.WriteString("]")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ReplaceAllItems replaces the whole content of the DB with the provided
+// items and saves the DB file.  Items must have unique ids.  Every item
+// that is added, changed or removed is a change like any other: the
+// pre-hooks can veto the replacement, removed items go to the trash and
+// the changes are recorded in the history.
+func (t *ToDo) ReplaceAllItems(items []ToDoItem) error {
+	newMap := make(DbMap, len(items))
+	for _, item := range items {
+		if _, exists := newMap[item.Id]; exists {
+			return fmt.Errorf("Couldn't replace items. Item %d appears more than once.", item.Id)
+		}
+		newMap[item.Id] = item
+	}
+	if err := t.loadDB(); err != nil {
+		return err
+	}
+
+	type change struct {
+		before, after *ToDoItem
+	}
+	var changes []change
+	var removed []ToDoItem
+	for i := range items {
+		after := &items[i]
+		prev, exists := t.toDoMap[after.Id]
+		switch {
+		case !exists:
+			changes = append(changes, change{nil, after})
+		case len(changedFields(prev, *after)) > 0:
+			changes = append(changes, change{&prev, after})
+		}
+	}
+	for _, item := range t.toDoMap {
+		if _, kept := newMap[item.Id]; !kept {
+			removed = append(removed, item)
+		}
+	}
+	sortItems(removed)
+	for i := range removed {
+		changes = append(changes, change{&removed[i], nil})
+	}
+
+	for _, c := range changes {
+		if err := t.preHooks(c.before, c.after); err != nil {
+			return err
+		}
+	}
+	if len(removed) > 0 {
+		if err := t.moveToTrash(removed...); err != nil {
+			return err
+		}
+	}
+	t.toDoMap = newMap
+	if err := t.saveDB(); err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		op := OpUpdate
+		if c.before == nil {
+			op = OpAdd
+		} else if c.after == nil {
+			op = OpDelete
+		}
+		if err := t.changed(op, c.before, c.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteItems replaces the items and writes the DB file without treating
+// it as a change of the DB: nothing is backed up, trashed, recorded or
+// committed and no hooks run.  It is meant for files that are not the DB
+// in use, such as the temporary file git hands a merge driver, which
+// must not get side files next to it.
+func (t *ToDo) WriteItems(items []ToDoItem) error {
+	newMap := make(DbMap, len(items))
+	for _, item := range items {
+		if _, exists := newMap[item.Id]; exists {
+			return fmt.Errorf("Couldn't write items. Item %d appears more than once.", item.Id)
+		}
+		newMap[item.Id] = item
+	}
+	t.toDoMap = newMap
+	list := append([]ToDoItem(nil), items...)
+	sortItems(list)
+	return t.writeDB(list)
+}
+
+//------------------------------------------------------------
+// MERGE HELPERS
+//------------------------------------------------------------
+
+// mergeItem merges a single item field by field.  The fields are compared
+// using their json encoding so that any field added to ToDoItem in the
+// future takes part in the merge without changes here.  It returns the
+// merged item (conflicting fields keep the value from ours) and the names
+// of the conflicting fields.
+func mergeItem(base *ToDoItem, ours, theirs ToDoItem) (ToDoItem, []string, error) {
+	baseFields, err := itemToFields(base)
+	if err != nil {
+		return ToDoItem{}, nil, err
+	}
+	oursFields, err := itemToFields(&ours)
+	if err != nil {
+		return ToDoItem{}, nil, err
+	}
+	theirsFields, err := itemToFields(&theirs)
+	if err != nil {
+		return ToDoItem{}, nil, err
+	}
+
+	keys := make(map[string]bool)
+	for _, m := range []map[string]json.RawMessage{baseFields, oursFields, theirsFields} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+
+	merged := make(map[string]json.RawMessage)
+	var conflicts []string
+	for k := range keys {
+		b, inBase := baseFields[k]
+		o, inOurs := oursFields[k]
+		th, inTheirs := theirsFields[k]
+
+		sameOT := inOurs == inTheirs && bytes.Equal(o, th)
+		sameBO := inBase == inOurs && bytes.Equal(b, o)
+		sameBT := inBase == inTheirs && bytes.Equal(b, th)
+
+		switch {
+		case sameOT, sameBT:
+			//Unchanged by them (or same change on both sides), keep ours
+			if inOurs {
+				merged[k] = o
+			}
+		case sameBO:
+			//Only changed by them
+			if inTheirs {
+				merged[k] = th
+			}
+		default:
+			conflicts = append(conflicts, k)
+			if inOurs {
+				merged[k] = o
+			}
+		}
+	}
+	sort.Strings(conflicts)
+
+	item, err := fieldsToItem(merged)
+	if err != nil {
+		return ToDoItem{}, nil, err
+	}
+	return item, conflicts, nil
+}
+
+// itemsDiffer reports whether two items have a different json encoding
+func itemsDiffer(a, b ToDoItem) (bool, error) {
+	aJson, err := json.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+	bJson, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(aJson, bJson), nil
+}
+
+// itemToFields converts an item into a map of json field name to the
+// json encoded value of the field.  A nil item results in an empty map.
+func itemToFields(item *ToDoItem) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if item == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// fieldsToItem is the inverse of itemToFields
+func fieldsToItem(fields map[string]json.RawMessage) (ToDoItem, error) {
+	var item ToDoItem
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return ToDoItem{}, err
+	}
+	if err := json.Unmarshal(data, &item); err != nil {
+		return ToDoItem{}, err
+	}
+	return item, nil
+}
+
+func itemsToMap(items []ToDoItem) DbMap {
+	m := make(DbMap, len(items))
+	for _, item := range items {
+		m[item.Id] = item
+	}
+	return m
+}
+
+func removeItem(items []ToDoItem, id int) []ToDoItem {
+	kept := items[:0]
+	for _, item := range items {
+		if item.Id != id {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// writeIndentedItem writes a single item the same way saveDB formats the
+// items of the json array, followed by sep and a newline
+func writeIndentedItem(buf *bytes.Buffer, item ToDoItem, before, after string) error {
+	data, err := json.MarshalIndent(item, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	buf.WriteString("  " + before)
+	buf.Write(data)
+	buf.WriteString(after)
+	buf.WriteString("\n")
+	return nil
+}
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Items are ordered by hand with ranks, strings that sort in the order of
+// the items.  There is always a rank between two others ("i" is between
+// "h" and "j", "hi" between "h" and "i"), so moving an item only changes
+// the rank of that item instead of renumbering the items around it.  Items
+// without a rank come after the ranked ones, ordered by id, new items are
+// added at the bottom that way.
+//
+// Ranks are written with the digits 0-9 and the lower case letters a-z and
+// never end with 0, otherwise no rank would fit between "h" and "h0".
+
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// validRank reports whether s can be used as the rank of an item
+func validRank(s string) bool {
+	if s == "" || s[len(s)-1] == rankDigits[0] {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(rankDigits, s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SortByRank orders items by rank, the items without a rank last
+func SortByRank(items []ToDoItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if (a.Rank == "") != (b.Rank == "") {
+			return a.Rank != ""
+		}
+		if a.Rank != b.Rank {
+			return a.Rank < b.Rank
+		}
+		return a.Id < b.Id
+	})
+}
+
+// MoveItemBefore ranks an item right before another one
+func (t *ToDo) MoveItemBefore(id int, other int) error {
+	return t.rankItem(id, &other, func(order []ToDoItem) int {
+		return indexOf(order, other)
+	})
+}
+
+// MoveItemAfter ranks an item right after another one
+func (t *ToDo) MoveItemAfter(id int, other int) error {
+	return t.rankItem(id, &other, func(order []ToDoItem) int {
+		if at := indexOf(order, other); at != -1 {
+			return at + 1
+		}
+		return -1
+	})
+}
+
+// MoveItemToTop ranks an item before all others
+func (t *ToDo) MoveItemToTop(id int) error {
+	return t.rankItem(id, nil, func(order []ToDoItem) int {
+		return 0
+	})
+}
+
+// MoveItemToBottom ranks an item after all others
+func (t *ToDo) MoveItemToBottom(id int) error {
+	return t.rankItem(id, nil, func(order []ToDoItem) int {
+		return len(order)
+	})
+}
+
+// rankItem gives an item a rank that puts it at a position of the order
+// of the other items.  position returns the index the item is inserted
+// at, -1 if there is none, other is the item the position is relative to,
+// nil for none.
+func (t *ToDo) rankItem(id int, other *int, position func(order []ToDoItem) int) error {
+	if err := t.loadDB(); err != nil {
+		return err
+	}
+	item, exists := t.toDoMap[id]
+	if !exists {
+		return fmt.Errorf("Couldn't move item. Item %d does not exist in the map.", id)
+	}
+	if other != nil {
+		if *other == id {
+			return fmt.Errorf("Couldn't move item. Item %d cannot be moved next to itself.", id)
+		}
+		if _, exists := t.toDoMap[*other]; !exists {
+			return fmt.Errorf("Couldn't move item. Item %d does not exist in the map.", *other)
+		}
+	}
+
+	order := make([]ToDoItem, 0, len(t.toDoMap))
+	for _, i := range t.toDoMap {
+		if i.Id != id {
+			order = append(order, i)
+		}
+	}
+	SortByRank(order)
+	at := position(order)
+	if at < 0 || at > len(order) {
+		return fmt.Errorf("Couldn't move item. Item %d does not exist in the map.", *other)
+	}
+
+	//Unranked items are only ordered by id, the ones that end up before
+	//the item need ranks to keep their place.  This happens once, after
+	//that they keep their ranks.
+	var changed []ToDoItem
+	lower := ""
+	for i := 0; i < at; i++ {
+		if order[i].Rank == "" {
+			order[i].Rank = rankBetween(lower, "")
+			changed = append(changed, order[i])
+		}
+		lower = order[i].Rank
+	}
+	upper := ""
+	if at < len(order) {
+		upper = order[at].Rank
+	}
+	if upper != "" && lower >= upper {
+		return fmt.Errorf("Couldn't move item. Items %d and %d have the same rank %q.", order[at-1].Id, order[at].Id, upper)
+	}
+
+	item.Rank = rankBetween(lower, upper)
+	return t.UpdateItems(append(changed, item))
+}
+
+func indexOf(items []ToDoItem, id int) int {
+	for i, item := range items {
+		if item.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// rankBetween returns a rank that sorts after a and before b.  An empty a
+// stands for the start and an empty b for the end of the order.
+func rankBetween(a, b string) string {
+	if b != "" {
+		//Keep the common prefix, a is padded with the smallest digit
+		n := 0
+		for n < len(b) && rankDigit(a, n) == rankDigit(b, n) {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + rankBetween(rankRest(a, n), b[n:])
+		}
+	}
+
+	lo, hi := 0, len(rankDigits)
+	if a != "" {
+		lo = rankDigit(a, 0)
+	}
+	if b != "" {
+		hi = rankDigit(b, 0)
+	}
+	if hi-lo > 1 {
+		return string(rankDigits[(lo+hi)/2])
+	}
+	//The first digits are neighbors, b cut short is between a and b if
+	//it has more digits, otherwise a digit is added to a
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(rankDigits[lo]) + rankBetween(rankRest(a, 1), "")
+}
+
+// rankDigit returns the value of the digit of a rank at i, ranks are
+// padded with the smallest digit
+func rankDigit(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	if d := strings.IndexByte(rankDigits, s[i]); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func rankRest(s string, i int) string {
+	if i >= len(s) {
+		return ""
+	}
+	return s[i:]
+}
@@ -0,0 +1,37 @@
+package db
+
+import "time"
+
+// IsDeferred reports whether an item is snoozed at now.  Deferred items
+// are left out of the default list views until their DeferUntil date
+// passes, then they show up again without anybody touching the DB.
+func (item ToDoItem) IsDeferred(now time.Time) bool {
+	return item.DeferUntil != nil && now.Before(*item.DeferUntil)
+}
+
+// Snooze defers an item until the given time, the zero time makes it
+// visible right away
+func (t *ToDo) Snooze(id int, until time.Time) error {
+	item, err := t.GetItem(id)
+	if err != nil {
+		return err
+	}
+	if until.IsZero() {
+		item.DeferUntil = nil
+	} else {
+		until = until.UTC()
+		item.DeferUntil = &until
+	}
+	return t.UpdateItem(item)
+}
+
+// VisibleItems returns the items that are not deferred at now
+func VisibleItems(items []ToDoItem, now time.Time) []ToDoItem {
+	var visible []ToDoItem
+	for _, item := range items {
+		if !item.IsDeferred(now) {
+			visible = append(visible, item)
+		}
+	}
+	return visible
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The kinds of entries in the activity of an item
+const (
+	ActivityComment  = "comment"
+	ActivityAssign   = "assign"
+	ActivityUnassign = "unassign"
+)
+
+// Activity is an entry of the activity thread of an item, a comment or a
+// change of its assignees, with who made it and when.  For assign and
+// unassign entries Text is the user that was (un)assigned.
+type Activity struct {
+	At     time.Time `json:"at"`
+	Author string    `json:"author"`
+	Kind   string    `json:"kind"`
+	Text   string    `json:"text"`
+}
+
+// IsAssignedTo reports whether user is one of the assignees of an item
+func (item ToDoItem) IsAssignedTo(user string) bool {
+	for _, assignee := range item.Assignees {
+		if assignee == user {
+			return true
+		}
+	}
+	return false
+}
+
+// Assign adds user to the assignees of an item, by is the user making the
+// change.  The first assignee is the owner of the item.
+func (t *ToDo) Assign(id int, user string, by string) error {
+	if err := validUser(user); err != nil {
+		return err
+	}
+	item, err := t.GetItem(id)
+	if err != nil {
+		return err
+	}
+	if item.IsAssignedTo(user) {
+		return fmt.Errorf("Item %d is already assigned to %s.", id, user)
+	}
+	item.Assignees = append(append([]string(nil), item.Assignees...), user)
+	item.Activity = addActivity(item.Activity, by, ActivityAssign, user)
+	return t.UpdateItem(item)
+}
+
+// Unassign removes user from the assignees of an item, by is the user
+// making the change
+func (t *ToDo) Unassign(id int, user string, by string) error {
+	item, err := t.GetItem(id)
+	if err != nil {
+		return err
+	}
+	if !item.IsAssignedTo(user) {
+		return fmt.Errorf("Item %d is not assigned to %s.", id, user)
+	}
+	var assignees []string
+	for _, assignee := range item.Assignees {
+		if assignee != user {
+			assignees = append(assignees, assignee)
+		}
+	}
+	item.Assignees = assignees
+	item.Activity = addActivity(item.Activity, by, ActivityUnassign, user)
+	return t.UpdateItem(item)
+}
+
+// Comment adds a comment by author to the activity of an item
+func (t *ToDo) Comment(id int, author string, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return errors.New("The comment is empty.")
+	}
+	item, err := t.GetItem(id)
+	if err != nil {
+		return err
+	}
+	item.Activity = addActivity(item.Activity, author, ActivityComment, text)
+	return t.UpdateItem(item)
+}
+
+// addActivity returns a copy of the activity with a new entry, the item
+// may share the old slice with the map of the DB
+func addActivity(activity []Activity, author string, kind string, text string) []Activity {
+	entry := Activity{At: time.Now().UTC(), Author: author, Kind: kind, Text: text}
+	return append(append([]Activity(nil), activity...), entry)
+}
+
+// validUser checks a user name, which is a single word so that it can be
+// shown as @name
+func validUser(user string) error {
+	if user == "" || strings.ContainsAny(user, " \t\r\n@") {
+		return fmt.Errorf("%q is not a user name, names are a single word without @.", user)
+	}
+	return nil
+}
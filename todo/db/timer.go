@@ -0,0 +1,103 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimeEntry is a stretch of time spent on an item
+type TimeEntry struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Duration returns the length of the entry
+func (e TimeEntry) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// TimeSpent returns the total time logged on an item, not counting a
+// running timer
+func (item ToDoItem) TimeSpent() time.Duration {
+	var total time.Duration
+	for _, entry := range item.TimeEntries {
+		total += entry.Duration()
+	}
+	return total
+}
+
+// ErrNoTimer is returned by StopTimer when no timer is running
+var ErrNoTimer = errors.New("No timer is running.")
+
+// StartTimer starts timing an item.  Only one timer runs at a time, so
+// starting a second one fails until the first is stopped.  The start time
+// is stored on the item in the DB file, so a running timer survives the
+// program (or the computer) crashing.
+func (t *ToDo) StartTimer(id int) error {
+	if running, ok, err := t.ActiveTimer(); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("The timer of item %d is running, stop it first.", running.Id)
+	}
+
+	item, err := t.GetItem(id)
+	if err != nil {
+		return err
+	}
+	if item.IsDone {
+		return fmt.Errorf("Item %d is done.", id)
+	}
+	now := time.Now().UTC()
+	item.TimerStart = &now
+	return t.UpdateItem(item)
+}
+
+// StopTimer stops the running timer and adds the time to the entries of
+// its item.  It returns the item (as saved) and the new entry.
+func (t *ToDo) StopTimer() (ToDoItem, TimeEntry, error) {
+	item, ok, err := t.ActiveTimer()
+	if err != nil {
+		return ToDoItem{}, TimeEntry{}, err
+	}
+	if !ok {
+		return ToDoItem{}, TimeEntry{}, ErrNoTimer
+	}
+
+	entry := TimeEntry{Start: *item.TimerStart, End: time.Now().UTC()}
+	item.TimeEntries = append(item.TimeEntries, entry)
+	item.TimerStart = nil
+	if err := t.UpdateItem(item); err != nil {
+		return ToDoItem{}, TimeEntry{}, err
+	}
+	return item, entry, nil
+}
+
+// ActiveTimer returns the item whose timer is running, the bool is false
+// if there is none
+func (t *ToDo) ActiveTimer() (ToDoItem, bool, error) {
+	if err := t.loadDB(); err != nil {
+		return ToDoItem{}, false, err
+	}
+	for _, item := range t.toDoMap {
+		if item.TimerStart != nil {
+			return item, true, nil
+		}
+	}
+	return ToDoItem{}, false, nil
+}
+
+// LogTime adds an entry of the given length, ending at end, to an item.
+// It is meant for time that was not tracked with a timer.
+func (t *ToDo) LogTime(id int, d time.Duration, end time.Time) error {
+	if d <= 0 {
+		return errors.New("The logged time must be more than 0.")
+	}
+	item, err := t.GetItem(id)
+	if err != nil {
+		return err
+	}
+	end = end.UTC()
+	item.TimeEntries = append(item.TimeEntries, TimeEntry{Start: end.Add(-d), End: end})
+	return t.UpdateItem(item)
+}
@@ -6,13 +6,68 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"drexel.edu/todo/duration"
 )
 
 // ToDoItem is the struct that represents a single ToDo item
+//
+// Only id, title and done are required, the other fields are optional and
+// left out of the json if they are not set.  Priority 1 is the highest,
+// 0 means the item has no priority.  RemindBefore asks for an extra
+// reminder this long before the due date, written like the durations on
+// the command line ("2h", "1d"), see the remind package.  Items with a
+// DeferUntil date in the future are snoozed, see snooze.go.  ParentId is
+// the id of the item a subtask belongs to, 0 for top level items.
+// Status is the state of the item in the workflow and Transitions its
+// moves between states, see workflow.go.  TimeEntries is the time spent
+// on the item and TimerStart is set while its timer runs, see timer.go.
+// Rank orders the items by hand, see rank.go.  Assignees are the users
+// working on the item, the first one owns it, and Activity is its thread
+// of comments and assignments, see team.go.  Attachments are the links
+// and files attached to it, see attach.go.  Notes is free text about the
+// item.  Estimate is the expected effort in hours or points and DependsOn
+// the ids of the items that have to be done first, see estimate.go.
 type ToDoItem struct {
-	Id     int    `json:"id"`
-	Title  string `json:"title"`
-	IsDone bool   `json:"done"`
+	Id           int          `json:"id"`
+	Title        string       `json:"title"`
+	IsDone       bool         `json:"done"`
+	Tags         []string     `json:"tags,omitempty"`
+	Priority     int          `json:"priority,omitempty"`
+	Due          *time.Time   `json:"due,omitempty"`
+	RemindBefore string       `json:"remind_before,omitempty"`
+	DeferUntil   *time.Time   `json:"defer_until,omitempty"`
+	ParentId     int          `json:"parent_id,omitempty"`
+	Status       string       `json:"status,omitempty"`
+	Transitions  []Transition `json:"transitions,omitempty"`
+	TimeEntries  []TimeEntry  `json:"time_entries,omitempty"`
+	TimerStart   *time.Time   `json:"timer_start,omitempty"`
+	CreatedAt    *time.Time   `json:"created_at,omitempty"`
+	DoneAt       *time.Time   `json:"done_at,omitempty"`
+	Source       *SourceRef   `json:"source,omitempty"`
+	Rank         string       `json:"rank,omitempty"`
+	Assignees    []string     `json:"assignees,omitempty"`
+	Activity     []Activity   `json:"activity,omitempty"`
+	Attachments  []Attachment `json:"attachments,omitempty"`
+	Notes        string       `json:"notes,omitempty"`
+	Estimate     string       `json:"estimate,omitempty"`
+	DependsOn    []int        `json:"depends_on,omitempty"`
+}
+
+// SourceRef links a ToDoItem to a TODO style comment in a source code
+// file, see the scan package.  Root is the absolute path of the directory
+// that was scanned and File is relative to it.  Fingerprint identifies the
+// comment independently of its line number so that rescanning a directory
+// updates the existing item instead of adding a duplicate.
+type SourceRef struct {
+	Root        string `json:"root"`
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Kind        string `json:"kind"`
+	Fingerprint string `json:"fingerprint"`
 }
 
 // DbMap is a type alias for a map of ToDoItems.  The key
@@ -39,6 +94,51 @@ type DbMap map[int]ToDoItem
 type ToDo struct {
 	toDoMap    DbMap
 	dbFileName string
+	opts       Options
+
+	// Message of the next commit for Options.GitHistory, see git.go.
+	// Empty generates the message from the changes.
+	commitMessage string
+
+	// Reader and writer of DBs in the log format, nil for the json
+	// format, see logstore.go
+	log *logStore
+
+	// State of an encrypted DB, see crypto.go
+	encrypted  bool
+	passphrase string
+	key        []byte
+	salt       []byte
+}
+
+// Options holds the optional settings of a ToDo.  The zero value gives
+// the same behavior as New.
+type Options struct {
+	// BackupRetention is the number of automatic backups of the DB file
+	// that are kept, see backupDB.  0 disables automatic backups.
+	BackupRetention int
+
+	// TrashRetention is how long deleted items are kept in the trash
+	// before they are removed for good, see trash.go.  0 keeps them
+	// until the trash is emptied.
+	TrashRetention time.Duration
+
+	// HooksDir is the directory holding the hooks run when items
+	// change, see hooks.go.  Empty disables hooks.
+	HooksDir string
+
+	// Workflow is the list of states items move through, see
+	// workflow.go.  The zero value uses DefaultWorkflow.
+	Workflow Workflow
+
+	// GitHistory commits the DB file every time it is saved, to a git
+	// repository of its own next to the DB file, see git.go
+	GitHistory bool
+
+	// Passphrase is called (at most once) when an encrypted DB file has
+	// to be read or written, see crypto.go.  It is only needed for
+	// encrypted DBs.
+	Passphrase func() (string, error)
 }
 
 // New is a constructor function that returns a pointer to a new
@@ -47,6 +147,12 @@ type ToDo struct {
 // If the file doesn't exist, it will be created.  If the file
 // does exist, it will be loaded into the ToDo struct.
 func New(dbFile string) (*ToDo, error) {
+	return NewWithOptions(dbFile, Options{})
+}
+
+// NewWithOptions works like New, but allows to turn on the optional
+// features described in Options.
+func NewWithOptions(dbFile string, opts Options) (*ToDo, error) {
 
 	//Check if the database file exists, if not use initDB to create it
 	//In go, you use the os.Stat function to get information about a file
@@ -65,6 +171,21 @@ func New(dbFile string) (*ToDo, error) {
 	toDo := &ToDo{
 		toDoMap:    make(map[int]ToDoItem),
 		dbFileName: dbFile,
+		opts:       opts,
+	}
+
+	//Saves have to keep an encrypted DB encrypted even if nothing was
+	//loaded before, so check the format of the file right away.  Only
+	//the start of the file is read, DBs in the log format can be large.
+	header, err := readHeader(dbFile)
+	if err != nil {
+		return nil, err
+	}
+	toDo.encrypted = isEncrypted(header)
+	if isLog(header) {
+		if toDo.log, err = openLog(dbFile); err != nil {
+			return nil, err
+		}
 	}
 
 	// We should be all set here, the ToDo struct is ready to go
@@ -72,6 +193,11 @@ func New(dbFile string) (*ToDo, error) {
 	return toDo, nil
 }
 
+// FileName returns the name of the DB file
+func (t *ToDo) FileName() string {
+	return t.dbFileName
+}
+
 // RestoreDB copies the backup file to the db file. This is useful for testing
 // as we restore the database to a known state before running tests - or if we
 // mess up.  In the source code I provided there is a /data directory.  In that
@@ -115,13 +241,26 @@ func (t *ToDo) RestoreDB() error {
 	}
 	defer backupFile.Close()
 
+	//An encrypted backup is copied as is, but a plain backup has to be
+	//encrypted first if the DB itself is encrypted, otherwise restoring
+	//would silently store the DB unencrypted
+	data, err := io.ReadAll(backupFile)
+	if err != nil {
+		return err
+	}
+	if isEncrypted(data) {
+		t.encrypted = true
+	} else if data, err = t.encode(data); err != nil {
+		return err
+	}
+
 	dbFile, err := os.Create(dbFileName)
 	if err != nil {
 		return err
 	}
 	defer dbFile.Close()
 
-	if _, err := io.Copy(dbFile, backupFile); err != nil {
+	if _, err := dbFile.Write(data); err != nil {
 		return err
 	}
 
@@ -146,7 +285,9 @@ func (t *ToDo) RestoreDB() error {
 //
 //	 (1) The item will be added to the DB
 //		(2) The DB file will be saved with the item added
-//		(3) If there is an error, it will be returned
+//		(3) The add hooks will run, a pre-add hook can veto the
+//			change, see hooks.go
+//		(4) If there is an error, it will be returned
 func (t *ToDo) AddItem(item ToDoItem) error {
 	//Start by loading the database into the private map in our struct
 	//see the loadDB() helper.  Then make sure the item we want to load
@@ -159,6 +300,9 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 	//at the end to indicate that the item was properly added to the
 	//database.
 
+	if t.log != nil {
+		return t.putLog(OpAdd, item)
+	}
 	if err := t.loadDB(); err != nil {
 		return err
 	}
@@ -166,6 +310,9 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 	if _, exists := t.toDoMap[item.Id]; exists {
 		return errors.New("Couldn't add item. Item already exists in the map.")
 	}
+	if err := t.preHooks(nil, &item); err != nil {
+		return err
+	}
 
 	t.toDoMap[item.Id] = item
 
@@ -173,6 +320,44 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 		return err
 	}
 
+	return t.changed(OpAdd, nil, &item)
+}
+
+// AddItems adds several items at once.  Either all items are added or,
+// if one of them already exists or is vetoed by a pre-add hook, none.
+func (t *ToDo) AddItems(items []ToDoItem) error {
+	if err := t.loadDB(); err != nil {
+		return err
+	}
+
+	ids := make(map[int]bool, len(items))
+	for i := range items {
+		id := items[i].Id
+		if _, exists := t.toDoMap[id]; exists || ids[id] {
+			return fmt.Errorf("Couldn't add items. Item %d already exists.", id)
+		}
+		ids[id] = true
+	}
+	//Only run the pre-hooks once the whole batch is known to be valid, a
+	//hook must not see an add that then fails on a later item
+	for i := range items {
+		if err := t.preHooks(nil, &items[i]); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range items {
+		t.toDoMap[item.Id] = item
+	}
+	if err := t.saveDB(); err != nil {
+		return err
+	}
+
+	for i := range items {
+		if err := t.changed(OpAdd, nil, &items[i]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -188,7 +373,10 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 //
 //	 (1) The item will be removed from the DB
 //		(2) The DB file will be saved with the item removed
-//		(3) If there is an error, it will be returned
+//		(3) The item will be moved to the trash, see trash.go
+//		(4) The delete hooks will run, a pre-delete hook can veto the
+//			change, see hooks.go
+//		(5) If there is an error, it will be returned
 func (t *ToDo) DeleteItem(id int) error {
 	//Like the add item function, start by loading the database into the
 	//private map in our struct.  Then make sure the item we want to delete
@@ -201,11 +389,23 @@ func (t *ToDo) DeleteItem(id int) error {
 	//return nil at the end to indicate that the item was properly deleted
 	//from the database.
 
+	if t.log != nil {
+		return t.deleteLog(id)
+	}
 	if err := t.loadDB(); err != nil {
 		return err
 	}
 
-	if _, exists := t.toDoMap[id]; exists {
+	item, exists := t.toDoMap[id]
+	if exists {
+		if err := t.preHooks(&item, nil); err != nil {
+			return err
+		}
+		//Save the item in the trash first, if that fails the item
+		//is still in the DB and nothing is lost
+		if err := t.moveToTrash(item); err != nil {
+			return err
+		}
 		delete(t.toDoMap, id)
 	} else {
 		return errors.New("Couldn't remove item. Item doesn't exist in the map.")
@@ -215,7 +415,7 @@ func (t *ToDo) DeleteItem(id int) error {
 		return err
 	}
 
-	return nil
+	return t.changed(OpDelete, &item, nil)
 }
 
 // UpdateItem accepts a ToDoItem and updates it in the DB.
@@ -230,7 +430,9 @@ func (t *ToDo) DeleteItem(id int) error {
 //
 //	 (1) The item will be updated in the DB
 //		(2) The DB file will be saved with the item updated
-//		(3) If there is an error, it will be returned
+//		(3) The update (and done) hooks will run, a pre-hook can veto
+//			the change, see hooks.go
+//		(4) If there is an error, it will be returned
 func (t *ToDo) UpdateItem(item ToDoItem) error {
 	//Like the add and delete functions, start by loading the database
 	//into the private map in our struct.  Then make sure the item we
@@ -243,11 +445,18 @@ func (t *ToDo) UpdateItem(item ToDoItem) error {
 	//no errors, this function should return nil at the end to indicate
 	//that the item was properly updated in the database.
 
+	if t.log != nil {
+		return t.putLog(OpUpdate, item)
+	}
 	if err := t.loadDB(); err != nil {
 		return err
 	}
 
-	if _, exists := t.toDoMap[item.Id]; exists {
+	before, exists := t.toDoMap[item.Id]
+	if exists {
+		if err := t.preHooks(&before, &item); err != nil {
+			return err
+		}
 		t.toDoMap[item.Id] = item
 	} else {
 		return errors.New("Couldn't update item. Item does not exist in the map.")
@@ -257,7 +466,7 @@ func (t *ToDo) UpdateItem(item ToDoItem) error {
 		return err
 	}
 
-	return nil
+	return t.changed(OpUpdate, &before, &item)
 }
 
 // GetItem accepts an item id and returns the item from the DB.
@@ -286,6 +495,9 @@ func (t *ToDo) GetItem(id int) (ToDoItem, error) {
 	//as the error value the end to indicate that the item was
 	//properly returned from the database.
 
+	if t.log != nil {
+		return t.getLog(id)
+	}
 	if err := t.loadDB(); err != nil {
 		return ToDoItem{}, err
 	}
@@ -331,6 +543,44 @@ func (t *ToDo) GetAllItems() ([]ToDoItem, error) {
 	return toDoList, nil
 }
 
+// NextId returns the id that should be used for a new item, which is one
+// more than the largest id in the DB, the trash or the archive (or 1 for
+// an empty DB).  Ids of deleted and archived items are not handed out
+// again, so they can still be restored.
+func (t *ToDo) NextId() (int, error) {
+	if err := t.loadDB(); err != nil {
+		return 0, err
+	}
+	ids := make([]int, 0, len(t.toDoMap))
+	for id := range t.toDoMap {
+		ids = append(ids, id)
+	}
+	//Read the trash without dropping expired entries, a lookup shouldn't
+	//change anything
+	var trash []TrashEntry
+	if err := t.readSideFile(t.dbFileName+trashSuffix, &trash); err != nil {
+		return 0, err
+	}
+	for _, entry := range trash {
+		ids = append(ids, entry.Item.Id)
+	}
+	archived, err := t.ArchivedItems()
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range archived {
+		ids = append(ids, entry.Item.Id)
+	}
+
+	next := 1
+	for _, id := range ids {
+		if id >= next {
+			next = id + 1
+		}
+	}
+	return next, nil
+}
+
 // PrintItem accepts a ToDoItem and prints it to the console
 // in a JSON pretty format. As some help, look at the
 // json.MarshalIndent() function from our in class go tutorial.
@@ -358,6 +608,22 @@ func (t *ToDo) JsonToItem(jsonString string) (ToDoItem, error) {
 	if err != nil {
 		return ToDoItem{}, err
 	}
+	if item.RemindBefore != "" {
+		if _, err := duration.Parse(item.RemindBefore); err != nil {
+			return ToDoItem{}, fmt.Errorf("remind_before: %w", err)
+		}
+	}
+	if item.Rank != "" && !validRank(item.Rank) {
+		return ToDoItem{}, fmt.Errorf("rank: %q is not a rank, ranks are made of 0-9 and a-z and don't end with 0", item.Rank)
+	}
+	if item.Estimate != "" {
+		if _, err := ParseEstimate(item.Estimate); err != nil {
+			return ToDoItem{}, fmt.Errorf("estimate: %w", err)
+		}
+	}
+	if containsId(item.DependsOn, item.Id) {
+		return ToDoItem{}, fmt.Errorf("depends_on: item %d can't depend on itself", item.Id)
+	}
 
 	return item, nil
 }
@@ -396,8 +662,10 @@ func (t *ToDo) ChangeItemDoneStatus(id int, value bool) error {
 		return getErr
 	}
 
-	item.IsDone = value
-	return t.UpdateItem(item)
+	//Done is the terminal state of the workflow, the move is recorded
+	//like any other but does not have to be an allowed transition, see
+	//workflow.go
+	return t.UpdateItem(t.WithDone(item, value))
 }
 
 //------------------------------------------------------------
@@ -431,7 +699,7 @@ func initDB(dbFileName string) error {
 
 func (t *ToDo) saveDB() error {
 	//1. Convert our map into a slice
-	//2. Marshal the slice into json
+	//2. Back up the current file
 	//3. Write the json to our file
 
 	//1. Convert our map into a slice
@@ -440,39 +708,123 @@ func (t *ToDo) saveDB() error {
 		toDoList = append(toDoList, item)
 	}
 
-	//2. Marshal the slice into json, lets pretty print it, but
-	//   this is not required
+	//   Keep the file ordered by id, map iteration order is random and we
+	//   want the file to diff (and merge) cleanly between saves
+	sortItems(toDoList)
+
+	//2. Keep a copy of the current content if automatic backups are
+	//   turned on
+	if err := t.backupDB(); err != nil {
+		return err
+	}
+
+	//3. Write the json to our file
+	if err := t.writeDB(toDoList); err != nil {
+		return err
+	}
+	if t.opts.GitHistory {
+		if err := t.commitDB(toDoList); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeDB writes the items to the DB file, lets pretty print it, but this
+// is not required.  The log format is written record by record, saving
+// the whole DB compacts it, see logstore.go
+func (t *ToDo) writeDB(toDoList []ToDoItem) error {
+	if t.log != nil {
+		return t.log.compact(toDoList)
+	}
 	data, err := json.MarshalIndent(toDoList, "", "  ")
 	if err != nil {
 		return err
 	}
+	if data, err = t.encode(data); err != nil {
+		return err
+	}
+	return writeFileAtomic(t.dbFileName, data, t.filePerm())
+}
 
-	//3. Write the json to our file
-	err = os.WriteFile(t.dbFileName, data, 0644)
+// writeFileAtomic writes a file through a temporary file that is renamed
+// over it, so other programs reading the DB (like the live reload of the
+// tui) never see a half written file
+func writeFileAtomic(fileName string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".tmp*")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name())
 
-	return nil
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
 }
 
 func (t *ToDo) loadDB() error {
-	data, err := os.ReadFile(t.dbFileName)
+	if t.log != nil {
+		if err := t.log.sync(); err != nil {
+			return err
+		}
+		items, err := t.log.all()
+		if err != nil {
+			return err
+		}
+		t.toDoMap = items
+		return nil
+	}
+
+	data, err := t.readFile(t.dbFileName)
 	if err != nil {
 		return err
 	}
 
-	//Now let's unmarshal the data into our map
+	//Now let's unmarshal the data into our map.  Start with an empty
+	//map, the file may have been changed since the last load
 	var toDoList []ToDoItem
 	err = json.Unmarshal(data, &toDoList)
 	if err != nil {
-		return err
+		return fmt.Errorf("The DB file %s is damaged: %v. Run 'todo doctor' to check and repair it.", t.dbFileName, err)
 	}
 
 	//Now let's iterate over our slice and add each item to our map
+	t.toDoMap = make(DbMap, len(toDoList))
 	for _, item := range toDoList {
 		t.toDoMap[item.Id] = item
 	}
 
 	return nil
 }
+
+// sortItems sorts a slice of ToDoItems in place by id
+func sortItems(items []ToDoItem) {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Id < items[j].Id
+	})
+}
+
+// readHeader returns the first bytes of a file, enough to tell its format
+func readHeader(fileName string) ([]byte, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 64)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return header[:n], nil
+}
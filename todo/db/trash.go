@@ -0,0 +1,129 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const trashSuffix = ".trash"
+
+// TrashEntry is a deleted item in the trash, together with the time it
+// was deleted
+type TrashEntry struct {
+	Item      ToDoItem  `json:"item"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// Trash returns the deleted items that are still in the trash, oldest
+// deletion first.  Entries older than Options.TrashRetention are removed
+// from the trash file first.
+func (t *ToDo) Trash() ([]TrashEntry, error) {
+	entries, err := t.loadTrash()
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// RestoreFromTrash moves a deleted item back into the DB, the most
+// recently deleted one if the trash holds several items with the id.  It
+// fails if the item is not in the trash, or if its id is in use.
+func (t *ToDo) RestoreFromTrash(id int) error {
+	entries, err := t.loadTrash()
+	if err != nil {
+		return err
+	}
+
+	idx := -1
+	for i, entry := range entries {
+		if entry.Item.Id == id {
+			idx = i
+		}
+	}
+	if idx == -1 {
+		return errors.New("Couldn't restore item. Item is not in the trash.")
+	}
+
+	if err := t.loadDB(); err != nil {
+		return err
+	}
+	if _, exists := t.toDoMap[id]; exists {
+		return fmt.Errorf("Couldn't restore item. Item %d already exists in the map.", id)
+	}
+
+	//Add the item to the DB before removing it from the trash, so that
+	//a failure leaves a duplicate instead of losing the item
+	item := entries[idx].Item
+	t.toDoMap[id] = item
+	if err := t.saveDB(); err != nil {
+		return err
+	}
+	entries = append(entries[:idx], entries[idx+1:]...)
+	if err := t.writeSideFile(t.dbFileName+trashSuffix, entries); err != nil {
+		return err
+	}
+	return t.recordChange(OpRestore, nil, &item)
+}
+
+// EmptyTrash removes every item from the trash for good, together with
+// the files attached only to them, and returns the removed entries
+func (t *ToDo) EmptyTrash() ([]TrashEntry, error) {
+	entries, err := t.loadTrash()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.writeSideFile(t.dbFileName+trashSuffix, []TrashEntry{}); err != nil {
+		return nil, err
+	}
+	if _, err := t.CleanAttachments(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// moveToTrash adds items to the trash, DeleteItem and DeleteItems call it
+// before the items are removed from the DB.  An item with an id that is
+// already in the trash (deleted before, and the id was given to a new item
+// by hand) is added next to the older one, RestoreFromTrash restores the
+// most recently deleted first.
+func (t *ToDo) moveToTrash(items ...ToDoItem) error {
+	entries, err := t.loadTrash()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, item := range items {
+		entries = append(entries, TrashEntry{Item: item, DeletedAt: now})
+	}
+	return t.writeSideFile(t.dbFileName+trashSuffix, entries)
+}
+
+// loadTrash reads the trash file and drops entries that are older than
+// the retention period, and the files attached only to them
+func (t *ToDo) loadTrash() ([]TrashEntry, error) {
+	var entries []TrashEntry
+	if err := t.readSideFile(t.dbFileName+trashSuffix, &entries); err != nil {
+		return nil, err
+	}
+	if t.opts.TrashRetention <= 0 {
+		return entries, nil
+	}
+
+	cutoff := time.Now().Add(-t.opts.TrashRetention)
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.DeletedAt.After(cutoff) {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) != len(entries) {
+		if err := t.writeSideFile(t.dbFileName+trashSuffix, kept); err != nil {
+			return nil, err
+		}
+		if _, err := t.CleanAttachments(); err != nil {
+			return nil, err
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A Workflow is the list of states an item moves through, from the
+// initial (first) to the terminal (last) state, together with the moves
+// between states that are allowed.  Items are done exactly when they are
+// in the terminal state, so IsDone keeps working for tools and DB files
+// that know nothing about states.
+type Workflow struct {
+	States []string
+
+	// Transitions maps a state to the states it may move to, nil allows
+	// every move
+	Transitions map[string][]string
+}
+
+// Transition records a single move of an item between two states
+type Transition struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// DefaultWorkflow is used when Options.Workflow is not set
+var DefaultWorkflow = Workflow{States: []string{"todo", "in_progress", "review", "done"}}
+
+// ParseWorkflow builds a workflow from a comma separated list of states
+// and a comma separated list of allowed moves written as "from>to", where
+// "*" as from allows the move from every state.  Empty transitions allow
+// every move.
+func ParseWorkflow(states string, transitions string) (Workflow, error) {
+	var w Workflow
+	seen := make(map[string]bool)
+	for _, state := range strings.Split(states, ",") {
+		state = strings.TrimSpace(state)
+		if state == "" || strings.ContainsAny(state, "*> \t") {
+			return Workflow{}, fmt.Errorf("invalid workflow state %q", state)
+		}
+		if seen[state] {
+			return Workflow{}, fmt.Errorf("workflow state %q appears more than once", state)
+		}
+		seen[state] = true
+		w.States = append(w.States, state)
+	}
+	if len(w.States) < 2 {
+		return Workflow{}, fmt.Errorf("a workflow needs at least 2 states, got %q", states)
+	}
+
+	if strings.TrimSpace(transitions) == "" {
+		return w, nil
+	}
+	w.Transitions = make(map[string][]string)
+	for _, move := range strings.Split(transitions, ",") {
+		from, to, ok := strings.Cut(move, ">")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || (from != "*" && !seen[from]) || !seen[to] {
+			return Workflow{}, fmt.Errorf("invalid workflow transition %q, use from>to with the states %s", strings.TrimSpace(move), strings.Join(w.States, ", "))
+		}
+		froms := []string{from}
+		if from == "*" {
+			froms = w.States
+		}
+		for _, from := range froms {
+			if from != to {
+				w.Transitions[from] = append(w.Transitions[from], to)
+			}
+		}
+	}
+	return w, nil
+}
+
+// Initial returns the state of new items
+func (w Workflow) Initial() string {
+	return w.States[0]
+}
+
+// Terminal returns the state of done items
+func (w Workflow) Terminal() string {
+	return w.States[len(w.States)-1]
+}
+
+// Has reports whether state is a state of the workflow
+func (w Workflow) Has(state string) bool {
+	for _, s := range w.States {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether an item may move from one state to another
+func (w Workflow) Allowed(from string, to string) bool {
+	if !w.Has(to) || from == to {
+		return false
+	}
+	if w.Transitions == nil {
+		return true
+	}
+	for _, allowed := range w.Transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusOf returns the state of an item.  Items without a (known) status,
+// such as items written before workflows existed, are in the initial or
+// the terminal state depending on IsDone.
+func (w Workflow) StatusOf(item ToDoItem) string {
+	if item.Status != "" && w.Has(item.Status) && (item.Status == w.Terminal()) == item.IsDone {
+		return item.Status
+	}
+	if item.IsDone {
+		return w.Terminal()
+	}
+	return w.Initial()
+}
+
+// Workflow returns the workflow of the DB
+func (t *ToDo) Workflow() Workflow {
+	if len(t.opts.Workflow.States) == 0 {
+		return DefaultWorkflow
+	}
+	return t.opts.Workflow
+}
+
+// MoveItem moves an item to another state of the workflow, if the
+// workflow allows it.  The move is recorded in the transitions of the
+// item, and moving into (or out of) the terminal state marks the item done
+// (or not done).
+func (t *ToDo) MoveItem(id int, state string) error {
+	w := t.Workflow()
+	if !w.Has(state) {
+		return fmt.Errorf("Unknown state %q, the states are %s.", state, strings.Join(w.States, ", "))
+	}
+
+	item, err := t.GetItem(id)
+	if err != nil {
+		return err
+	}
+	from := w.StatusOf(item)
+	if from == state {
+		return fmt.Errorf("Item %d is already in state %q.", id, state)
+	}
+	if !w.Allowed(from, state) {
+		return fmt.Errorf("Item %d cannot move from %q to %q.", id, from, state)
+	}
+	return t.UpdateItem(setStatus(w, item, state))
+}
+
+// setStatus puts an item into a state without checking the transitions
+// and records the move.  A running timer is stopped when the item is done.
+func setStatus(w Workflow, item ToDoItem, state string) ToDoItem {
+	from := w.StatusOf(item)
+	if from == state {
+		return item
+	}
+
+	now := time.Now().UTC()
+	item.Status = state
+	item.Transitions = append(append([]Transition(nil), item.Transitions...), Transition{From: from, To: state, At: now})
+
+	//Remember when the item was completed, archiving and the reports
+	//rely on it
+	item.IsDone = state == w.Terminal()
+	if item.IsDone {
+		item.DoneAt = &now
+		//Work on a done item is over, log the time of a running timer
+		if item.TimerStart != nil {
+			item.TimeEntries = append(append([]TimeEntry(nil), item.TimeEntries...), TimeEntry{Start: *item.TimerStart, End: now})
+			item.TimerStart = nil
+		}
+	} else {
+		item.DoneAt = nil
+	}
+	return item
+}
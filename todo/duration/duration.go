@@ -0,0 +1,68 @@
+// Package duration parses the durations used on the command line and in
+// the configuration, which are usually days or weeks.
+package duration
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Day  = 24 * time.Hour
+	Week = 7 * Day
+)
+
+// Parse works like time.ParseDuration, but also understands days
+// and weeks ("3d", "2w", "1w2d") which are far more useful for todo items
+// than hours.  A plain number is a number of days.
+func Parse(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * Day, nil
+	}
+
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		i := strings.IndexAny(rest, "dw")
+		if i == -1 {
+			break
+		}
+		n, err := strconv.Atoi(rest[:i])
+		if err != nil {
+			// Not a day/week prefix, let time.ParseDuration handle it
+			break
+		}
+		unit := Day
+		if rest[i] == 'w' {
+			unit = Week
+		}
+		total += time.Duration(n) * unit
+		rest = rest[i+1:]
+	}
+
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q, use for example 30m, 2h, 3d or 1w", s)
+		}
+		total += d
+	}
+	return total, nil
+}
+
+// Format formats a duration in hours and minutes ("1h25m", "40m"), which
+// is how time spent on items is usually written
+func Format(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours, minutes := int(d/time.Hour), int(d%time.Hour/time.Minute)
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	if minutes == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh%02dm", hours, minutes)
+}
@@ -4,28 +4,55 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
+	"drexel.edu/todo/config"
 	"drexel.edu/todo/db"
+	"drexel.edu/todo/duration"
+	"drexel.edu/todo/workspace"
 )
 
 // Global variables to hold the command line flags to drive the todo CLI
 // application
 var (
 	dbFileNameFlag string
+	formatFlag     string
 	restoreDbFlag  bool
 	listFlag       bool
+	allFlag        bool
 	itemStatusFlag bool
 	queryFlag      int
 	addFlag        string
+	forceFlag      bool
 	updateFlag     string
 	deleteFlag     int
 	rootCmd        = &cobra.Command{
 		Use:   "todo",
 		Short: "A CLI that keeps track of your ToDo items",
+		// The root command itself does nothing, the flags are processed
+		// after Execute returns in processCmdLineFlags.  Having a Run
+		// function keeps cobra from printing the help text on every run
+		// now that the root command also has subcommands.
+		Run: func(cmd *cobra.Command, args []string) {},
+		// Errors are printed by main, and only flag errors should show
+		// the usage text, not failures of an otherwise valid command
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return loadConfig(cmd)
+		},
 	}
+
+	// cfg is the effective configuration, loaded before any command runs
+	cfg *config.Config
+
+	// workflow holds the states items move through, from the workflow.*
+	// settings
+	workflow db.Workflow
 )
 
 type AppOptType int
@@ -42,6 +69,7 @@ const (
 	UPDATE_DB_ITEM
 	DELETE_DB_ITEM
 	CHANGE_ITEM_STATUS
+	SUBCOMMAND_EXECUTED
 	NOT_IMPLEMENTED
 	INVALID_APP_OPT
 )
@@ -70,20 +98,32 @@ const (
 //					If there is an error, it will be returned along with the
 //					INVALID_APP_OPT constant.
 func processCmdLineFlags() (AppOptType, error) {
-	rootCmd.PersistentFlags().StringVar(&dbFileNameFlag, "db", "./data/todo.json", "Name of the database file")
-	rootCmd.PersistentFlags().BoolVarP(&restoreDbFlag, "restore", "r", false, "Restore the database from the backup file")
-	rootCmd.PersistentFlags().BoolVarP(&listFlag, "list", "l", false, "List all the items in the database")
-	rootCmd.PersistentFlags().IntVarP(&queryFlag, "query", "q", 0, "Query an item in the database")
-	rootCmd.PersistentFlags().StringVarP(&addFlag, "add", "a", "", "Add an item to the database")
-	rootCmd.PersistentFlags().StringVarP(&updateFlag, "update", "u", "", "Update an item in the database")
-	rootCmd.PersistentFlags().IntVarP(&deleteFlag, "delete", "d", 0, "Delete an item from the database")
-	rootCmd.PersistentFlags().BoolVarP(&itemStatusFlag, "statuschange", "s", false, "Change item 'done' status to true or false. Must be used in conjunction with -q to specify the item.")
-
-	err := rootCmd.Execute()
+	// The db flag is shared with all subcommands, the other flags are
+	// only valid on the root command itself
+	rootCmd.PersistentFlags().StringVar(&dbFileNameFlag, "db", "", "Name of the database file (default: found from the current directory, see 'todo where')")
+	rootCmd.PersistentFlags().StringVar(&formatFlag, "format", "", "Output format of listed items: json or text (default from config, json)")
+	rootCmd.Flags().BoolVarP(&restoreDbFlag, "restore", "r", false, "Restore the database from the backup file")
+	rootCmd.Flags().BoolVarP(&listFlag, "list", "l", false, "List all the items in the database")
+	rootCmd.Flags().BoolVar(&allFlag, "all", false, "Also list snoozed items, use with -l")
+	rootCmd.Flags().IntVarP(&queryFlag, "query", "q", 0, "Query an item in the database")
+	rootCmd.Flags().StringVarP(&addFlag, "add", "a", "", "Add an item to the database")
+	rootCmd.Flags().BoolVar(&forceFlag, "force", false, "Add the item even if it looks like a duplicate, use with -a")
+	rootCmd.Flags().StringVarP(&updateFlag, "update", "u", "", "Update an item in the database")
+	rootCmd.Flags().IntVarP(&deleteFlag, "delete", "d", 0, "Delete an item from the database")
+	rootCmd.Flags().BoolVarP(&itemStatusFlag, "statuschange", "s", false, "Change item 'done' status to true or false. Must be used in conjunction with -q to specify the item.")
+	registerCompletions()
+
+	cmd, err := rootCmd.ExecuteC()
 	if err != nil {
 		return INVALID_APP_OPT, err
 	}
 
+	// Subcommands (see the cmd_*.go files) do all of their work inside
+	// of Execute, so there is nothing left for main to do
+	if cmd != rootCmd {
+		return SUBCOMMAND_EXECUTED, nil
+	}
+
 	var appOpt AppOptType = INVALID_APP_OPT
 
 	//show help if no flags are set
@@ -106,6 +146,10 @@ func processCmdLineFlags() (AppOptType, error) {
 		switch f.Name {
 		case "list":
 			appOpt = LIST_DB_ITEM
+		case "all":
+			// Only changes what -l lists
+		case "force":
+			// Only changes what -a does
 		case "restore":
 			appOpt = RESTORE_DB_ITEM
 		case "query":
@@ -162,8 +206,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	if opts == SUBCOMMAND_EXECUTED {
+		return
+	}
+
 	//Create a new db object
-	todo, err := db.New(dbFileNameFlag)
+	todo, err := openDB()
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -186,9 +234,10 @@ func main() {
 			fmt.Println("Error: ", err)
 			break
 		}
-		for _, item := range todoList {
-			todo.PrintItem(item)
+		if !allFlag {
+			todoList = db.VisibleItems(todoList, time.Now())
 		}
+		printItems(todo, todoList)
 		fmt.Println("THERE ARE", len(todoList), "ITEMS IN THE DB")
 		fmt.Println("Ok")
 
@@ -199,7 +248,7 @@ func main() {
 			fmt.Println("Error: ", err)
 			break
 		}
-		todo.PrintItem(item)
+		printItem(todo, item)
 		fmt.Println("Ok")
 	case ADD_DB_ITEM:
 		fmt.Println("Running ADD_DB_ITEM...")
@@ -209,6 +258,16 @@ func main() {
 			fmt.Println("Error: ", err)
 			break
 		}
+		if item.CreatedAt == nil {
+			now := time.Now().UTC()
+			item.CreatedAt = &now
+		}
+		if !forceFlag {
+			if err := checkDuplicates(todo, item); err != nil {
+				fmt.Println("Error: ", err)
+				break
+			}
+		}
 		if err := todo.AddItem(item); err != nil {
 			fmt.Println("Error: ", err)
 			break
@@ -247,3 +306,121 @@ func main() {
 		fmt.Println("INVALID_APP_OPT")
 	}
 }
+
+// loadConfig builds the effective configuration from the config files,
+// TODO_* environment variables and the command line flags
+func loadConfig(cmd *cobra.Command) error {
+	globalFile, err := config.GlobalFile()
+	if err != nil {
+		return err
+	}
+	loc, err := workspace.Find(".")
+	if err != nil {
+		return err
+	}
+
+	// Only flags that were set override the lower layers
+	flags := make(map[string]string)
+	for flagName, key := range map[string]string{"db": "db.path", "format": "output.format"} {
+		if f := cmd.Root().PersistentFlags().Lookup(flagName); f.Changed {
+			flags[key] = f.Value.String()
+		}
+	}
+
+	//A broken config file must not keep the config commands from
+	//repairing it
+	lenient := cmd == configCmd || cmd.Parent() == configCmd
+	cfg, err = config.Load(config.Options{
+		GlobalFile:    globalFile,
+		WorkspaceFile: loc.ConfigFile(),
+		Flags:         flags,
+		Lenient:       lenient,
+	})
+	if err != nil {
+		return err
+	}
+	for _, warning := range cfg.Warnings() {
+		fmt.Fprintln(os.Stderr, "Warning:", warning)
+	}
+
+	workflow, err = db.ParseWorkflow(cfg.Get("workflow.states"), cfg.Get("workflow.transitions"))
+	if err != nil && lenient {
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+		workflow, err = db.DefaultWorkflow, nil
+	}
+	return err
+}
+
+// openDB opens the database set with the --db flag or in the config, or
+// found by the workspace discovery otherwise.  Subcommands should use
+// this instead of calling db.New directly so that every command resolves
+// and configures the database the same way.
+func openDB() (*db.ToDo, error) {
+	loc, err := dbLocation()
+	if err != nil {
+		return nil, err
+	}
+	opts := dbOptions()
+	opts.HooksDir = hooksDir(loc)
+	opts.GitHistory = cfg.Get("history.git") == "true"
+	return newDB(loc, opts)
+}
+
+// newDB opens the database of loc, which creates the file if it doesn't
+// exist yet.  The directory of the global database is created with it.
+func newDB(loc workspace.Location, opts db.Options) (*db.ToDo, error) {
+	if loc.Source == workspace.FromGlobal {
+		if err := os.MkdirAll(filepath.Dir(loc.Path), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return db.NewWithOptions(loc.Path, opts)
+}
+
+// hooksDir returns the directory with the hooks of a database, set with
+// hooks.dir or a hooks directory next to the database file.  A workspace
+// is checked in with its project, so the hooks directory of a workspace
+// database would run whatever a cloned repository brings along.  Those
+// databases only get hooks set with hooks.dir, which a workspace config
+// can't set.
+func hooksDir(loc workspace.Location) string {
+	if dir := cfg.Get("hooks.dir"); dir != "" {
+		return dir
+	}
+	if loc.Root != "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(loc.Path), "hooks")
+}
+
+// sideDir returns a directory set in the config, or the directory with
+// the given name next to the database file
+func sideDir(dbPath string, key string, name string) string {
+	if dir := cfg.Get(key); dir != "" {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(dbPath), name)
+}
+
+// dbOptions returns the db.Options matching the configuration, for
+// commands that open database files other than the one from dbLocation
+func dbOptions() db.Options {
+	return db.Options{
+		BackupRetention: cfg.Int("backup.retention"),
+		TrashRetention:  time.Duration(cfg.Int("trash.retention_days")) * duration.Day,
+		Workflow:        workflow,
+		Passphrase:      passphrase,
+	}
+}
+
+// dbLocation returns the database file to use and how it was found
+func dbLocation() (workspace.Location, error) {
+	if dbPath := cfg.Get("db.path"); dbPath != "" {
+		source := workspace.FromConfig
+		if cfg.Source("db.path") == config.FromFlag {
+			source = workspace.FromFlag
+		}
+		return workspace.Location{Path: dbPath, Source: source}, nil
+	}
+	return workspace.Find(".")
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+const (
+	colorReset = "\033[0m"
+	colorGreen = "\033[32m"
+	colorDim   = "\033[2m"
+)
+
+// printItems prints a list of items in the configured output format and
+// in the order of list.sort.  The json format prints every item with
+// ToDo.PrintItem, the text format prints one line per item.
+func printItems(todo *db.ToDo, items []db.ToDoItem) {
+	printSortedItems(todo, items, cfg.Get("list.sort"))
+}
+
+// printSortedItems prints a list of items like printItems, ordered by id
+// or rank
+func printSortedItems(todo *db.ToDo, items []db.ToDoItem, by string) {
+	sorted := append([]db.ToDoItem(nil), items...)
+	sortItems(sorted, by)
+	if cfg.Get("output.format") != "text" {
+		todo.PrintAllItems(sorted)
+		return
+	}
+	for _, item := range sorted {
+		fmt.Println(formatItemLine(item))
+	}
+}
+
+// sortItems orders items by id or by rank
+func sortItems(items []db.ToDoItem, by string) {
+	if by == "rank" {
+		db.SortByRank(items)
+		return
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Id < items[j].Id
+	})
+}
+
+// printItem prints a single item in the configured output format
+func printItem(todo *db.ToDo, item db.ToDoItem) {
+	printItems(todo, []db.ToDoItem{item})
+}
+
+// formatItemLine formats an item as a single line for the text output
+// format, for example "[x]    2  Learn Kubernetes"
+func formatItemLine(item db.ToDoItem) string {
+	check := "[ ]"
+	if item.IsDone {
+		check = "[x]"
+	}
+	line := fmt.Sprintf("%s %4d  %s", check, item.Id, item.Title)
+	//Items in the first and last state are already told apart by the
+	//check box, only show the states in between
+	if status := workflow.StatusOf(item); status != workflow.Initial() && status != workflow.Terminal() {
+		line += "  [" + status + "]"
+	}
+	if item.ParentId != 0 {
+		line += fmt.Sprintf("  (part of %d)", item.ParentId)
+	}
+	if item.Priority > 0 {
+		line += fmt.Sprintf("  P%d", item.Priority)
+	}
+	for _, tag := range item.Tags {
+		line += "  #" + tag
+	}
+	for _, assignee := range item.Assignees {
+		line += "  @" + assignee
+	}
+	if item.Estimate != "" {
+		line += "  ~" + item.Estimate
+	}
+	if len(item.DependsOn) > 0 && !item.IsDone {
+		var ids []string
+		for _, id := range item.DependsOn {
+			ids = append(ids, strconv.Itoa(id))
+		}
+		line += "  (after " + strings.Join(ids, ", ") + ")"
+	}
+	if item.Due != nil && !item.IsDone {
+		line += "  (due " + formatDate(*item.Due) + ")"
+	}
+	if item.IsDeferred(time.Now()) {
+		line += "  (snoozed until " + formatDate(*item.DeferUntil) + ")"
+	}
+	if item.TimerStart != nil {
+		line += "  (timer running)"
+	}
+	if item.DoneAt != nil {
+		line += "  (done " + formatDate(*item.DoneAt) + ")"
+	}
+
+	if !useColor() {
+		return line
+	}
+	if item.IsDone {
+		return colorDim + line + colorReset
+	}
+	return colorGreen + check + colorReset + line[len(check):]
+}
+
+// useColor decides if the text output is colored, "auto" colors only when
+// stdout is a terminal and NO_COLOR is not set
+func useColor() bool {
+	switch cfg.Get("output.color") {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if _, noColor := os.LookupEnv("NO_COLOR"); noColor {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// formatDate formats a date for the text output using output.date_format
+func formatDate(t time.Time) string {
+	return t.Local().Format(cfg.Get("output.date_format"))
+}
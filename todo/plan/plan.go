@@ -0,0 +1,159 @@
+// Package plan proposes what to work on next: the open items that fit
+// into a capacity, such as the hours of a week or the points of a sprint,
+// the most urgent first and every item after the items it depends on.
+package plan
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// Skipped is an open item that was left out of the plan and why
+type Skipped struct {
+	Item   db.ToDoItem `json:"item"`
+	Reason string      `json:"reason"`
+}
+
+// Plan is the outcome of Propose
+type Plan struct {
+	Capacity db.Estimate `json:"capacity"`
+
+	// Items are the planned items in the order to work on them, Planned
+	// is the sum of their estimates and Left what remains of the capacity
+	Items   []db.ToDoItem `json:"items"`
+	Planned float64       `json:"planned"`
+	Left    float64       `json:"left"`
+
+	// Skipped are the other open items, the most urgent first
+	Skipped []Skipped `json:"skipped"`
+}
+
+// Propose fills capacity with open items, taken by priority (1 first, no
+// priority last), then by due date (the earliest first, no due date last)
+// and then by id.  Only items estimated in the unit of capacity are
+// planned, snoozed items are not.  An item is only planned after the open
+// items it depends on, if those are left out so is the item.  Items that
+// do not fit are skipped and smaller ones after them may still be
+// planned.  Dependencies on items that are not in items count as done.
+func Propose(items []db.ToDoItem, capacity db.Estimate, now time.Time) Plan {
+	p := Plan{Capacity: capacity, Left: capacity.Amount}
+
+	byId := make(map[int]db.ToDoItem, len(items))
+	for _, item := range items {
+		byId[item.Id] = item
+	}
+
+	var candidates []db.ToDoItem
+	isCandidate := make(map[int]bool)
+	for _, item := range items {
+		if item.IsDone {
+			continue
+		}
+		e, ok := item.Effort()
+		switch {
+		case item.IsDeferred(now):
+			p.skip(item, "snoozed")
+		case !ok:
+			p.skip(item, "not estimated")
+		case e.Unit != capacity.Unit:
+			p.skip(item, "estimated in "+unitName(e.Unit))
+		default:
+			candidates = append(candidates, item)
+			isCandidate[item.Id] = true
+		}
+	}
+	sortByUrgency(candidates)
+
+	//Decide on the most urgent item whose dependencies are decided, until
+	//no item is left whose dependencies are
+	decided := make(map[int]bool)
+	planned := make(map[int]bool)
+	for progress := true; progress; {
+		progress = false
+		for _, item := range candidates {
+			if decided[item.Id] {
+				continue
+			}
+			waiting, blocker := false, 0
+			for _, dep := range item.DependsOn {
+				other, exists := byId[dep]
+				if !exists || other.IsDone || planned[dep] {
+					continue
+				}
+				if isCandidate[dep] && !decided[dep] {
+					waiting = true
+					continue
+				}
+				blocker = dep
+				break
+			}
+			if blocker == 0 && waiting {
+				continue
+			}
+
+			e, _ := item.Effort()
+			switch {
+			case blocker != 0:
+				p.skip(item, fmt.Sprintf("waits for #%d, which is not planned", blocker))
+			case e.Amount > p.Left+1e-9:
+				p.skip(item, fmt.Sprintf("does not fit, %s left", db.Estimate{Amount: p.Left, Unit: capacity.Unit}))
+			default:
+				p.Items = append(p.Items, item)
+				p.Planned += e.Amount
+				p.Left -= e.Amount
+				planned[item.Id] = true
+			}
+			decided[item.Id] = true
+			progress = true
+			break
+		}
+	}
+
+	//What is left depends on itself through other items
+	for _, item := range candidates {
+		if !decided[item.Id] {
+			p.skip(item, "its dependencies form a cycle")
+		}
+	}
+
+	if p.Left < 0 {
+		p.Left = 0
+	}
+	sort.SliceStable(p.Skipped, func(i, j int) bool {
+		return moreUrgent(p.Skipped[i].Item, p.Skipped[j].Item)
+	})
+	return p
+}
+
+func (p *Plan) skip(item db.ToDoItem, reason string) {
+	p.Skipped = append(p.Skipped, Skipped{Item: item, Reason: reason})
+}
+
+func sortByUrgency(items []db.ToDoItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return moreUrgent(items[i], items[j])
+	})
+}
+
+func moreUrgent(a, b db.ToDoItem) bool {
+	if a.Priority != b.Priority {
+		return a.Priority != 0 && (b.Priority == 0 || a.Priority < b.Priority)
+	}
+	if (a.Due == nil) != (b.Due == nil) {
+		return a.Due != nil
+	}
+	if a.Due != nil && !a.Due.Equal(*b.Due) {
+		return a.Due.Before(*b.Due)
+	}
+	return a.Id < b.Id
+}
+
+func unitName(unit string) string {
+	if unit == db.UnitHours {
+		return "hours"
+	}
+	return "points"
+}
@@ -0,0 +1,47 @@
+package remind
+
+import (
+	"sort"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// Agenda groups the open items with a due date by when they are due.
+// Every group is sorted by due date.
+type Agenda struct {
+	Overdue  []db.ToDoItem `json:"overdue"`
+	Today    []db.ToDoItem `json:"today"`
+	ThisWeek []db.ToDoItem `json:"this_week"`
+}
+
+// NewAgenda builds the agenda at now.  Items due earlier today but not
+// done are overdue, "this week" covers the 6 days after today.
+func NewAgenda(items []db.ToDoItem, now time.Time) Agenda {
+	var agenda Agenda
+
+	now = now.Local()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	weekEnd := tomorrow.AddDate(0, 0, 6)
+
+	for _, item := range items {
+		if item.IsDone || item.Due == nil {
+			continue
+		}
+		switch due := *item.Due; {
+		case due.Before(now):
+			agenda.Overdue = append(agenda.Overdue, item)
+		case due.Before(tomorrow):
+			agenda.Today = append(agenda.Today, item)
+		case due.Before(weekEnd):
+			agenda.ThisWeek = append(agenda.ThisWeek, item)
+		}
+	}
+
+	for _, group := range [][]db.ToDoItem{agenda.Overdue, agenda.Today, agenda.ThisWeek} {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Due.Before(*group[j].Due)
+		})
+	}
+	return agenda
+}
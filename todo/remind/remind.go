@@ -0,0 +1,180 @@
+// Package remind tells what is due: it groups open items into an agenda
+// and finds the reminders that have to be sent for items reaching their
+// due date, remembering which reminders already fired.
+package remind
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"time"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/duration"
+)
+
+// The kinds of reminders
+const (
+	// KindBefore is the early reminder, RemindBefore (or the default
+	// offset) ahead of the due date
+	KindBefore = "before"
+	// KindDue is sent when the due date is reached
+	KindDue = "due"
+)
+
+// StateSuffix is appended to the DB file name to get the file that
+// records the fired reminders
+const StateSuffix = ".reminders"
+
+// Reminder is a notification for a single item
+type Reminder struct {
+	Item db.ToDoItem `json:"item"`
+	Kind string      `json:"kind"`
+	At   time.Time   `json:"at"`
+}
+
+// Key identifies a reminder in the state.  The due date is part of the
+// key, so moving the due date of an item arms its reminders again.
+func (r Reminder) Key() string {
+	return fmt.Sprintf("%d/%s/%s", r.Item.Id, r.Kind, r.Item.Due.UTC().Format(time.RFC3339))
+}
+
+// Message is the text of the reminder
+func (r Reminder) Message() string {
+	if r.Kind == KindBefore {
+		return fmt.Sprintf("item %d %q is due %s", r.Item.Id, r.Item.Title, r.Item.Due.Local().Format("2006-01-02 15:04"))
+	}
+	return fmt.Sprintf("item %d %q is due now", r.Item.Id, r.Item.Title)
+}
+
+// Pending returns the reminders of the open items that are due at now
+// and have not fired yet, oldest first.  defaultOffset is used for items
+// without RemindBefore, 0 means they only get a reminder on the due date.
+func Pending(items []db.ToDoItem, state *State, now time.Time, defaultOffset time.Duration) ([]Reminder, error) {
+	var pending []Reminder
+	for _, item := range items {
+		if item.IsDone || item.Due == nil {
+			continue
+		}
+
+		offset := defaultOffset
+		if item.RemindBefore != "" {
+			var err error
+			if offset, err = duration.Parse(item.RemindBefore); err != nil {
+				return nil, fmt.Errorf("item %d: remind_before: %w", item.Id, err)
+			}
+		}
+
+		due := Reminder{Item: item, Kind: KindDue, At: *item.Due}
+		if !now.Before(due.At) {
+			//Once an item is due the early reminder is pointless
+			if !state.Fired(due.Key()) {
+				pending = append(pending, due)
+			}
+			continue
+		}
+		if offset > 0 {
+			before := Reminder{Item: item, Kind: KindBefore, At: item.Due.Add(-offset)}
+			if !now.Before(before.At) && !state.Fired(before.Key()) {
+				pending = append(pending, before)
+			}
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].At.Before(pending[j].At)
+	})
+	return pending, nil
+}
+
+// Notify sends a reminder.  If command is set it is run by the shell with
+// the reminder in the environment variables TODO_ID, TODO_TITLE, TODO_DUE
+// (RFC 3339), TODO_REMINDER (the kind) and TODO_MESSAGE.  Otherwise the
+// message is written to log.
+func Notify(r Reminder, command string, log io.Writer) error {
+	if command == "" {
+		_, err := fmt.Fprintf(log, "%s REMINDER %s\n", time.Now().Format(time.RFC3339), r.Message())
+		return err
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"TODO_ID="+strconv.Itoa(r.Item.Id),
+		"TODO_TITLE="+r.Item.Title,
+		"TODO_DUE="+r.Item.Due.Format(time.RFC3339),
+		"TODO_REMINDER="+r.Kind,
+		"TODO_MESSAGE="+r.Message(),
+	)
+	cmd.Stdout = log
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("remind.command for item %d: %w", r.Item.Id, err)
+	}
+	return nil
+}
+
+// State records the reminders that fired, keyed by Reminder.Key
+type State struct {
+	fileName string
+	fired    map[string]time.Time
+}
+
+// LoadState reads the state file, a missing file means nothing fired yet
+func LoadState(fileName string) (*State, error) {
+	s := &State{fileName: fileName, fired: make(map[string]time.Time)}
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.fired); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return s, nil
+}
+
+// Fired reports whether a reminder already fired
+func (s *State) Fired(key string) bool {
+	_, fired := s.fired[key]
+	return fired
+}
+
+// MarkFired records that a reminder fired at the given time
+func (s *State) MarkFired(key string, at time.Time) {
+	s.fired[key] = at.UTC()
+}
+
+// Prune forgets the reminders of items that are done or gone, so the
+// state does not grow forever
+func (s *State) Prune(items []db.ToDoItem) {
+	open := make(map[string]bool)
+	for _, item := range items {
+		if item.IsDone || item.Due == nil {
+			continue
+		}
+		for _, kind := range []string{KindBefore, KindDue} {
+			open[Reminder{Item: item, Kind: kind}.Key()] = true
+		}
+	}
+	for key := range s.fired {
+		if !open[key] {
+			delete(s.fired, key)
+		}
+	}
+}
+
+// Save writes the state file
+func (s *State) Save() error {
+	data, err := json.MarshalIndent(s.fired, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.fileName, data, 0644)
+}
@@ -0,0 +1,261 @@
+// Package scan harvests TODO, FIXME and XXX comments from source code and
+// keeps them in sync with the items of a todo database.
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// Comment is a single TODO style comment found in a source file
+type Comment struct {
+	File        string // path relative to the scanned directory, using forward slashes
+	Line        int
+	Kind        string // TODO, FIXME or XXX
+	Text        string
+	Fingerprint string
+}
+
+// Result summarizes the changes Sync made to the database
+type Result struct {
+	Added   []int
+	Updated []int
+	Closed  []int
+}
+
+const (
+	// Files larger than this are most likely generated or data files
+	maxFileSize = 1 << 20
+)
+
+var (
+	// A comment marker followed by the keyword, an optional "(owner)" and
+	// an optional colon.  The keyword must be upper case, so prose such as
+	// "// todo list" is not picked up.
+	commentPattern = regexp.MustCompile(`(?://|#|/\*|\*|--|;|<!--)\s*(TODO|FIXME|XXX)\b(?:\([^)]*\))?:?\s*(.*)$`)
+
+	// Directories that never contain source code worth scanning
+	skippedDirs = map[string]bool{
+		"node_modules": true,
+		"vendor":       true,
+	}
+)
+
+// Dir walks the directory tree below root and returns every TODO, FIXME
+// and XXX comment found in text files.  Hidden directories (such as .git),
+// vendor and node_modules directories, binary files and very large files
+// are skipped.
+func Dir(root string) ([]Comment, error) {
+	var comments []Comment
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || skippedDirs[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		found, err := scanFile(path, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		comments = append(comments, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Sync creates or updates a ToDoItem for every comment found below root
+// and marks items done whose comment has disappeared.  Only items that
+// were created by scanning the same root are considered, so scanning
+// several repositories into one database works as expected.  Items whose
+// comment shows up again after being closed are reopened.
+func Sync(todo *db.ToDo, root string, comments []Comment) (Result, error) {
+	var result Result
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return result, err
+	}
+
+	items, err := todo.GetAllItems()
+	if err != nil {
+		return result, err
+	}
+	existing := make(map[string]db.ToDoItem)
+	for _, item := range items {
+		if item.Source != nil && item.Source.Root == absRoot {
+			existing[item.Source.Fingerprint] = item
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range comments {
+		seen[c.Fingerprint] = true
+		source := &db.SourceRef{
+			Root:        absRoot,
+			File:        c.File,
+			Line:        c.Line,
+			Kind:        c.Kind,
+			Fingerprint: c.Fingerprint,
+		}
+
+		item, exists := existing[c.Fingerprint]
+		if !exists {
+			id, err := todo.NextId()
+			if err != nil {
+				return result, err
+			}
+			now := time.Now().UTC()
+			newItem := db.ToDoItem{Id: id, Title: c.title(), CreatedAt: &now, Source: source}
+			if err := todo.AddItem(newItem); err != nil {
+				return result, err
+			}
+			result.Added = append(result.Added, id)
+			continue
+		}
+
+		if !item.IsDone && *item.Source == *source {
+			continue
+		}
+		item.IsDone = false
+		item.DoneAt = nil
+		item.Source = source
+		if err := todo.UpdateItem(item); err != nil {
+			return result, err
+		}
+		result.Updated = append(result.Updated, item.Id)
+	}
+
+	for fingerprint, item := range existing {
+		if seen[fingerprint] || item.IsDone {
+			continue
+		}
+		if err := todo.ChangeItemDoneStatus(item.Id, true); err != nil {
+			return result, err
+		}
+		result.Closed = append(result.Closed, item.Id)
+	}
+
+	return result, nil
+}
+
+// title is the item title used for a comment, comments that only contain
+// the keyword get a title pointing at their location
+func (c Comment) title() string {
+	if c.Text == "" {
+		return fmt.Sprintf("%s in %s:%d", c.Kind, c.File, c.Line)
+	}
+	return c.Text
+}
+
+// scanFile returns the comments in a single file, binary and very large
+// files are silently ignored
+func scanFile(path string, rel string) ([]Comment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxFileSize {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isBinary(data) {
+		return nil, nil
+	}
+
+	var comments []Comment
+	// The same comment can appear several times in one file, the
+	// occurrence number keeps their fingerprints apart
+	occurrences := make(map[string]int)
+
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	lines.Buffer(make([]byte, 0, 64*1024), maxFileSize)
+	for lineNo := 1; lines.Scan(); lineNo++ {
+		match := commentPattern.FindStringSubmatch(lines.Text())
+		if match == nil {
+			continue
+		}
+		kind := match[1]
+		text := cleanText(match[2])
+
+		key := kind + "\x00" + normalize(text)
+		occurrences[key]++
+
+		comments = append(comments, Comment{
+			File:        rel,
+			Line:        lineNo,
+			Kind:        kind,
+			Text:        text,
+			Fingerprint: fingerprint(rel, key, occurrences[key]),
+		})
+	}
+	if err := lines.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return comments, nil
+}
+
+// cleanText removes block comment terminators and surrounding whitespace
+func cleanText(text string) string {
+	text = strings.TrimSpace(text)
+	for _, suffix := range []string{"*/", "-->"} {
+		text = strings.TrimSpace(strings.TrimSuffix(text, suffix))
+	}
+	return text
+}
+
+// normalize makes the fingerprint insensitive to changes in case and
+// whitespace of the comment text
+func normalize(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// fingerprint identifies a comment by its file, keyword, text and
+// occurrence within the file, but not by its line number, so that a
+// comment keeps its fingerprint when code above it changes
+func fingerprint(file string, key string, occurrence int) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s\x00%s\x00%d", file, key, occurrence)))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// isBinary uses the same heuristic as git: a NUL byte in the first few
+// kilobytes means the file is not text
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) != -1
+}
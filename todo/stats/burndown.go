@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// BurndownDay is the estimated work at the end of a day of a burndown
+type BurndownDay struct {
+	Date string `json:"date"`
+
+	// Scope is the work of the items open at the start of the burndown or
+	// added since, Completed the part of it done since the start and
+	// Remaining the rest
+	Scope     float64 `json:"scope"`
+	Completed float64 `json:"completed"`
+	Remaining float64 `json:"remaining"`
+
+	// Ideal is what would remain working at a steady pace that finishes
+	// the work open at the start on the last day
+	Ideal float64 `json:"ideal"`
+}
+
+// Burndown is the outcome of ComputeBurndown
+type Burndown struct {
+	Unit  string        `json:"unit"`
+	Start float64       `json:"start"`
+	Days  []BurndownDay `json:"days"`
+}
+
+// ComputeBurndown follows the estimated work of the items (archived items
+// should be included) from the day from to the day to.  Only items
+// estimated in unit count, with their current estimate.  The creation and
+// completion times are found like in Compute, items without a known
+// creation time count as open from the start.
+func ComputeBurndown(items []db.ToDoItem, history []db.HistoryEntry, unit string, from, to time.Time) Burndown {
+	b := Burndown{Unit: unit}
+	created, completed := timesFromHistory(history)
+	start := startOfDay(from)
+	for day := start; !day.After(startOfDay(to)); day = day.AddDate(0, 0, 1) {
+		b.Days = append(b.Days, BurndownDay{Date: day.Format("2006-01-02")})
+	}
+
+	for _, item := range items {
+		e, ok := item.Effort()
+		if !ok || e.Unit != unit {
+			continue
+		}
+		createdAt := item.CreatedAt
+		if createdAt == nil {
+			if t, ok := created[item.Id]; ok {
+				createdAt = &t
+			}
+		}
+		doneAt := item.DoneAt
+		if doneAt == nil && item.IsDone {
+			t, ok := completed[item.Id]
+			if !ok {
+				//Done at some unknown time, most likely before the start
+				continue
+			}
+			doneAt = &t
+		}
+		if !item.IsDone {
+			doneAt = nil
+		}
+		if doneAt != nil && doneAt.Before(start) {
+			continue
+		}
+
+		if createdAt == nil || createdAt.Before(start) {
+			b.Start += e.Amount
+		}
+		for i := range b.Days {
+			end := start.AddDate(0, 0, i+1)
+			if createdAt != nil && !createdAt.Before(end) {
+				continue
+			}
+			b.Days[i].Scope += e.Amount
+			if doneAt != nil && doneAt.Before(end) {
+				b.Days[i].Completed += e.Amount
+			}
+		}
+	}
+
+	for i := range b.Days {
+		day := &b.Days[i]
+		day.Remaining = day.Scope - day.Completed
+		day.Ideal = b.Start * float64(len(b.Days)-1-i) / float64(len(b.Days))
+	}
+	return b
+}
@@ -0,0 +1,175 @@
+package stats
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+// The HTML report is a single file without external resources, the charts
+// are inline SVG, so it can be mailed or attached to a ticket as it is.
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent":  func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+	"duration": FormatDuration,
+	"date":     func(t time.Time) string { return t.Local().Format("2006-01-02") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>todo report {{date .Report.GeneratedAt}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 60em; color: #222; }
+h1, h2 { font-weight: normal; }
+.summary { display: flex; gap: 2em; }
+.summary div { font-size: 2em; }
+.summary span { display: block; font-size: 0.4em; color: #666; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 1em; text-align: left; border-bottom: 1px solid #ddd; }
+td.n { text-align: right; }
+.created { fill: #8ab4f8; }
+.completed { fill: #34a853; }
+.open { fill: #fbbc04; }
+</style>
+</head>
+<body>
+<h1>todo report</h1>
+<p>Generated {{date .Report.GeneratedAt}}</p>
+<div class="summary">
+<div>{{.Report.Open}}<span>open</span></div>
+<div>{{.Report.Done}}<span>done</span></div>
+<div>{{percent .Report.CompletionRate}}<span>completed</span></div>
+<div>{{if .Report.Completed}}{{duration .Report.AverageTimeToComplete}}{{else}}-{{end}}<span>average time to complete</span></div>
+<div>{{len .Report.Overdue}}<span>overdue</span></div>
+</div>
+
+<h2>Last {{len .Report.Days}} days</h2>
+<svg width="{{.Chart.Width}}" height="{{.Chart.Height}}" role="img" aria-label="items created and completed per day">
+{{range .Chart.Bars}}<rect class="{{.Class}}" x="{{.X}}" y="{{.Y}}" width="{{.Width}}" height="{{.Height}}"><title>{{.Label}}</title></rect>
+{{end}}</svg>
+<p><svg width="10" height="10"><rect class="created" width="10" height="10"/></svg> created
+<svg width="10" height="10"><rect class="completed" width="10" height="10"/></svg> completed</p>
+
+{{if .Report.Overdue}}<h2>Overdue</h2>
+<table>
+<tr><th>Id</th><th>Title</th><th>Due</th></tr>
+{{range .Report.Overdue}}<tr><td class="n">{{.Id}}</td><td>{{.Title}}</td><td>{{date .Due}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Report.ByTag}}<h2>By tag</h2>
+<table>
+<tr><th>Tag</th><th>Open</th><th>Done</th><th></th></tr>
+{{range .Tags}}<tr><td>{{.Name}}</td><td class="n">{{.Open}}</td><td class="n">{{.Done}}</td><td>{{template "bar" .}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>By priority</h2>
+<table>
+<tr><th>Priority</th><th>Open</th><th>Done</th><th></th></tr>
+{{range .Priorities}}<tr><td>{{.Name}}</td><td class="n">{{.Open}}</td><td class="n">{{.Done}}</td><td>{{template "bar" .}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+{{define "bar"}}<svg width="{{.Width}}" height="12"><rect class="completed" width="{{.DoneWidth}}" height="12"/><rect class="open" x="{{.DoneWidth}}" width="{{.OpenWidth}}" height="12"/></svg>{{end}}
+`))
+
+const (
+	chartHeight   = 150
+	chartBarWidth = 8
+	groupBarWidth = 200
+)
+
+type chartBar struct {
+	Class               string
+	X, Y, Width, Height int
+	Label               string
+}
+
+type chart struct {
+	Width, Height int
+	Bars          []chartBar
+}
+
+type groupRow struct {
+	Name                        string
+	Open, Done                  int
+	Width, DoneWidth, OpenWidth int
+}
+
+// WriteHTML writes the report as a self-contained HTML page with charts
+func (r Report) WriteHTML(w io.Writer) error {
+	data := struct {
+		Report     Report
+		Chart      chart
+		Tags       []groupRow
+		Priorities []groupRow
+	}{Report: r, Chart: r.dayChart()}
+
+	largest := 0
+	for _, c := range r.ByTag {
+		largest = max(largest, c.Open+c.Done)
+	}
+	for _, c := range r.ByPriority {
+		largest = max(largest, c.Open+c.Done)
+	}
+	for _, tag := range r.Tags() {
+		data.Tags = append(data.Tags, newGroupRow(tag, r.ByTag[tag], largest))
+	}
+	for _, p := range r.Priorities() {
+		data.Priorities = append(data.Priorities, newGroupRow(PriorityName(p), r.ByPriority[p], largest))
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
+
+// dayChart lays out a bar chart with a created and a completed bar per day
+func (r Report) dayChart() chart {
+	c := chart{Width: len(r.Days) * chartBarWidth * 3, Height: chartHeight}
+	largest := 1
+	for _, day := range r.Days {
+		largest = max(largest, day.Created, day.Completed)
+	}
+	scale := func(n int) int { return n * (chartHeight - 10) / largest }
+
+	for i, day := range r.Days {
+		x := i * chartBarWidth * 3
+		created, completed := scale(day.Created), scale(day.Completed)
+		c.Bars = append(c.Bars,
+			chartBar{"created", x, chartHeight - created, chartBarWidth, created,
+				fmt.Sprintf("%s: %d created", day.Date, day.Created)},
+			chartBar{"completed", x + chartBarWidth, chartHeight - completed, chartBarWidth, completed,
+				fmt.Sprintf("%s: %d completed", day.Date, day.Completed)})
+	}
+	return c
+}
+
+func newGroupRow(name string, c Counts, largest int) groupRow {
+	row := groupRow{Name: name, Open: c.Open, Done: c.Done, Width: groupBarWidth}
+	if largest > 0 {
+		row.DoneWidth = c.Done * groupBarWidth / largest
+		row.OpenWidth = c.Open * groupBarWidth / largest
+	}
+	return row
+}
+
+// PriorityName is the label of a priority in reports
+func PriorityName(priority int) string {
+	if priority == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("P%d", priority)
+}
+
+// FormatDuration formats a duration in days and hours, which is precise
+// enough for the time it takes to complete an item
+func FormatDuration(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int((d % (24 * time.Hour)) / time.Hour)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh", hours)
+	default:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	}
+}
@@ -0,0 +1,193 @@
+// Package stats computes reports about a todo database: how many items
+// are open and done, how fast items get completed, what is overdue and
+// how the work is spread over tags and priorities.
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// Counts holds the number of open and done items of a group
+type Counts struct {
+	Open int `json:"open"`
+	Done int `json:"done"`
+}
+
+// Day holds the activity of a single day
+type Day struct {
+	Date      string `json:"date"`
+	Created   int    `json:"created"`
+	Completed int    `json:"completed"`
+}
+
+// Report is the outcome of Compute
+type Report struct {
+	GeneratedAt time.Time `json:"generated_at"`
+
+	Total int `json:"total"`
+	Open  int `json:"open"`
+	Done  int `json:"done"`
+
+	// CompletionRate is Done / Total, between 0 and 1
+	CompletionRate float64 `json:"completion_rate"`
+
+	// AverageTimeToComplete is the mean time between creating and
+	// completing an item, over all done items where both are known.
+	// Completed counts the items that went into the average.
+	AverageTimeToComplete time.Duration `json:"average_time_to_complete"`
+	Completed             int           `json:"completed_with_known_duration"`
+
+	// Overdue lists the open items past their due date, oldest first
+	Overdue []db.ToDoItem `json:"overdue"`
+
+	ByTag      map[string]Counts `json:"by_tag"`
+	ByPriority map[int]Counts    `json:"by_priority"`
+
+	// Days holds the items created and completed per day, oldest first
+	Days []Day `json:"days"`
+}
+
+// Compute builds a report from the items of the database (archived items
+// should be included, they count as done) and its history.  The creation
+// time of an item is its CreatedAt field, or the time it was first added
+// according to the history.  The completion time is DoneAt, or the time
+// of the last change that marked the item done.  days is the number of
+// days, up to and including today, covered by Report.Days.
+func Compute(items []db.ToDoItem, history []db.HistoryEntry, now time.Time, days int) Report {
+	report := Report{
+		GeneratedAt: now,
+		ByTag:       make(map[string]Counts),
+		ByPriority:  make(map[int]Counts),
+	}
+
+	created, completed := timesFromHistory(history)
+
+	//Day buckets, keyed by date in the local time zone
+	dayIndex := make(map[string]int)
+	today := startOfDay(now)
+	for i := days - 1; i >= 0; i-- {
+		date := today.AddDate(0, 0, -i).Format("2006-01-02")
+		dayIndex[date] = len(report.Days)
+		report.Days = append(report.Days, Day{Date: date})
+	}
+
+	var totalTimeToComplete time.Duration
+	for _, item := range items {
+		report.Total++
+		if item.IsDone {
+			report.Done++
+		} else {
+			report.Open++
+		}
+
+		for _, tag := range item.Tags {
+			report.ByTag[tag] = count(report.ByTag[tag], item.IsDone)
+		}
+		report.ByPriority[item.Priority] = count(report.ByPriority[item.Priority], item.IsDone)
+
+		if !item.IsDone && item.Due != nil && item.Due.Before(now) {
+			report.Overdue = append(report.Overdue, item)
+		}
+
+		createdAt := item.CreatedAt
+		if createdAt == nil {
+			if t, ok := created[item.Id]; ok {
+				createdAt = &t
+			}
+		}
+		doneAt := item.DoneAt
+		if doneAt == nil && item.IsDone {
+			if t, ok := completed[item.Id]; ok {
+				doneAt = &t
+			}
+		}
+
+		if createdAt != nil {
+			if i, ok := dayIndex[createdAt.Local().Format("2006-01-02")]; ok {
+				report.Days[i].Created++
+			}
+		}
+		if item.IsDone && doneAt != nil {
+			if i, ok := dayIndex[doneAt.Local().Format("2006-01-02")]; ok {
+				report.Days[i].Completed++
+			}
+			if createdAt != nil && !doneAt.Before(*createdAt) {
+				totalTimeToComplete += doneAt.Sub(*createdAt)
+				report.Completed++
+			}
+		}
+	}
+
+	if report.Total > 0 {
+		report.CompletionRate = float64(report.Done) / float64(report.Total)
+	}
+	if report.Completed > 0 {
+		report.AverageTimeToComplete = totalTimeToComplete / time.Duration(report.Completed)
+	}
+	sort.Slice(report.Overdue, func(i, j int) bool {
+		return report.Overdue[i].Due.Before(*report.Overdue[j].Due)
+	})
+
+	return report
+}
+
+// Tags returns the tags of the report sorted by name
+func (r Report) Tags() []string {
+	tags := make([]string, 0, len(r.ByTag))
+	for tag := range r.ByTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Priorities returns the priorities of the report, highest (1) first and
+// "no priority" (0) last
+func (r Report) Priorities() []int {
+	priorities := make([]int, 0, len(r.ByPriority))
+	for p := range r.ByPriority {
+		priorities = append(priorities, p)
+	}
+	sort.Slice(priorities, func(i, j int) bool {
+		if priorities[i] == 0 || priorities[j] == 0 {
+			return priorities[j] == 0 && priorities[i] != 0
+		}
+		return priorities[i] < priorities[j]
+	})
+	return priorities
+}
+
+// timesFromHistory finds, per item id, the first time it was added and
+// the last time it was marked done
+func timesFromHistory(history []db.HistoryEntry) (map[int]time.Time, map[int]time.Time) {
+	created := make(map[int]time.Time)
+	completed := make(map[int]time.Time)
+	for _, entry := range history {
+		if entry.Op == db.OpAdd {
+			if _, seen := created[entry.Id]; !seen {
+				created[entry.Id] = entry.Time
+			}
+		}
+		if entry.After != nil && entry.After.IsDone && (entry.Before == nil || !entry.Before.IsDone) {
+			completed[entry.Id] = entry.Time
+		}
+	}
+	return created, completed
+}
+
+func count(c Counts, done bool) Counts {
+	if done {
+		c.Done++
+	} else {
+		c.Open++
+	}
+	return c
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
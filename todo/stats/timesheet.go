@@ -0,0 +1,125 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// Untagged is the tag the time of items without tags is reported under
+const Untagged = "(untagged)"
+
+// TimesheetRow is the time spent on one item on one day
+type TimesheetRow struct {
+	Date     string        `json:"date"`
+	Id       int           `json:"id"`
+	Title    string        `json:"title"`
+	Tags     []string      `json:"tags,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Total is the time spent on a group of rows, a day or a tag
+type Total struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Timesheet is the time logged on items in a period
+type Timesheet struct {
+	Rows  []TimesheetRow `json:"rows"`
+	ByDay []Total        `json:"by_day"`
+	ByTag []Total        `json:"by_tag"`
+	Total time.Duration  `json:"total"`
+}
+
+// NewTimesheet collects the time entries of the items that started in
+// [from, to).  Entries count for the day (in the local time zone) they
+// started on.  The rows are sorted by day and id, the totals by day and
+// by tag name.
+func NewTimesheet(items []db.ToDoItem, from, to time.Time) Timesheet {
+	type key struct {
+		date string
+		id   int
+	}
+	perItemDay := make(map[key]*TimesheetRow)
+	byDay := make(map[string]time.Duration)
+	byTag := make(map[string]time.Duration)
+
+	var ts Timesheet
+	for _, item := range items {
+		for _, entry := range item.TimeEntries {
+			if entry.Start.Before(from) || !entry.Start.Before(to) {
+				continue
+			}
+			d := entry.Duration()
+			date := entry.Start.Local().Format("2006-01-02")
+
+			k := key{date, item.Id}
+			if perItemDay[k] == nil {
+				perItemDay[k] = &TimesheetRow{Date: date, Id: item.Id, Title: item.Title, Tags: item.Tags}
+			}
+			perItemDay[k].Duration += d
+			byDay[date] += d
+			if len(item.Tags) == 0 {
+				byTag[Untagged] += d
+			}
+			for _, tag := range item.Tags {
+				byTag[tag] += d
+			}
+			ts.Total += d
+		}
+	}
+
+	for _, row := range perItemDay {
+		ts.Rows = append(ts.Rows, *row)
+	}
+	sort.Slice(ts.Rows, func(i, j int) bool {
+		if ts.Rows[i].Date != ts.Rows[j].Date {
+			return ts.Rows[i].Date < ts.Rows[j].Date
+		}
+		return ts.Rows[i].Id < ts.Rows[j].Id
+	})
+	ts.ByDay = totals(byDay)
+	ts.ByTag = totals(byTag)
+	return ts
+}
+
+// WriteCSV writes one line per row with the columns date, id, title,
+// tags (separated by ";") and minutes, after a header line
+func (ts Timesheet) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"date", "id", "title", "tags", "minutes"}); err != nil {
+		return err
+	}
+	for _, row := range ts.Rows {
+		err := out.Write([]string{
+			row.Date,
+			strconv.Itoa(row.Id),
+			row.Title,
+			strings.Join(row.Tags, ";"),
+			fmt.Sprintf("%.0f", row.Duration.Minutes()),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func totals(durations map[string]time.Duration) []Total {
+	totals := make([]Total, 0, len(durations))
+	for name, d := range durations {
+		totals = append(totals, Total{Name: name, Duration: d})
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		return totals[i].Name < totals[j].Name
+	})
+	return totals
+}
@@ -0,0 +1,226 @@
+// Package templates creates batches of items from named templates, such
+// as a release checklist that is the same every time.
+//
+// A template is a YAML file named <name>.yaml in the templates directory
+// next to the DB, for example:
+//
+//	description: Release checklist
+//	vars:
+//	  version: ""        # no default, has to be given when applying
+//	  branch: main
+//	items:
+//	  - title: Release {{version}}
+//	    tags: [release]
+//	    due: 7d          # relative to the time the template is applied
+//	    subtasks:
+//	      - title: Merge {{branch}} and tag v{{version}}
+//	      - title: Publish the release notes
+//
+// Placeholders are written {{name}} and can be used in titles and tags.
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/duration"
+)
+
+const fileSuffix = ".yaml"
+
+var placeholderPattern = regexp.MustCompile(`{{\s*([A-Za-z0-9_.-]+)\s*}}`)
+
+// Template is a named list of items
+type Template struct {
+	Name        string            `yaml:"-"`
+	Description string            `yaml:"description,omitempty"`
+	Vars        map[string]string `yaml:"vars,omitempty"`
+	Items       []Item            `yaml:"items"`
+}
+
+// Item is an item of a template, Subtasks become items with the item as
+// their parent
+type Item struct {
+	Title        string   `yaml:"title"`
+	Tags         []string `yaml:"tags,omitempty"`
+	Priority     int      `yaml:"priority,omitempty"`
+	Due          string   `yaml:"due,omitempty"`
+	RemindBefore string   `yaml:"remind_before,omitempty"`
+	Subtasks     []Item   `yaml:"subtasks,omitempty"`
+}
+
+// List returns the names of the templates in dir, sorted
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), fileSuffix) {
+			names = append(names, strings.TrimSuffix(entry.Name(), fileSuffix))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Load reads the template with the given name from dir
+func Load(dir string, name string) (*Template, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid template name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name+fileSuffix))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("there is no template %q in %s", name, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tpl := &Template{Name: name}
+	if err := yaml.Unmarshal(data, tpl); err != nil {
+		return nil, fmt.Errorf("template %s: %w", name, err)
+	}
+	if len(tpl.Items) == 0 {
+		return nil, fmt.Errorf("template %s has no items", name)
+	}
+	return tpl, nil
+}
+
+// Instantiate creates the items of the template, with ids counting up
+// from firstId in the order the items appear in the template (parents
+// before their subtasks).  vars override the defaults of the template,
+// every placeholder used in the template needs a value.
+func (tpl *Template) Instantiate(vars map[string]string, firstId int, now time.Time) ([]db.ToDoItem, error) {
+	values := make(map[string]string)
+	for name, value := range tpl.Vars {
+		values[name] = value
+	}
+	for name, value := range vars {
+		if _, known := tpl.Vars[name]; !known && !tpl.uses(name) {
+			return nil, fmt.Errorf("template %s has no variable %q", tpl.Name, name)
+		}
+		values[name] = value
+	}
+
+	var items []db.ToDoItem
+	nextId := firstId
+	created := now.UTC()
+
+	var add func(parentId int, templateItems []Item) error
+	add = func(parentId int, templateItems []Item) error {
+		for _, ti := range templateItems {
+			item := db.ToDoItem{
+				Id:           nextId,
+				Priority:     ti.Priority,
+				RemindBefore: ti.RemindBefore,
+				ParentId:     parentId,
+				CreatedAt:    &created,
+			}
+			nextId++
+
+			var err error
+			if item.Title, err = substitute(ti.Title, values); err != nil {
+				return err
+			}
+			if item.Title == "" {
+				return fmt.Errorf("template %s has an item without a title", tpl.Name)
+			}
+			for _, tag := range ti.Tags {
+				if tag, err = substitute(tag, values); err != nil {
+					return err
+				}
+				item.Tags = append(item.Tags, tag)
+			}
+			if ti.Due != "" {
+				d, err := duration.Parse(ti.Due)
+				if err != nil {
+					return fmt.Errorf("%q: due: %w", ti.Title, err)
+				}
+				due := created.Add(d)
+				item.Due = &due
+			}
+			if ti.RemindBefore != "" {
+				if _, err := duration.Parse(ti.RemindBefore); err != nil {
+					return fmt.Errorf("%q: remind_before: %w", ti.Title, err)
+				}
+			}
+
+			items = append(items, item)
+			if err := add(item.Id, ti.Subtasks); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := add(0, tpl.Items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Placeholders returns the names of all placeholders used in the
+// template, sorted
+func (tpl *Template) Placeholders() []string {
+	seen := make(map[string]bool)
+	var walk func(items []Item)
+	walk = func(items []Item) {
+		for _, item := range items {
+			for _, text := range append([]string{item.Title}, item.Tags...) {
+				for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+					seen[match[1]] = true
+				}
+			}
+			walk(item.Subtasks)
+		}
+	}
+	walk(tpl.Items)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (tpl *Template) uses(name string) bool {
+	for _, placeholder := range tpl.Placeholders() {
+		if placeholder == name {
+			return true
+		}
+	}
+	return false
+}
+
+// substitute replaces the placeholders in text, a placeholder without a
+// value (or with an empty value) is an error
+func substitute(text string, values map[string]string) (string, error) {
+	var missing []string
+	result := placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		value := values[name]
+		if value == "" {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("no value for %s, use --var %s=...", strings.Join(missing, ", "), missing[0])
+	}
+	return result, nil
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchive(t *testing.T) {
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+
+	longAgo := time.Now().Add(-60 * 24 * time.Hour).UTC()
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Open item"}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Done long ago", IsDone: true, DoneAt: &longAgo}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 3, Title: "Done just now"}))
+	assert.NoError(t, todo.ChangeItemDoneStatus(3, true))
+
+	// ChangeItemDoneStatus records the completion time
+	item, err := todo.GetItem(3)
+	assert.NoError(t, err, "Error getting item from DB")
+	assert.NotNil(t, item.DoneAt)
+
+	// Only item 2 was completed more than 30 days ago
+	archived, err := todo.Archive(30 * 24 * time.Hour)
+	assert.NoError(t, err, "Error archiving items")
+	assert.Equal(t, []int{2}, archived)
+
+	// Without an age limit every done item is archived
+	archived, err = todo.Archive(0)
+	assert.NoError(t, err, "Error archiving items")
+	assert.Equal(t, []int{3}, archived)
+
+	items, err := todo.GetAllItems()
+	assert.NoError(t, err, "Error getting all items from DB")
+	assert.Equal(t, 1, len(items))
+	assert.Equal(t, 1, items[0].Id)
+
+	entries, err := todo.ArchivedItems()
+	assert.NoError(t, err, "Error getting archived items")
+	assert.Equal(t, 2, len(entries))
+
+	found, err := todo.SearchArchive("LONG AGO")
+	assert.NoError(t, err, "Error searching the archive")
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, 2, found[0].Item.Id)
+}
+
+func TestTrash(t *testing.T) {
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+
+	item := db.ToDoItem{Id: 7, Title: "Delete me"}
+	assert.NoError(t, todo.AddItem(item))
+	assert.NoError(t, todo.DeleteItem(7))
+
+	entries, err := todo.Trash()
+	assert.NoError(t, err, "Error listing the trash")
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, item, entries[0].Item)
+
+	// Restoring fails while the id is taken by a new item
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 7, Title: "New item with the same id"}))
+	assert.Error(t, todo.RestoreFromTrash(7))
+	assert.NoError(t, todo.DeleteItem(7))
+
+	// The newer deletion is kept next to the older one in the trash
+	entries, err = todo.Trash()
+	assert.NoError(t, err, "Error listing the trash")
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, item, entries[0].Item)
+	assert.Equal(t, "New item with the same id", entries[1].Item.Title)
+
+	// The most recently deleted one is restored first
+	assert.NoError(t, todo.RestoreFromTrash(7))
+	dbItem, err := todo.GetItem(7)
+	assert.NoError(t, err, "Error getting restored item")
+	assert.Equal(t, "New item with the same id", dbItem.Title)
+
+	entries, err = todo.Trash()
+	assert.NoError(t, err, "Error listing the trash")
+	assert.Equal(t, []db.TrashEntry{entries[0]}, entries)
+	assert.Equal(t, item, entries[0].Item)
+
+	assert.NoError(t, todo.DeleteItem(7))
+	removed, err := todo.EmptyTrash()
+	assert.NoError(t, err, "Error emptying the trash")
+	assert.Equal(t, 2, len(removed))
+	assert.Error(t, todo.RestoreFromTrash(7))
+}
+
+func TestNextIdSkipsTrashAndArchive(t *testing.T) {
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+
+	// delete, add, delete: both items have to survive in the trash
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "First"}))
+	assert.NoError(t, todo.DeleteItem(1))
+	id, err := todo.NextId()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, id, "The id of the trashed item is not reused")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: id, Title: "Second"}))
+	assert.NoError(t, todo.DeleteItem(id))
+
+	entries, err := todo.Trash()
+	assert.NoError(t, err, "Error listing the trash")
+	assert.Equal(t, 2, len(entries))
+	assert.NoError(t, todo.RestoreFromTrash(1))
+	assert.NoError(t, todo.RestoreFromTrash(2))
+	first, err := todo.GetItem(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "First", first.Title)
+	second, err := todo.GetItem(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "Second", second.Title)
+
+	assert.NoError(t, todo.ChangeItemDoneStatus(2, true))
+	_, err = todo.Archive(0)
+	assert.NoError(t, err, "Error archiving")
+	id, err = todo.NextId()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, id, "The id of the archived item is not reused")
+}
+
+func TestTrashRetention(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	todo, err := db.NewWithOptions(dbFile, db.Options{TrashRetention: time.Nanosecond})
+	assert.NoError(t, err, "Error creating DB")
+
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Gone soon"}))
+	assert.NoError(t, todo.DeleteItem(1))
+	time.Sleep(time.Millisecond)
+
+	entries, err := todo.Trash()
+	assert.NoError(t, err, "Error listing the trash")
+	assert.Empty(t, entries, "Expired items must be purged from the trash")
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// storedFiles returns the files in the attachments directory of a DB
+func storedFiles(t *testing.T, todo *db.ToDo) []string {
+	files, err := filepath.Glob(filepath.Join(todo.AttachmentsDir(), "*", "*"))
+	assert.NoError(t, err)
+	return files
+}
+
+func TestAttachments(t *testing.T) {
+	dir := t.TempDir()
+	todo, err := db.New(filepath.Join(dir, "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Write report"}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Send report"}))
+	report := filepath.Join(dir, "report.txt")
+	assert.NoError(t, os.WriteFile(report, []byte("Quarterly numbers"), 0644))
+
+	a, err := todo.AttachURL(1, "https://example.com/report")
+	assert.NoError(t, err)
+	assert.Equal(t, db.AttachmentURL, a.Kind)
+	_, err = todo.AttachURL(1, "not a url")
+	assert.Error(t, err)
+	_, err = todo.AttachURL(1, "https://example.com/report")
+	assert.Error(t, err, "Already attached")
+
+	a, err = todo.AttachPath(1, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, dir, a.Target)
+	_, err = todo.AttachPath(1, filepath.Join(dir, "missing.txt"))
+	assert.Error(t, err)
+
+	// The same file attached twice is stored once
+	a, err = todo.AttachFile(1, report)
+	assert.NoError(t, err)
+	assert.Equal(t, "report.txt", a.Name)
+	assert.Equal(t, int64(17), a.Size)
+	_, err = todo.AttachFile(2, report)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(storedFiles(t, todo)))
+	_, err = todo.AttachFile(3, report)
+	assert.Error(t, err, "No such item")
+	_, err = todo.AttachFile(1, dir)
+	assert.Error(t, err, "Directories can only be linked")
+
+	// The copy doesn't change with the original
+	assert.NoError(t, os.WriteFile(report, []byte("Changed"), 0644))
+	item, err := todo.GetItem(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(item.Attachments))
+	data, err := todo.ReadAttachment(item.Attachments[2])
+	assert.NoError(t, err)
+	assert.Equal(t, "Quarterly numbers", string(data))
+	_, err = todo.ReadAttachment(item.Attachments[0])
+	assert.Error(t, err, "Links have no contents")
+
+	// The copy is kept while an item has the file attached, also in the
+	// trash, and removed after that
+	_, err = todo.Detach(1, 3)
+	assert.NoError(t, err)
+	_, err = todo.Detach(1, 3)
+	assert.Error(t, err)
+	assert.Equal(t, 1, len(storedFiles(t, todo)))
+	assert.NoError(t, todo.DeleteItem(2))
+	assert.Equal(t, 1, len(storedFiles(t, todo)))
+	_, err = todo.EmptyTrash()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(storedFiles(t, todo)))
+	_, err = todo.ReadAttachment(db.Attachment{Kind: db.AttachmentFile, Name: "x", Hash: "../../etc/passwd"})
+	assert.Error(t, err)
+}
+
+func TestEncryptedAttachments(t *testing.T) {
+	dir := t.TempDir()
+	opts := db.Options{Passphrase: passphraseFunc("correct horse battery staple")}
+	todo, err := db.NewWithOptions(filepath.Join(dir, "todo.json"), opts)
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Review contract"}))
+	contract := filepath.Join(dir, "contract.txt")
+	assert.NoError(t, os.WriteFile(contract, []byte("Secret terms"), 0644))
+	a, err := todo.AttachFile(1, contract)
+	assert.NoError(t, err)
+
+	// Files attached before and after encrypting are encrypted
+	assert.NoError(t, todo.Encrypt())
+	assert.NoError(t, os.WriteFile(contract, []byte("More secret terms"), 0644))
+	b, err := todo.AttachFile(1, contract)
+	assert.NoError(t, err)
+	files := storedFiles(t, todo)
+	assert.Equal(t, 2, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "terms")
+	}
+
+	data, err := todo.ReadAttachment(a)
+	assert.NoError(t, err)
+	assert.Equal(t, "Secret terms", string(data))
+	assert.NoError(t, todo.Decrypt())
+	data, err = todo.ReadAttachment(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "More secret terms", string(data))
+}
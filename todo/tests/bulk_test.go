@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-40 * 24 * time.Hour)
+	tomorrow := now.Add(24 * time.Hour)
+	items := []db.ToDoItem{
+		{Id: 1, Title: "Fix login", Tags: []string{"sprint-12", "bug"}, CreatedAt: &now},
+		{Id: 2, Title: "Write docs", Tags: []string{"sprint-12"}, IsDone: true, DoneAt: &old},
+		{Id: 3, Title: "Release", Due: &now, CreatedAt: &old},
+		{Id: 4, Title: "Plan next sprint", Due: &tomorrow, IsDone: true, DoneAt: &now},
+	}
+	w := db.DefaultWorkflow
+
+	matching := func(f db.Filter) []int {
+		var ids []int
+		for _, item := range items {
+			if f.Match(w, item, now) {
+				ids = append(ids, item.Id)
+			}
+		}
+		return ids
+	}
+
+	assert.True(t, db.Filter{}.IsZero())
+	assert.Equal(t, []int{1, 2, 3, 4}, matching(db.Filter{}))
+	assert.Equal(t, []int{1, 2}, matching(db.Filter{Tags: []string{"sprint-12"}}))
+	assert.Equal(t, []int{1}, matching(db.Filter{Tags: []string{"sprint-12", "bug"}}))
+	assert.Equal(t, []int{2}, matching(db.Filter{Done: true, OlderThan: 30 * 24 * time.Hour}))
+	assert.Equal(t, []int{3}, matching(db.Filter{Open: true, OlderThan: 30 * 24 * time.Hour}))
+	soon := now.Add(time.Hour)
+	assert.Equal(t, []int{3}, matching(db.Filter{DueBefore: &soon}), "Done items are never due")
+	assert.Equal(t, []int{4}, matching(db.Filter{Text: "SPRINT"}))
+	assert.Equal(t, []int{2, 4}, matching(db.Filter{Status: w.Terminal()}))
+	assert.Equal(t, []int{1, 3}, matching(db.Filter{Ids: []int{1, 3, 5}}))
+}
+
+func TestBulkOperations(t *testing.T) {
+	dir := t.TempDir()
+	hooksDir := filepath.Join(dir, "hooks")
+	assert.NoError(t, os.Mkdir(hooksDir, 0755))
+	todo, err := db.NewWithOptions(filepath.Join(dir, "todo.json"), db.Options{HooksDir: hooksDir})
+	assert.NoError(t, err, "Error creating DB")
+	for i := 1; i <= 4; i++ {
+		assert.NoError(t, todo.AddItem(db.ToDoItem{Id: i, Title: "Item", Tags: []string{"sprint-12"}}))
+	}
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 5, Title: "Locked", Tags: []string{"sprint-12"}}))
+
+	selected, err := todo.Select(db.Filter{Tags: []string{"sprint-12"}})
+	assert.NoError(t, err, "Error selecting items")
+	assert.Equal(t, 5, len(selected))
+	var done []db.ToDoItem
+	for _, item := range selected {
+		done = append(done, todo.WithDone(item, true))
+	}
+
+	// One rejected item leaves all of them unchanged
+	writeHook(t, hooksDir, "pre-update", `if grep -q Locked; then echo "locked" >&2; exit 1; fi`)
+	assert.Error(t, todo.UpdateItems(done))
+	open, err := todo.Select(db.Filter{Open: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(open))
+
+	assert.NoError(t, todo.UpdateItems(done[:4]))
+	open, err = todo.Select(db.Filter{Open: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(open))
+	assert.Equal(t, 5, open[0].Id)
+
+	assert.Error(t, todo.DeleteItems([]int{1, 99}), "A missing item fails the whole batch")
+	all, err := todo.GetAllItems()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(all))
+
+	assert.NoError(t, todo.DeleteItems([]int{1, 2, 3}))
+	all, err = todo.GetAllItems()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(all))
+	trash, err := todo.Trash()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(trash), "Deleted items are in the trash")
+}
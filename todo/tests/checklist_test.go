@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"drexel.edu/todo/checklist"
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecklistParse(t *testing.T) {
+	lines, err := checklist.Parse(strings.NewReader(`# Tasks
+
+Some notes that are not items.
+
+## backend
+
+- [ ] Fix login <!-- todo:3 done:0 title:5d41402a -->
+  - [X] Write a test
+* [ ] Not indented
+
+## Untagged
+- [x] Buy milk <!-- todo:7 -->
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(lines))
+	assert.Equal(t, checklist.Line{Number: 7, Section: "backend", Title: "Fix login", Id: 3, Parent: -1}, stripState(lines[0]))
+	assert.Equal(t, checklist.Line{Number: 8, Indent: 2, Section: "backend", Done: true, Title: "Write a test", Id: -1, Parent: 0}, stripState(lines[1]))
+	assert.Equal(t, -1, lines[2].Parent)
+	assert.Equal(t, checklist.Line{Number: 12, Section: "Untagged", Done: true, Title: "Buy milk", Id: 7, Parent: -1}, stripState(lines[3]))
+}
+
+// stripState returns the exported fields of a line
+func stripState(line checklist.Line) checklist.Line {
+	return checklist.Line{Number: line.Number, Indent: line.Indent, Section: line.Section, Done: line.Done, Title: line.Title, Id: line.Id, Parent: line.Parent}
+}
+
+func TestChecklistSync(t *testing.T) {
+	dir := t.TempDir()
+	todo, err := db.New(filepath.Join(dir, "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Fix login", Tags: []string{"backend"}}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Write a test", ParentId: 1}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 3, Title: "Buy milk"}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 4, Title: "Call Bob"}))
+
+	tasks := filepath.Join(dir, "TASKS.md")
+	result, err := checklist.Sync(todo, tasks)
+	assert.NoError(t, err)
+	assert.Equal(t, checklist.Result{}, result)
+	data, err := os.ReadFile(tasks)
+	assert.NoError(t, err)
+	text := string(data)
+	assert.Contains(t, text, "## backend\n\n- [ ] Fix login <!-- todo:1 ")
+	assert.Contains(t, text, "\n  - [ ] Write a test <!-- todo:2 ")
+	assert.Less(t, strings.Index(text, "## backend"), strings.Index(text, "## "+checklist.Untagged))
+
+	// Syncing again without changes changes nothing
+	result, err = checklist.Sync(todo, tasks)
+	assert.NoError(t, err)
+	assert.Equal(t, checklist.Result{}, result)
+	again, err := os.ReadFile(tasks)
+	assert.NoError(t, err)
+	assert.Equal(t, text, string(again))
+
+	// Edit the file and the database at the same time
+	text = strings.Replace(text, "- [ ] Buy milk", "- [x] Buy oat milk", 1)
+	text = strings.Replace(text, "  - [ ] Write a test <!-- todo:2 ", "  - [ ] Write a test\n    - [ ] Run it\n  - [ ] Write a test <!-- todo:2 ", 1)
+	text += "- [ ] Deploy\n"
+	assert.NoError(t, os.WriteFile(tasks, []byte(text), 0644))
+	assert.NoError(t, todo.ChangeItemDoneStatus(1, true))
+	assert.NoError(t, todo.UpdateItem(db.ToDoItem{Id: 4, Title: "Call Alice"}))
+	assert.NoError(t, todo.DeleteItem(2))
+
+	result, err = checklist.Sync(todo, tasks)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5, 6, 7}, result.Added)
+	assert.Equal(t, []int{3}, result.Renamed)
+	assert.Equal(t, []int{3}, result.Completed)
+	assert.Empty(t, result.Reopened, "Lines that were not edited don't undo changes to the database")
+	assert.Equal(t, []int{2}, result.Missing)
+
+	item, err := todo.GetItem(1)
+	assert.NoError(t, err)
+	assert.True(t, item.IsDone)
+	item, err = todo.GetItem(4)
+	assert.NoError(t, err)
+	assert.Equal(t, "Call Alice", item.Title)
+	item, err = todo.GetItem(5)
+	assert.NoError(t, err)
+	assert.Equal(t, db.ToDoItem{Id: 5, Title: "Write a test", Tags: []string{"backend"}, ParentId: 1}, db.ToDoItem{Id: item.Id, Title: item.Title, Tags: item.Tags, ParentId: item.ParentId})
+	item, err = todo.GetItem(6)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, item.ParentId, "New lines are subtasks of the line they are indented under")
+	item, err = todo.GetItem(7)
+	assert.NoError(t, err)
+	assert.Empty(t, item.Tags)
+
+	data, err = os.ReadFile(tasks)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "- [x] Fix login <!-- todo:1 done:1 ")
+	assert.NotContains(t, string(data), "todo:2 ")
+	assert.Contains(t, string(data), "\n    - [ ] Run it <!-- todo:6 ")
+}
+
+func TestChecklistSyncItemZero(t *testing.T) {
+	dir := t.TempDir()
+	todo, err := db.New(filepath.Join(dir, "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 0, Title: "First"}))
+
+	tasks := filepath.Join(dir, "TASKS.md")
+	_, err = checklist.Sync(todo, tasks)
+	assert.NoError(t, err)
+	text, err := os.ReadFile(tasks)
+	assert.NoError(t, err)
+
+	// The line of item 0 is linked to it, not a new line
+	for i := 0; i < 2; i++ {
+		result, err := checklist.Sync(todo, tasks)
+		assert.NoError(t, err)
+		assert.Equal(t, checklist.Result{}, result)
+	}
+	again, err := os.ReadFile(tasks)
+	assert.NoError(t, err)
+	assert.Equal(t, string(text), string(again))
+	items, err := todo.GetAllItems()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(items))
+
+	assert.NoError(t, os.WriteFile(tasks, []byte(strings.Replace(string(text), "- [ ] First", "- [x] First", 1)), 0644))
+	result, err := checklist.Sync(todo, tasks)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0}, result.Completed)
+}
+
+func TestChecklistSyncKeepsHandWrittenFile(t *testing.T) {
+	dir := t.TempDir()
+	todo, err := db.New(filepath.Join(dir, "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+
+	tasks := filepath.Join(dir, "TASKS.md")
+	notes := "# Plans\n\nLong notes about the release.\n\n- [ ] Tag it\n"
+	assert.NoError(t, os.WriteFile(tasks, []byte(notes), 0644))
+	result, err := checklist.Sync(todo, tasks)
+	assert.NoError(t, err)
+	assert.Equal(t, tasks+".orig", result.Backup)
+	assert.Equal(t, 1, len(result.Added))
+	data, err := os.ReadFile(tasks + ".orig")
+	assert.NoError(t, err)
+	assert.Equal(t, notes, string(data), "The file is kept as it was")
+
+	// Synced files are not backed up again
+	result, err = checklist.Sync(todo, tasks)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Backup)
+
+	// Neither is a file synced with an empty database
+	other := filepath.Join(dir, "EMPTY.md")
+	empty, err := db.New(filepath.Join(dir, "empty.json"))
+	assert.NoError(t, err, "Error creating DB")
+	for i := 0; i < 2; i++ {
+		result, err = checklist.Sync(empty, other)
+		assert.NoError(t, err)
+		assert.Empty(t, result.Backup)
+	}
+
+	// An existing backup is never overwritten
+	assert.NoError(t, os.WriteFile(tasks, []byte(notes), 0644))
+	_, err = checklist.Sync(todo, tasks)
+	assert.Error(t, err)
+	data, err = os.ReadFile(tasks)
+	assert.NoError(t, err)
+	assert.Equal(t, notes, string(data))
+}
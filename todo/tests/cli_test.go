@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// The todo binary, built once for the tests that run commands
+var (
+	buildOnce sync.Once
+	binDir    string
+	todoBin   string
+	buildErr  error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if binDir != "" {
+		os.RemoveAll(binDir)
+	}
+	os.Exit(code)
+}
+
+// runTodo runs the todo command in dir with a clean environment, so that
+// the global config and database of the user are never touched, plus the
+// variables in env, which override it.  It returns the output on stdout and stderr and the error of the command.
+func runTodo(t *testing.T, dir string, env []string, args ...string) (string, string, error) {
+	buildOnce.Do(func() {
+		binDir, buildErr = os.MkdirTemp("", "todo-test-bin")
+		if buildErr != nil {
+			return
+		}
+		todoBin = filepath.Join(binDir, "todo")
+		out, err := exec.Command("go", "build", "-o", todoBin, "..").CombinedOutput()
+		if err != nil {
+			buildErr = fmt.Errorf("%v: %s", err, out)
+		}
+	})
+	if buildErr != nil {
+		t.Fatalf("Error building todo: %v", buildErr)
+	}
+
+	home := t.TempDir()
+	cmd := exec.Command(todoBin, args...)
+	cmd.Dir = dir
+	cmd.Env = append([]string{
+		"HOME=" + home,
+		"XDG_CONFIG_HOME=" + filepath.Join(home, ".config"),
+		"XDG_DATA_HOME=" + filepath.Join(home, ".local", "share"),
+		"PATH=" + os.Getenv("PATH"),
+	}, env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
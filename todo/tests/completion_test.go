@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// complete runs a shell completion in dir and returns the candidates,
+// without the directive cobra prints after them
+func complete(t *testing.T, dir string, env []string, args ...string) []string {
+	stdout, stderr, err := runTodo(t, dir, env, append([]string{"__complete"}, args...)...)
+	assert.NoError(t, err, stderr)
+	var candidates []string
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		if strings.HasPrefix(line, ":") {
+			break
+		}
+		candidates = append(candidates, line)
+	}
+	return candidates
+}
+
+func completionWorkspace(t *testing.T) string {
+	dir := t.TempDir()
+	items := `[{"id":1,"title":"Buy milk","tags":["home"]},{"id":2,"title":"Write report","done":true,"tags":["work","home"]}]`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "todo.json"), []byte(items), 0644))
+	return dir
+}
+
+func TestCompleteItemIds(t *testing.T) {
+	dir := completionWorkspace(t)
+	assert.Equal(t, []string{"1\tBuy milk", "2\tWrite report"}, complete(t, dir, nil, "comment", ""))
+
+	// Only open items can be started
+	assert.Equal(t, []string{"1\tBuy milk"}, complete(t, dir, nil, "start", ""))
+}
+
+func TestCompleteTags(t *testing.T) {
+	dir := completionWorkspace(t)
+	assert.Equal(t, []string{"home", "work"}, complete(t, dir, nil, "tag", "remove", ""))
+}
+
+func TestCompleteStates(t *testing.T) {
+	dir := completionWorkspace(t)
+	assert.Equal(t, []string{"todo", "in_progress", "review", "done"}, complete(t, dir, nil, "move", "1", ""))
+}
+
+func TestCompleteConfigKeys(t *testing.T) {
+	dir := completionWorkspace(t)
+	keys := complete(t, dir, nil, "config", "get", "")
+	assert.Contains(t, keys, "output.format\tOutput format of listed items: json or text")
+	assert.Contains(t, keys, "backup.retention\tNumber of automatic backups kept when the database is saved, 0 disables them")
+}
+
+func TestCompleteWithoutDatabase(t *testing.T) {
+	dir := t.TempDir()
+	home := t.TempDir()
+	dataHome := filepath.Join(home, ".local", "share")
+	env := []string{"HOME=" + home, "XDG_DATA_HOME=" + dataHome}
+
+	assert.Empty(t, complete(t, dir, env, "start", ""))
+	assert.Empty(t, complete(t, dir, env, "tag", "remove", ""))
+	assert.Empty(t, complete(t, dir, env, "trash", "restore", ""))
+
+	// A completion doesn't create the global database or its directory
+	assert.NoFileExists(t, filepath.Join(dataHome, "todo", "todo.json"))
+	assert.NoDirExists(t, filepath.Join(dataHome, "todo"))
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
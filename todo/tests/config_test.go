@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"drexel.edu/todo/config"
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigDefaults(t *testing.T) {
+	cfg, err := config.Load(config.Options{})
+	assert.NoError(t, err, "Error loading config")
+	assert.Equal(t, "json", cfg.Get("output.format"))
+	assert.Equal(t, config.FromDefault, cfg.Source("output.format"))
+	assert.Equal(t, 0, cfg.Int("backup.retention"))
+}
+
+func TestConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	globalFile := filepath.Join(dir, "global.yaml")
+	workspaceFile := filepath.Join(dir, "workspace.yaml")
+
+	assert.NoError(t, os.WriteFile(globalFile, []byte("output:\n  format: text\n  color: never\nbackup:\n  retention: 3\n"), 0644))
+	assert.NoError(t, os.WriteFile(workspaceFile, []byte("output:\n  color: always\n"), 0644))
+	t.Setenv("TODO_BACKUP_RETENTION", "5")
+
+	cfg, err := config.Load(config.Options{
+		GlobalFile:    globalFile,
+		WorkspaceFile: workspaceFile,
+		Flags:         map[string]string{"output.format": "json"},
+	})
+	assert.NoError(t, err, "Error loading config")
+
+	assert.Equal(t, "json", cfg.Get("output.format"))
+	assert.Equal(t, config.FromFlag, cfg.Source("output.format"))
+	assert.Equal(t, "always", cfg.Get("output.color"))
+	assert.Equal(t, config.FromWorkspace, cfg.Source("output.color"))
+	assert.Equal(t, 5, cfg.Int("backup.retention"))
+	assert.Equal(t, config.FromEnv, cfg.Source("backup.retention"))
+	assert.Equal(t, "2006-01-02", cfg.Get("output.date_format"))
+}
+
+func TestConfigValidation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+
+	assert.NoError(t, os.WriteFile(file, []byte("output:\n  colour: never\n"), 0644))
+	_, err := config.Load(config.Options{GlobalFile: file})
+	assert.Error(t, err, "Unknown keys must be rejected")
+
+	assert.NoError(t, os.WriteFile(file, []byte("output:\n  format: xml\n"), 0644))
+	_, err = config.Load(config.Options{GlobalFile: file})
+	assert.Error(t, err, "Invalid values must be rejected")
+
+	t.Setenv("TODO_BACKUP_RETENTION", "-1")
+	_, err = config.Load(config.Options{})
+	assert.Error(t, err, "Invalid environment values must be rejected")
+}
+
+func TestConfigTrustedKeys(t *testing.T) {
+	dir := t.TempDir()
+	globalFile := filepath.Join(dir, "global.yaml")
+	workspaceFile := filepath.Join(dir, "workspace.yaml")
+
+	// A workspace config comes with a cloned repository and can't make
+	// todo run programs
+	assert.NoError(t, os.WriteFile(workspaceFile, []byte("hooks:\n  dir: /tmp/evil\n"), 0644))
+	_, err := config.Load(config.Options{WorkspaceFile: workspaceFile})
+	assert.Error(t, err)
+	for _, name := range []string{"open.command", "remind.command"} {
+		assert.NoError(t, os.WriteFile(workspaceFile, []byte(name+": rm -rf ~\n"), 0644))
+		_, err = config.Load(config.Options{WorkspaceFile: workspaceFile})
+		assert.Error(t, err, name)
+	}
+
+	assert.NoError(t, os.WriteFile(globalFile, []byte("hooks:\n  dir: /home/me/hooks\n"), 0644))
+	cfg, err := config.Load(config.Options{GlobalFile: globalFile})
+	assert.NoError(t, err, "Error loading config")
+	assert.Equal(t, "/home/me/hooks", cfg.Get("hooks.dir"))
+
+	t.Setenv("TODO_HOOKS_DIR", "/opt/hooks")
+	cfg, err = config.Load(config.Options{GlobalFile: globalFile})
+	assert.NoError(t, err, "Error loading config")
+	assert.Equal(t, config.FromEnv, cfg.Source("hooks.dir"))
+}
+
+func TestConfigSetInFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "todo", "config.yaml")
+
+	assert.NoError(t, config.SetInFile(file, "output.format", "text"))
+	assert.NoError(t, config.SetInFile(file, "backup.retention", "2"))
+	assert.Error(t, config.SetInFile(file, "no.such.key", "1"))
+	assert.Error(t, config.SetInFile(file, "output.color", "sometimes"))
+
+	values, err := config.ReadFile(file)
+	assert.NoError(t, err, "Error reading config file")
+	assert.Equal(t, map[string]string{"output.format": "text", "backup.retention": "2"}, values)
+
+	assert.NoError(t, config.SetInFile(file, "output.format", ""))
+	values, err = config.ReadFile(file)
+	assert.NoError(t, err, "Error reading config file")
+	assert.Equal(t, map[string]string{"backup.retention": "2"}, values)
+}
+
+func TestConfigLenient(t *testing.T) {
+	dir := t.TempDir()
+	globalFile := filepath.Join(dir, "global.yaml")
+	workspaceFile := filepath.Join(dir, "workspace.yaml")
+
+	assert.NoError(t, os.WriteFile(globalFile, []byte("output:\n  formt: text\n  color: never\nbackup:\n  retention: many\n"), 0644))
+	assert.NoError(t, os.WriteFile(workspaceFile, []byte("- not a map\n"), 0644))
+	opts := config.Options{GlobalFile: globalFile, WorkspaceFile: workspaceFile}
+
+	_, err := config.Load(opts)
+	assert.Error(t, err, "Unknown keys fail a strict load")
+
+	opts.Lenient = true
+	cfg, err := config.Load(opts)
+	assert.NoError(t, err, "Error loading config")
+	assert.Len(t, cfg.Warnings(), 3)
+	assert.Equal(t, "never", cfg.Get("output.color"))
+	assert.Equal(t, config.FromGlobal, cfg.Source("output.color"))
+	assert.Equal(t, 0, cfg.Int("backup.retention"))
+	assert.Equal(t, config.FromDefault, cfg.Source("backup.retention"))
+
+	// The bad keys can be removed again, unknown or not
+	assert.NoError(t, config.SetInFile(globalFile, "output.formt", ""))
+	assert.NoError(t, config.SetInFile(globalFile, "backup.retention", ""))
+	assert.Error(t, config.SetInFile(globalFile, "output.formt", ""), "The key is gone")
+	cfg, err = config.Load(config.Options{GlobalFile: globalFile})
+	assert.NoError(t, err, "Error loading config")
+	assert.Equal(t, "never", cfg.Get("output.color"))
+}
+
+func TestConfigCommandsRepairConfig(t *testing.T) {
+	dir := t.TempDir()
+	configHome := filepath.Join(dir, "config")
+	globalFile := filepath.Join(configHome, "todo", config.FileName)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(globalFile), 0755))
+	assert.NoError(t, os.WriteFile(globalFile, []byte("output:\n  formt: text\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "todo.json"), []byte("[]"), 0644))
+	env := []string{"XDG_CONFIG_HOME=" + configHome}
+
+	_, _, err := runTodo(t, dir, env, "--db", "todo.json", "-l")
+	assert.Error(t, err, "Other commands still fail on the bad key")
+
+	stdout, stderr, err := runTodo(t, dir, env, "config", "list")
+	assert.NoError(t, err, stderr)
+	assert.Contains(t, stderr, "output.formt")
+	assert.Contains(t, stdout, "output.format")
+
+	_, stderr, err = runTodo(t, dir, env, "config", "unset", "--global", "output.formt")
+	assert.NoError(t, err, stderr)
+	_, stderr, err = runTodo(t, dir, env, "--db", "todo.json", "-l")
+	assert.NoError(t, err, stderr)
+}
+
+func TestConfigKeepsValueText(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+
+	// Unquoted values yaml would read as a time, a number or a bool
+	assert.NoError(t, os.WriteFile(file, []byte("output:\n  date_format: 2006-01-02\n  color: never\nuser:\n  name: yes\ndedupe:\n  similarity: 1e3\nworkflow:\n  transitions:\n"), 0644))
+	values, err := config.ReadFile(file)
+	assert.NoError(t, err, "Error reading config file")
+	assert.Equal(t, map[string]string{
+		"output.date_format":   "2006-01-02",
+		"output.color":         "never",
+		"user.name":            "yes",
+		"dedupe.similarity":    "1e3",
+		"workflow.transitions": "",
+	}, values)
+
+	_, err = config.Load(config.Options{GlobalFile: file})
+	assert.Error(t, err, "1e3 is not a whole number")
+	assert.NoError(t, os.WriteFile(file, []byte("output:\n  date_format: 2006-01-02 15:04\n"), 0644))
+	cfg, err := config.Load(config.Options{GlobalFile: file})
+	assert.NoError(t, err, "Error loading config")
+	assert.Equal(t, "2006-01-02 15:04", cfg.Get("output.date_format"))
+
+	// Values written by SetInFile read back the same
+	assert.NoError(t, config.SetInFile(file, "output.date_format", "2006-01-02"))
+	values, err = config.ReadFile(file)
+	assert.NoError(t, err, "Error reading config file")
+	assert.Equal(t, "2006-01-02", values["output.date_format"])
+
+	assert.NoError(t, os.WriteFile(file, []byte("- a\n- b\n"), 0644))
+	_, err = config.ReadFile(file)
+	assert.Error(t, err, "The file has to be a map")
+}
+
+func TestBackupRetention(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	todo, err := db.NewWithOptions(dbFile, db.Options{BackupRetention: 2})
+	assert.NoError(t, err, "Error creating DB")
+
+	for i := 1; i <= 4; i++ {
+		assert.NoError(t, todo.AddItem(db.ToDoItem{Id: i, Title: "Backup test"}))
+	}
+
+	backups, err := todo.Backups()
+	assert.NoError(t, err, "Error listing backups")
+	assert.Equal(t, 2, len(backups))
+
+	// The newest backup holds the DB as it was before the last save
+	data, err := os.ReadFile(backups[1])
+	assert.NoError(t, err, "Error reading backup")
+	assert.Contains(t, string(data), `"id": 3`)
+	assert.NotContains(t, string(data), `"id": 4`)
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func passphraseFunc(passphrase string) func() (string, error) {
+	return func() (string, error) {
+		return passphrase, nil
+	}
+}
+
+func TestEncryptedDB(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	opts := db.Options{Passphrase: passphraseFunc("correct horse battery staple")}
+
+	todo, err := db.NewWithOptions(dbFile, opts)
+	assert.NoError(t, err, "Error creating DB")
+	item := db.ToDoItem{Id: 1, Title: "Call customer Jane Doe", IsDone: false}
+	assert.NoError(t, todo.AddItem(item))
+
+	assert.NoError(t, todo.Encrypt())
+	assert.True(t, todo.IsEncrypted())
+
+	// The title must not be readable from the file anymore
+	data, err := os.ReadFile(dbFile)
+	assert.NoError(t, err, "Error reading DB file")
+	assert.NotContains(t, string(data), "Jane Doe")
+
+	// A new ToDo with the same passphrase works transparently
+	reopened, err := db.NewWithOptions(dbFile, opts)
+	assert.NoError(t, err, "Error opening encrypted DB")
+	assert.True(t, reopened.IsEncrypted())
+	assert.NoError(t, reopened.ChangeItemDoneStatus(1, true))
+	dbItem, err := reopened.GetItem(1)
+	assert.NoError(t, err, "Error getting item from encrypted DB")
+	assert.Equal(t, item.Title, dbItem.Title)
+	assert.True(t, dbItem.IsDone)
+
+	// Saving keeps the file encrypted
+	data, err = os.ReadFile(dbFile)
+	assert.NoError(t, err, "Error reading DB file")
+	assert.NotContains(t, string(data), "Jane Doe")
+
+	// Decrypting turns it back into plain json
+	assert.NoError(t, reopened.Decrypt())
+	data, err = os.ReadFile(dbFile)
+	assert.NoError(t, err, "Error reading DB file")
+	assert.Contains(t, string(data), "Jane Doe")
+}
+
+func TestEncryptedDBFilePermissions(t *testing.T) {
+	dir := t.TempDir()
+	dbFile := filepath.Join(dir, "db", "todo.json")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(dbFile), 0755))
+	opts := db.Options{Passphrase: passphraseFunc("secret"), BackupRetention: 5}
+	todo, err := db.NewWithOptions(dbFile, opts)
+	assert.NoError(t, err, "Error creating DB")
+	attachment := filepath.Join(dir, "contract.txt")
+	assert.NoError(t, os.WriteFile(attachment, []byte("Jane Doe"), 0644))
+	for id := 1; id <= 3; id++ {
+		assert.NoError(t, todo.AddItem(db.ToDoItem{Id: id, Title: "Call Jane Doe"}))
+	}
+	_, err = todo.AttachFile(1, attachment)
+	assert.NoError(t, err)
+	assert.NoError(t, todo.DeleteItem(2))
+	assert.NoError(t, todo.ChangeItemDoneStatus(3, true))
+	_, err = todo.Archive(0)
+	assert.NoError(t, err)
+
+	// perms returns the permissions of the files of the DB
+	perms := func() map[os.FileMode][]string {
+		found := make(map[os.FileMode][]string)
+		filepath.Walk(filepath.Dir(dbFile), func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				found[info.Mode().Perm()] = append(found[info.Mode().Perm()], path)
+			}
+			return err
+		})
+		return found
+	}
+
+	// Encrypting makes the existing files private, the files written later
+	// are private from the start
+	assert.NoError(t, todo.Encrypt())
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 4, Title: "Call Jane Doe again"}))
+	_, err = todo.AttachFile(4, attachment)
+	assert.NoError(t, err)
+	assert.NoError(t, todo.DeleteItem(4))
+	found := perms()
+	assert.Equal(t, []os.FileMode{0600}, keys(found), "Files: %v", found)
+
+	assert.NoError(t, todo.Decrypt())
+	found = perms()
+	assert.Equal(t, []os.FileMode{0644}, keys(found), "Files: %v", found)
+}
+
+func keys(m map[os.FileMode][]string) []os.FileMode {
+	var modes []os.FileMode
+	for mode := range m {
+		modes = append(modes, mode)
+	}
+	return modes
+}
+
+func TestEncryptedDBWrongPassphrase(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	todo, err := db.NewWithOptions(dbFile, db.Options{Passphrase: passphraseFunc("secret")})
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.Encrypt())
+
+	wrong, err := db.NewWithOptions(dbFile, db.Options{Passphrase: passphraseFunc("guess")})
+	assert.NoError(t, err, "Opening does not need the passphrase yet")
+	_, err = wrong.GetAllItems()
+	assert.ErrorIs(t, err, db.ErrWrongPassphrase)
+
+	noPassphrase, err := db.New(dbFile)
+	assert.NoError(t, err, "Opening does not need the passphrase yet")
+	_, err = noPassphrase.GetAllItems()
+	assert.ErrorIs(t, err, db.ErrNoPassphrase)
+}
+
+func TestEncryptedRestoreDB(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	backup := `[{"id": 1, "title": "From backup", "done": false}]`
+	assert.NoError(t, os.WriteFile(dbFile+".bak", []byte(backup), 0644))
+
+	opts := db.Options{Passphrase: passphraseFunc("secret")}
+	todo, err := db.NewWithOptions(dbFile, opts)
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Not in backup"}))
+
+	// Encrypt also encrypts the .bak file
+	assert.NoError(t, todo.Encrypt())
+	data, err := os.ReadFile(dbFile + ".bak")
+	assert.NoError(t, err, "Error reading backup file")
+	assert.NotContains(t, string(data), "From backup")
+
+	assert.NoError(t, todo.RestoreDB())
+
+	reopened, err := db.NewWithOptions(dbFile, opts)
+	assert.NoError(t, err, "Error opening restored DB")
+	assert.True(t, reopened.IsEncrypted())
+	items, err := reopened.GetAllItems()
+	assert.NoError(t, err, "Error getting items from restored DB")
+	assert.Equal(t, []db.ToDoItem{{Id: 1, Title: "From backup"}}, items)
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/config"
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, db.Similarity("Buy milk", "  buy MILK!"), "Case, punctuation and spacing are ignored")
+	assert.Equal(t, 1.0, db.Similarity("Buy milk and eggs", "buy eggs and milk"), "The order of the words is ignored")
+	assert.Greater(t, db.Similarity("Fix the login page", "Fix the logn page"), 0.9)
+	assert.Less(t, db.Similarity("Fix the login page", "Water the plants"), 0.5)
+	assert.Equal(t, 0.0, db.Similarity("abc", "xyz"))
+
+	assert.NoError(t, config.Validate("dedupe.similarity", "70"))
+	assert.Error(t, config.Validate("dedupe.similarity", "101"))
+	assert.Error(t, config.Validate("add.duplicates", "ask"))
+}
+
+func TestDuplicates(t *testing.T) {
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+	for _, item := range []db.ToDoItem{
+		{Id: 1, Title: "Buy milk"},
+		{Id: 2, Title: "Water the plants"},
+		{Id: 3, Title: "buy milk!"},
+		{Id: 4, Title: "Buy mlk"},
+		{Id: 5, Title: "Buy milk", IsDone: true},
+		{Id: 6, Title: "Water the plant"},
+		{Id: 7, Title: "Call mom"},
+	} {
+		assert.NoError(t, todo.AddItem(item))
+	}
+
+	found, err := todo.Duplicates("Buy some milk", 0.5)
+	assert.NoError(t, err)
+	var ids []int
+	for _, item := range found {
+		ids = append(ids, item.Id)
+	}
+	assert.Equal(t, []int{1, 3, 4}, ids, "Open items only, the most similar first")
+
+	found, err = todo.Duplicates("Call dad", 0.85)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+
+	clusters, err := todo.DuplicateClusters(0.85, false)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]int{{1, 3, 4}, {2, 6}}, clusterIds(clusters))
+
+	clusters, err = todo.DuplicateClusters(0.85, true)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]int{{1, 3, 4, 5}, {2, 6}}, clusterIds(clusters))
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	early, late := start.Add(24*time.Hour), start.Add(72*time.Hour)
+	for _, item := range []db.ToDoItem{
+		{Id: 1, Title: "Fix login", Tags: []string{"web"}, Notes: "Only on Safari", Due: &late, Priority: 2},
+		{Id: 2, Title: "fix login!", Tags: []string{"web", "urgent"}, Notes: "See the logs", Due: &early, Priority: 1,
+			TimeEntries: []db.TimeEntry{{Start: start, End: start.Add(time.Hour)}}},
+		{Id: 3, Title: "Reproduce the bug", ParentId: 2},
+		{Id: 4, Title: "Unrelated"},
+	} {
+		assert.NoError(t, todo.AddItem(item))
+	}
+	assert.NoError(t, todo.Assign(2, "bob", "carol"))
+
+	_, err = todo.MergeDuplicates(1, []int{9}, "carol")
+	assert.Error(t, err, "No such item")
+	_, err = todo.MergeDuplicates(1, []int{1}, "carol")
+	assert.Error(t, err, "Can't merge an item into itself")
+	_, err = todo.MergeDuplicates(1, []int{2, 2}, "carol")
+	assert.Error(t, err, "Item given twice")
+
+	merged, err := todo.MergeDuplicates(1, []int{2}, "carol")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web", "urgent"}, merged.Tags)
+	assert.Equal(t, "Only on Safari\n\nSee the logs", merged.Notes)
+	assert.Equal(t, []string{"bob"}, merged.Assignees)
+	assert.Equal(t, early, *merged.Due, "The earliest due date is kept")
+	assert.Equal(t, 1, merged.Priority, "The highest priority is kept")
+	assert.Equal(t, 1, len(merged.TimeEntries))
+	assert.Equal(t, 2, len(merged.Activity))
+	assert.Equal(t, db.ActivityAssign, merged.Activity[0].Kind)
+	assert.Equal(t, db.ActivityMerge, merged.Activity[1].Kind)
+	assert.Equal(t, "#2 fix login!", merged.Activity[1].Text)
+
+	item, err := todo.GetItem(1)
+	assert.NoError(t, err)
+	assert.Equal(t, merged, item)
+	_, err = todo.GetItem(2)
+	assert.Error(t, err, "The merged item is gone")
+	item, err = todo.GetItem(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, item.ParentId, "Subtasks move to the kept item")
+
+	trash, err := todo.Trash()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(trash))
+	assert.Equal(t, 2, trash[0].Item.Id, "The merged item is in the trash")
+}
+
+func TestMergeDuplicatesVetoed(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, "pre-delete", `echo "keep everything"; exit 1`)
+	todo, err := db.NewWithOptions(filepath.Join(dir, "todo.json"), db.Options{HooksDir: dir})
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Fix login", Notes: "Only on Safari"}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "fix login!", Notes: "See the logs"}))
+
+	// A vetoed delete leaves the kept item as it was
+	_, err = todo.MergeDuplicates(1, []int{2}, "carol")
+	assert.ErrorContains(t, err, "keep everything")
+	item, err := todo.GetItem(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Only on Safari", item.Notes)
+	assert.Empty(t, item.Activity)
+	_, err = todo.GetItem(2)
+	assert.NoError(t, err)
+	trash, err := todo.Trash()
+	assert.NoError(t, err)
+	assert.Empty(t, trash)
+}
+
+func clusterIds(clusters [][]db.ToDoItem) [][]int {
+	var ids [][]int
+	for _, cluster := range clusters {
+		var group []int
+		for _, item := range cluster {
+			group = append(group, item.Id)
+		}
+		ids = append(ids, group)
+	}
+	return ids
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+const damagedDB = `[
+  {"id": 1, "title": "First", "done": false},
+  {"id": 2, "title": "Wrong type", "done": "yes", "priority": 1},
+  {"id": 1, "title": "Same id"},
+  {"id": 3, "title": broken},
+  {"id": 4, "title": "Orphan", "parent_id": 99, "remind_before": "soon"},
+  42
+] trailing garbage`
+
+func TestDoctor(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	assert.NoError(t, os.WriteFile(dbFile, []byte(damagedDB), 0644))
+	todo, err := db.New(dbFile)
+	assert.NoError(t, err, "Error creating DB")
+
+	_, err = todo.GetAllItems()
+	assert.ErrorContains(t, err, "todo doctor", "Loading a damaged file points to the doctor")
+
+	result, err := todo.Check()
+	assert.NoError(t, err, "Error checking DB")
+	assert.Equal(t, 5, result.Entries)
+	var messages []string
+	for _, problem := range result.Problems {
+		messages = append(messages, problem.String())
+	}
+	report := strings.Join(messages, "\n")
+	assert.Contains(t, report, `line 3, column 3: field "done" has an invalid value`)
+	assert.Contains(t, report, "line 4, column 3: duplicate id 1, the item at line 2 has it too, it gets id 5")
+	assert.Contains(t, report, "line 5, column 3: the entry can't be parsed")
+	assert.Contains(t, report, "parent_id 99 is not an item")
+	assert.Contains(t, report, `remind_before "soon" is not a duration`)
+	assert.Contains(t, report, `line 7, column 3: unexpected "42" in the list`)
+	assert.Contains(t, report, "line 8, column 3: unexpected data after the end of the list")
+
+	// Checking doesn't change anything
+	data, err := os.ReadFile(dbFile)
+	assert.NoError(t, err)
+	assert.Equal(t, damagedDB, string(data))
+
+	result, backup, err := todo.Repair()
+	assert.NoError(t, err, "Error repairing DB")
+	original, err := os.ReadFile(backup)
+	assert.NoError(t, err, "The original file is kept")
+	assert.Equal(t, damagedDB, string(original))
+
+	items, err := todo.GetAllItems()
+	assert.NoError(t, err, "The repaired file loads")
+	assert.Equal(t, 4, len(items))
+	assert.Equal(t, len(result.Items), len(items))
+
+	wrongType, err := todo.GetItem(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "Wrong type", wrongType.Title)
+	assert.Equal(t, 1, wrongType.Priority, "The valid fields of an entry are kept")
+	sameId, err := todo.GetItem(5)
+	assert.NoError(t, err)
+	assert.Equal(t, "Same id", sameId.Title)
+	orphan, err := todo.GetItem(4)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, orphan.ParentId)
+	assert.Equal(t, "", orphan.RemindBefore)
+
+	// A healthy file has nothing to repair
+	result, backup, err = todo.Repair()
+	assert.NoError(t, err)
+	assert.True(t, result.OK())
+	assert.Equal(t, "", backup)
+}
+
+func TestDoctorFixesTitlesAndKeepsIdZero(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	damaged := `[
+  {"id": 0, "title": "Item zero"},
+  {"id": 1, "title": ""},
+  {"title": "No id"},
+  {"id": -3, "title": "Negative"},
+  {"id": "x", "title": "Text id"},
+  {"id": null, "title": "Null id"}
+]`
+	assert.NoError(t, os.WriteFile(dbFile, []byte(damaged), 0644))
+	todo, err := db.New(dbFile)
+	assert.NoError(t, err, "Error creating DB")
+
+	result, err := todo.Check()
+	assert.NoError(t, err, "Error checking DB")
+	var messages []string
+	for _, problem := range result.Problems {
+		messages = append(messages, problem.String())
+	}
+	report := strings.Join(messages, "\n")
+	assert.NotContains(t, report, "line 2,", "0 is a valid id")
+	assert.Contains(t, report, `line 3, column 3: the item has no title, it gets the title "(untitled #1)"`)
+	assert.Contains(t, report, "line 4, column 3: the item has no valid id, it gets id 2")
+	assert.Contains(t, report, "line 5, column 3: the item has no valid id, it gets id 3")
+	assert.Contains(t, report, "line 6, column 3: the item has no valid id, it gets id 4")
+	assert.Contains(t, report, "line 7, column 3: the item has no valid id, it gets id 5")
+
+	_, _, err = todo.Repair()
+	assert.NoError(t, err, "Error repairing DB")
+	item, err := todo.GetItem(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "Item zero", item.Title)
+	item, err = todo.GetItem(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "(untitled #1)", item.Title)
+	item, err = todo.GetItem(4)
+	assert.NoError(t, err)
+	assert.Equal(t, "Text id", item.Title)
+	item, err = todo.GetItem(5)
+	assert.NoError(t, err)
+	assert.Equal(t, "Null id", item.Title)
+
+	// One repair fixes everything
+	result, backup, err := todo.Repair()
+	assert.NoError(t, err)
+	assert.True(t, result.OK())
+	assert.Equal(t, "", backup)
+}
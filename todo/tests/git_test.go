@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitHistory(t *testing.T) {
+	dir := t.TempDir()
+	todo, err := db.NewWithOptions(filepath.Join(dir, "todo.json"), db.Options{GitHistory: true})
+	assert.NoError(t, err, "Error creating DB")
+
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 12, Title: "Learn Go"}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 13, Title: "Write tests"}))
+	assert.NoError(t, todo.ChangeItemDoneStatus(12, true))
+	assert.NoError(t, todo.UpdateItem(db.ToDoItem{Id: 13, Title: "Write more tests", Priority: 1}))
+	assert.NoError(t, todo.DeleteItem(13))
+
+	_, err = os.Stat(filepath.Join(dir, "todo.json.git"))
+	assert.NoError(t, err, "The history is in a repository next to the DB")
+	_, err = os.Stat(filepath.Join(dir, ".git"))
+	assert.True(t, os.IsNotExist(err), "The DB directory is left alone")
+
+	revisions, err := todo.Revisions()
+	assert.NoError(t, err, "Error getting the revisions")
+	var messages []string
+	for _, rev := range revisions {
+		messages = append(messages, rev.Message)
+	}
+	assert.Equal(t, []string{
+		"delete #13 'Write more tests'",
+		"update #13 'Write more tests' (title, priority)",
+		"done #12 'Learn Go'",
+		"add #13 'Write tests'",
+		"add #12 'Learn Go'",
+	}, messages)
+
+	rev, changes, err := todo.Revision("HEAD~3")
+	assert.NoError(t, err, "Error getting a revision")
+	assert.Equal(t, revisions[3].Hash, rev.Hash)
+	assert.Equal(t, 1, len(changes))
+	assert.Equal(t, 13, changes[0].Id)
+	assert.Nil(t, changes[0].Before)
+
+	items, err := todo.ItemsAt(revisions[1].Short())
+	assert.NoError(t, err, "Error getting the items of a revision")
+	assert.Equal(t, 2, len(items))
+
+	// Restoring is a new commit, the history after the revision is kept
+	_, err = todo.RestoreRevision("HEAD~2")
+	assert.NoError(t, err, "Error restoring a revision")
+	item, err := todo.GetItem(13)
+	assert.NoError(t, err, "The deleted item is back")
+	assert.Equal(t, "Write tests", item.Title)
+	revisions, err = todo.Revisions()
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(revisions))
+	assert.Contains(t, revisions[0].Message, "restore ")
+
+	_, _, err = todo.Revision("nope")
+	assert.Error(t, err, "Unknown revisions are an error")
+
+	plain, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+	_, err = plain.Revisions()
+	assert.ErrorIs(t, err, db.ErrNoGitHistory)
+}
+
+func TestGitHistoryRestorePastAddedItem(t *testing.T) {
+	todo, err := db.NewWithOptions(filepath.Join(t.TempDir(), "todo.json"), db.Options{GitHistory: true})
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Learn Go"}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Added later"}))
+
+	_, err = todo.RestoreRevision("HEAD~1")
+	assert.NoError(t, err, "Error restoring a revision")
+	_, err = todo.GetItem(2)
+	assert.Error(t, err, "The item added later is gone")
+
+	// It went to the trash like any deleted item, so its id is not reused
+	trash, err := todo.Trash()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(trash))
+	assert.Equal(t, 2, trash[0].Item.Id)
+	history, err := todo.History()
+	assert.NoError(t, err)
+	last := history[len(history)-1]
+	assert.Equal(t, db.OpDelete, last.Op)
+	assert.Equal(t, 2, last.Id)
+	next, err := todo.NextId()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, next)
+}
+
+func TestGitHistoryEncrypted(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	opts := db.Options{GitHistory: true, Passphrase: passphraseFunc("secret")}
+	todo, err := db.NewWithOptions(dbFile, opts)
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Call Jane Doe"}))
+
+	// The commits made so far hold the title unencrypted
+	assert.Error(t, todo.Encrypt())
+	assert.False(t, todo.IsEncrypted())
+
+	assert.NoError(t, os.RemoveAll(dbFile+".git"))
+	assert.NoError(t, todo.Encrypt())
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Call John Doe"}))
+	assert.NoError(t, todo.ChangeItemDoneStatus(1, true))
+
+	revisions, err := todo.Revisions()
+	assert.NoError(t, err)
+	var messages []string
+	for _, rev := range revisions {
+		messages = append(messages, rev.Message)
+	}
+	assert.Equal(t, []string{"done #1", "add #1, add #2"}, messages, "Messages don't name the titles")
+	items, err := todo.ItemsAt("HEAD")
+	assert.NoError(t, err, "The commits hold the encrypted DB")
+	assert.Equal(t, 2, len(items))
+
+	repo, err := git.PlainOpen(dbFile + ".git")
+	assert.NoError(t, err)
+	head, err := repo.Head()
+	assert.NoError(t, err)
+	commit, err := repo.CommitObject(head.Hash())
+	assert.NoError(t, err)
+	file, err := commit.File("todo.json")
+	assert.NoError(t, err)
+	contents, err := file.Contents()
+	assert.NoError(t, err)
+	assert.NotContains(t, contents, "Doe")
+}
+
+func TestGitHistoryInsideProject(t *testing.T) {
+	// A workspace DB at the root of a project that is a git repository
+	root := t.TempDir()
+	project, err := git.PlainInit(root, false)
+	assert.NoError(t, err, "Error creating the project repository")
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "secret.go"), []byte("package secret\n"), 0644))
+	worktree, err := project.Worktree()
+	assert.NoError(t, err)
+	_, err = worktree.Add("secret.go")
+	assert.NoError(t, err)
+
+	todo, err := db.NewWithOptions(filepath.Join(root, "todo.json"), db.Options{GitHistory: true})
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Ship it"}))
+
+	// The project has no commits and secret.go is still only staged
+	_, err = project.Head()
+	assert.Error(t, err, "Nothing was committed to the project")
+	status, err := worktree.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, git.Added, status.File("secret.go").Staging)
+
+	revisions, err := todo.Revisions()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(revisions))
+	history, err := git.PlainOpen(filepath.Join(root, "todo.json.git"))
+	assert.NoError(t, err)
+	head, err := history.Head()
+	assert.NoError(t, err)
+	commit, err := history.CommitObject(head.Hash())
+	assert.NoError(t, err)
+	files, err := commit.Files()
+	assert.NoError(t, err)
+	var names []string
+	files.ForEach(func(f *object.File) error {
+		names = append(names, f.Name)
+		return nil
+	})
+	assert.Equal(t, []string{"todo.json"}, names, "Only the DB file is committed")
+}
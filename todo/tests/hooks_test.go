@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeHook installs an executable shell script as a hook
+func writeHook(t *testing.T, dir string, name string, script string) {
+	err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755)
+	assert.NoError(t, err, "Error writing hook")
+}
+
+func TestHooks(t *testing.T) {
+	dir := t.TempDir()
+	hooksDir := filepath.Join(dir, "hooks")
+	assert.NoError(t, os.Mkdir(hooksDir, 0755))
+	log := filepath.Join(dir, "log")
+
+	// Every post hook appends the event and its input to the log
+	for _, event := range []string{"add", "update", "delete", "done"} {
+		writeHook(t, hooksDir, "post-"+event, `echo "$TODO_EVENT $(cat)" >> `+log)
+	}
+	// Items with "secret" in the title cannot be added
+	writeHook(t, hooksDir, "pre-add", `if grep -q secret; then echo "no secrets please" >&2; exit 1; fi`)
+	// Hooks that are not executable are ignored
+	assert.NoError(t, os.WriteFile(filepath.Join(hooksDir, "pre-delete"), []byte("#!/bin/sh\nexit 1\n"), 0644))
+
+	todo, err := db.NewWithOptions(filepath.Join(dir, "todo.json"), db.Options{HooksDir: hooksDir})
+	assert.NoError(t, err, "Error creating DB")
+
+	err = todo.AddItem(db.ToDoItem{Id: 1, Title: "A secret plan"})
+	var hookErr *db.HookError
+	assert.True(t, errors.As(err, &hookErr), "The pre-add hook should veto the item")
+	assert.Equal(t, "pre-add", hookErr.Hook)
+	assert.Equal(t, "no secrets please", hookErr.Message)
+	_, err = todo.GetItem(1)
+	assert.Error(t, err, "A vetoed item must not be added")
+
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "A public plan"}))
+	assert.NoError(t, todo.ChangeItemDoneStatus(1, true))
+	assert.NoError(t, todo.DeleteItem(1))
+
+	data, err := os.ReadFile(log)
+	assert.NoError(t, err, "The post hooks did not run")
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var events []string
+	for _, line := range lines {
+		event, input, _ := strings.Cut(line, " ")
+		events = append(events, event)
+
+		var hookInput db.HookInput
+		assert.NoError(t, json.Unmarshal([]byte(input), &hookInput))
+		assert.Equal(t, event, hookInput.Event)
+	}
+	assert.Equal(t, []string{"add", "update", "done", "delete"}, events)
+
+	var done db.HookInput
+	assert.NoError(t, json.Unmarshal([]byte(strings.SplitN(lines[2], " ", 2)[1]), &done))
+	assert.False(t, done.Before.IsDone)
+	assert.True(t, done.After.IsDone)
+}
+
+func TestPreUpdateHookVeto(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, "pre-done", `echo "reviews first"; exit 1`)
+
+	todo, err := db.NewWithOptions(filepath.Join(dir, "todo.json"), db.Options{HooksDir: dir})
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Ship it"}))
+
+	// Without output on stderr the stdout of the hook is the message
+	err = todo.ChangeItemDoneStatus(1, true)
+	assert.EqualError(t, err, "The pre-done hook rejected the change: reviews first")
+
+	item, err := todo.GetItem(1)
+	assert.NoError(t, err, "Error getting item from DB")
+	assert.False(t, item.IsDone)
+
+	// Other updates are not affected
+	item.Title = "Ship it soon"
+	assert.NoError(t, todo.UpdateItem(item))
+}
+
+func TestPreHooksRunForValidBatchesOnly(t *testing.T) {
+	dir := t.TempDir()
+	hooksDir := filepath.Join(dir, "hooks")
+	assert.NoError(t, os.Mkdir(hooksDir, 0755))
+	log := filepath.Join(dir, "log")
+	for _, event := range []string{"add", "update", "delete"} {
+		writeHook(t, hooksDir, "pre-"+event, `echo "$TODO_EVENT" >> `+log)
+	}
+
+	todo, err := db.NewWithOptions(filepath.Join(dir, "todo.json"), db.Options{HooksDir: hooksDir})
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "First"}))
+	assert.NoError(t, os.Remove(log))
+
+	// The last item of every batch is invalid, so no hook may run
+	err = todo.AddItems([]db.ToDoItem{{Id: 2, Title: "Second"}, {Id: 1, Title: "Taken"}})
+	assert.Error(t, err, "Item 1 already exists")
+	err = todo.UpdateItems([]db.ToDoItem{{Id: 1, Title: "Renamed"}, {Id: 9, Title: "Missing"}})
+	assert.Error(t, err, "Item 9 doesn't exist")
+	err = todo.DeleteItems([]int{1, 9})
+	assert.Error(t, err, "Item 9 doesn't exist")
+	assert.NoFileExists(t, log, "A pre-hook ran for a batch that failed")
+
+	items, err := todo.GetAllItems()
+	assert.NoError(t, err, "Error getting items from DB")
+	assert.Equal(t, []db.ToDoItem{{Id: 1, Title: "First"}}, items)
+}
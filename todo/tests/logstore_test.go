@@ -0,0 +1,233 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogStorage(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	todo, err := db.New(dbFile)
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Learn Go"}))
+	assert.Equal(t, db.FormatJSON, todo.Format())
+	assert.NoError(t, todo.Convert(db.FormatLog))
+	assert.Equal(t, db.FormatLog, todo.Format())
+
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Write tests"}))
+	assert.Error(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Again"}), "Ids are still unique")
+	assert.NoError(t, todo.ChangeItemDoneStatus(1, true))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 3, Title: "Gone soon"}))
+	assert.NoError(t, todo.DeleteItem(3))
+	assert.Error(t, todo.DeleteItem(3))
+	assert.Error(t, todo.UpdateItem(db.ToDoItem{Id: 3, Title: "Gone"}))
+
+	// A second handle opens the file from its index and sees the changes
+	// appended by the first one
+	other, err := db.New(dbFile)
+	assert.NoError(t, err, "Error opening DB")
+	assert.Equal(t, db.FormatLog, other.Format())
+	item, err := other.GetItem(1)
+	assert.NoError(t, err)
+	assert.True(t, item.IsDone)
+	assert.NoError(t, other.AddItem(db.ToDoItem{Id: 4, Title: "From the other handle"}))
+	item, err = todo.GetItem(4)
+	assert.NoError(t, err, "Appends of other programs are picked up")
+	assert.Equal(t, "From the other handle", item.Title)
+
+	// An incomplete record left by a crash is ignored
+	f, err := os.OpenFile(dbFile, os.O_APPEND|os.O_WRONLY, 0)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"put":{"id":5,"title":"Cut o`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	_, err = todo.GetItem(5)
+	assert.Error(t, err)
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 6, Title: "After the crash"}))
+
+	reopened, err := db.New(dbFile)
+	assert.NoError(t, err, "Error opening DB")
+	items, err := reopened.GetAllItems()
+	assert.NoError(t, err)
+	var ids []int
+	for _, item := range items {
+		ids = append(ids, item.Id)
+	}
+	assert.ElementsMatch(t, []int{1, 2, 4, 6}, ids)
+
+	assert.Error(t, reopened.Encrypt(), "The log format is not encrypted")
+	_, err = reopened.Check()
+	assert.Error(t, err, "The doctor only checks json files")
+
+	// Updates pile up until the log compacts itself
+	for i := 0; i < 1500; i++ {
+		assert.NoError(t, reopened.UpdateItem(db.ToDoItem{Id: 2, Title: fmt.Sprint("Version ", i)}))
+	}
+	info, err := os.Stat(dbFile)
+	assert.NoError(t, err)
+	assert.Less(t, info.Size(), int64(64*1024), "The log should have been compacted")
+	item, err = todo.GetItem(2)
+	assert.NoError(t, err, "Compactions of other programs are picked up")
+	assert.Equal(t, "Version 1499", item.Title)
+
+	assert.NoError(t, reopened.Convert(db.FormatJSON))
+	jsonDB, err := db.New(dbFile)
+	assert.NoError(t, err)
+	assert.Equal(t, db.FormatJSON, jsonDB.Format())
+	items, err = jsonDB.GetAllItems()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(items))
+}
+
+func TestLogStorageGitHistory(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	todo, err := db.NewWithOptions(dbFile, db.Options{GitHistory: true})
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Learn Go"}))
+	assert.NoError(t, todo.Convert(db.FormatLog))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Write tests"}))
+	assert.NoError(t, todo.DeleteItem(1))
+
+	revisions, err := todo.Revisions()
+	assert.NoError(t, err)
+	assert.Equal(t, "delete #1 'Learn Go'", revisions[0].Message)
+	assert.Equal(t, "add #2 'Write tests'", revisions[1].Message)
+
+	items, err := todo.ItemsAt("HEAD~1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(items))
+}
+
+func TestLogStorageDeleteIdZero(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	todo, err := db.New(dbFile)
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.Convert(db.FormatLog))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 0, Title: "Item zero"}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Item one"}))
+	assert.NoError(t, todo.DeleteItem(0))
+	_, err = todo.GetItem(0)
+	assert.Error(t, err, "Item 0 is deleted")
+
+	// The deletion is in the file, not only in memory
+	reopened, err := db.New(dbFile)
+	assert.NoError(t, err, "Error opening DB")
+	items, err := reopened.GetAllItems()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(items))
+	assert.Equal(t, 1, items[0].Id)
+	assert.NoError(t, reopened.Compact())
+	_, err = reopened.GetItem(0)
+	assert.Error(t, err, "Compacting doesn't bring it back")
+
+	trash, err := reopened.Trash()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(trash))
+	assert.NoError(t, reopened.RestoreFromTrash(0))
+	item, err := reopened.GetItem(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "Item zero", item.Title)
+}
+
+// benchmarkDB creates a DB with n items in the given format
+func benchmarkDB(b *testing.B, format string, n int) string {
+	dbFile := filepath.Join(b.TempDir(), "todo.json")
+	todo, err := db.New(dbFile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	items := make([]db.ToDoItem, n)
+	for i := range items {
+		items[i] = db.ToDoItem{Id: i + 1, Title: fmt.Sprint("Item ", i+1), Tags: []string{"bench"}, Priority: i % 4}
+	}
+	if err := todo.ReplaceAllItems(items); err != nil {
+		b.Fatal(err)
+	}
+	if err := todo.Convert(format); err != nil {
+		b.Fatal(err)
+	}
+	return dbFile
+}
+
+// BenchmarkAddItem adds items to a DB that already holds 10000 items,
+// opening the DB for every item like the CLI does
+func BenchmarkAddItem(b *testing.B) {
+	for _, format := range []string{db.FormatJSON, db.FormatLog} {
+		b.Run(format, func(b *testing.B) {
+			dbFile := benchmarkDB(b, format, 10000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				todo, err := db.New(dbFile)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := todo.AddItem(db.ToDoItem{Id: 20000 + i, Title: "New item"}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkUpdateItem changes an item of a DB with 10000 items
+func BenchmarkUpdateItem(b *testing.B) {
+	for _, format := range []string{db.FormatJSON, db.FormatLog} {
+		b.Run(format, func(b *testing.B) {
+			dbFile := benchmarkDB(b, format, 10000)
+			todo, err := db.New(dbFile)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := todo.UpdateItem(db.ToDoItem{Id: 1 + i%10000, Title: fmt.Sprint("Changed ", i)}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkOpenAndGet opens a DB with 10000 items and reads one of them,
+// the startup cost of every CLI command
+func BenchmarkOpenAndGet(b *testing.B) {
+	for _, format := range []string{db.FormatJSON, db.FormatLog} {
+		b.Run(format, func(b *testing.B) {
+			dbFile := benchmarkDB(b, format, 10000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				todo, err := db.New(dbFile)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := todo.GetItem(5000); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGetAllItems reads every item of a DB with 10000 items
+func BenchmarkGetAllItems(b *testing.B) {
+	for _, format := range []string{db.FormatJSON, db.FormatLog} {
+		b.Run(format, func(b *testing.B) {
+			dbFile := benchmarkDB(b, format, 10000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				todo, err := db.New(dbFile)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := todo.GetAllItems(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
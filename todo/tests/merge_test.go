@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func mergeBase() []db.ToDoItem {
+	return []db.ToDoItem{
+		{Id: 1, Title: "Learn Go / GoLang", IsDone: false},
+		{Id: 2, Title: "Learn Kubernetes", IsDone: false},
+		{Id: 3, Title: "Learn Cloud Native Architecture", IsDone: false},
+	}
+}
+
+func TestMergeNonConflictingChanges(t *testing.T) {
+	base := mergeBase()
+
+	// Ours renames item 1, deletes item 3 and adds item 4
+	ours := mergeBase()
+	ours[0].Title = "Learn Go"
+	ours = ours[:2]
+	ours = append(ours, db.ToDoItem{Id: 4, Title: "Ours new item"})
+
+	// Theirs marks item 1 done, and adds item 5
+	theirs := mergeBase()
+	theirs[0].IsDone = true
+	theirs = append(theirs, db.ToDoItem{Id: 5, Title: "Theirs new item"})
+
+	result, err := db.Merge(base, ours, theirs)
+	assert.NoError(t, err, "Error merging")
+	assert.Empty(t, result.Conflicts)
+
+	expected := []db.ToDoItem{
+		{Id: 1, Title: "Learn Go", IsDone: true},
+		{Id: 2, Title: "Learn Kubernetes", IsDone: false},
+		{Id: 4, Title: "Ours new item"},
+		{Id: 5, Title: "Theirs new item"},
+	}
+	assert.Equal(t, expected, result.Items)
+}
+
+func TestMergeFieldConflict(t *testing.T) {
+	base := mergeBase()
+	ours := mergeBase()
+	ours[1].Title = "Learn K8s"
+	theirs := mergeBase()
+	theirs[1].Title = "Learn Kubernetes and Helm"
+	theirs[1].IsDone = true
+
+	result, err := db.Merge(base, ours, theirs)
+	assert.NoError(t, err, "Error merging")
+	assert.Equal(t, 1, len(result.Conflicts))
+	assert.Equal(t, 2, result.Conflicts[0].Id)
+	assert.Equal(t, []string{"title"}, result.Conflicts[0].Fields)
+
+	// The non conflicting done change is applied, the title keeps ours
+	assert.Equal(t, db.ToDoItem{Id: 2, Title: "Learn K8s", IsDone: true}, result.Items[1])
+
+	assert.NoError(t, result.ResolveFields(2, map[string]bool{"title": true}))
+	assert.Empty(t, result.Conflicts)
+	assert.Equal(t, db.ToDoItem{Id: 2, Title: "Learn Kubernetes and Helm", IsDone: true}, result.Items[1])
+}
+
+func TestMergeDeleteModifyConflict(t *testing.T) {
+	base := mergeBase()
+	ours := mergeBase()[1:] // item 1 deleted
+	theirs := mergeBase()
+	theirs[0].IsDone = true
+
+	result, err := db.Merge(base, ours, theirs)
+	assert.NoError(t, err, "Error merging")
+	assert.Equal(t, 1, len(result.Conflicts))
+	assert.Empty(t, result.Conflicts[0].Fields)
+	assert.Nil(t, result.Conflicts[0].Ours)
+
+	assert.NoError(t, result.ResolveDeletion(1, true))
+	assert.Empty(t, result.Conflicts)
+	assert.Equal(t, 3, len(result.Items))
+	assert.True(t, result.Items[0].IsDone)
+}
+
+func TestMergeConflictMarkers(t *testing.T) {
+	base := mergeBase()
+	ours := mergeBase()
+	ours[2].Title = "Ours title"
+	theirs := mergeBase()
+	theirs[2].Title = "Theirs title"
+
+	result, err := db.Merge(base, ours, theirs)
+	assert.NoError(t, err, "Error merging")
+
+	var buf bytes.Buffer
+	assert.NoError(t, result.WriteConflictMarkers(&buf, "ours", "theirs"))
+	out := buf.String()
+
+	assert.True(t, strings.Contains(out, "<<<<<<< ours"))
+	assert.True(t, strings.Contains(out, "======="))
+	assert.True(t, strings.Contains(out, ">>>>>>> theirs"))
+	assert.True(t, strings.Index(out, "Ours title") < strings.Index(out, "Theirs title"))
+	assert.True(t, strings.Contains(out, "Learn Kubernetes"))
+}
+
+func TestMergeConflictMarkersOneSideDeleted(t *testing.T) {
+	// pick keeps one side of every conflict block, like someone resolving
+	// the conflicts by hand
+	pick := func(out string, ours bool) string {
+		var kept []string
+		side := ""
+		for _, line := range strings.Split(out, "\n") {
+			switch {
+			case strings.HasPrefix(line, "<<<<<<<"):
+				side = "ours"
+			case line == "=======":
+				side = "theirs"
+			case strings.HasPrefix(line, ">>>>>>>"):
+				side = ""
+			case side == "" || (side == "ours") == ours:
+				kept = append(kept, line)
+			}
+		}
+		return strings.Join(kept, "\n")
+	}
+
+	// Ours deletes the items, theirs marks them done
+	for _, deleted := range [][]int{{1}, {2}, {3}, {2, 3}, {1, 2, 3}} {
+		base := mergeBase()
+		var ours []db.ToDoItem
+		theirs := mergeBase()
+		for i, item := range mergeBase() {
+			if containsInt(deleted, item.Id) {
+				theirs[i].IsDone = true
+			} else {
+				ours = append(ours, item)
+			}
+		}
+
+		result, err := db.Merge(base, ours, theirs)
+		assert.NoError(t, err, "Error merging")
+		assert.Equal(t, len(deleted), len(result.Conflicts))
+
+		var buf bytes.Buffer
+		assert.NoError(t, result.WriteConflictMarkers(&buf, "ours", "theirs"))
+		for _, useOurs := range []bool{true, false} {
+			var items []db.ToDoItem
+			resolved := pick(buf.String(), useOurs)
+			assert.NoError(t, json.Unmarshal([]byte(resolved), &items), "Deleted %v, ours %v:\n%s", deleted, useOurs, resolved)
+			if useOurs {
+				assert.Equal(t, 3-len(deleted), len(items))
+			} else {
+				assert.Equal(t, 3, len(items))
+			}
+		}
+	}
+}
+
+func containsInt(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMergeDriverWritesNoSideFiles(t *testing.T) {
+	dir := t.TempDir()
+	base, _ := json.Marshal(mergeBase())
+	oursItems := mergeBase()[1:]
+	theirsItems := append(mergeBase(), db.ToDoItem{Id: 4, Title: "Learn Rust"})
+	ours, _ := json.Marshal(oursItems)
+	theirs, _ := json.Marshal(theirsItems)
+	//The names git gives the files it hands a merge driver
+	files := []string{".merge_file_base", ".merge_file_ours", ".merge_file_theirs"}
+	for i, data := range [][]byte{base, ours, theirs} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, files[i]), data, 0644))
+	}
+
+	_, stderr, err := runTodo(t, dir, []string{"TODO_BACKUP_RETENTION=3"}, "merge", "--driver", files[0], files[1], files[2])
+	assert.NoError(t, err, stderr)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, files, names, "Only the files of git are there")
+
+	merged, err := db.New(filepath.Join(dir, files[1]))
+	assert.NoError(t, err)
+	items, err := merged.GetAllItems()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(items), "Item 1 deleted by us, item 4 added by them")
+}
+
+func TestReplaceAllItems(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "merged.json")
+	merged, err := db.New(dbFile)
+	assert.NoError(t, err, "Error creating DB")
+
+	assert.NoError(t, merged.ReplaceAllItems(mergeBase()))
+
+	items, err := merged.GetAllItems()
+	assert.NoError(t, err, "Error getting all items from DB")
+	assert.Equal(t, 3, len(items))
+
+	// Duplicate ids are rejected
+	dup := append(mergeBase(), db.ToDoItem{Id: 1, Title: "Duplicate"})
+	assert.Error(t, merged.ReplaceAllItems(dup))
+
+	// The changes are recorded like any other and removed items can be
+	// restored from the trash
+	next := mergeBase()[1:]
+	next[0].IsDone = true
+	next = append(next, db.ToDoItem{Id: 4, Title: "Learn Rust"})
+	assert.NoError(t, merged.ReplaceAllItems(next))
+	history, err := merged.History()
+	assert.NoError(t, err)
+	var ops []string
+	for _, entry := range history[3:] {
+		ops = append(ops, fmt.Sprintf("%s %d", entry.Op, entry.Id))
+	}
+	assert.Equal(t, []string{"update 2", "add 4", "delete 1"}, ops)
+	assert.NoError(t, merged.RestoreFromTrash(1))
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/config"
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/plan"
+	"drexel.edu/todo/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEstimate(t *testing.T) {
+	for s, want := range map[string]db.Estimate{
+		"3h":       {Amount: 3, Unit: db.UnitHours},
+		"90m":      {Amount: 1.5, Unit: db.UnitHours},
+		"1h30m":    {Amount: 1.5, Unit: db.UnitHours},
+		"5pt":      {Amount: 5, Unit: db.UnitPoints},
+		"2.5 Pts":  {Amount: 2.5, Unit: db.UnitPoints},
+		"8":        {Amount: 8, Unit: db.UnitPoints},
+		" 3p ":     {Amount: 3, Unit: db.UnitPoints},
+		"1 point":  {Amount: 1, Unit: db.UnitPoints},
+		"13points": {Amount: 13, Unit: db.UnitPoints},
+	} {
+		e, err := db.ParseEstimate(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, e, s)
+	}
+	for _, s := range []string{"", "abc", "0h", "-2h", "0", "3d", "inf", "NaN pt"} {
+		_, err := db.ParseEstimate(s)
+		assert.Error(t, err, s)
+	}
+
+	assert.Equal(t, "1h30m", db.Estimate{Amount: 1.5, Unit: db.UnitHours}.String())
+	assert.Equal(t, "2.5pt", db.Estimate{Amount: 2.5, Unit: db.UnitPoints}.String())
+
+	assert.NoError(t, config.Validate("plan.capacity", "30h"))
+	assert.NoError(t, config.Validate("plan.capacity", ""))
+	assert.Error(t, config.Validate("plan.capacity", "a week"))
+}
+
+func TestEstimatesAndDependencies(t *testing.T) {
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+	for id := 1; id <= 4; id++ {
+		assert.NoError(t, todo.AddItem(db.ToDoItem{Id: id, Title: "Item"}))
+	}
+
+	assert.NoError(t, todo.SetEstimate(1, "90m"))
+	assert.Error(t, todo.SetEstimate(1, "soon"))
+	assert.Error(t, todo.SetEstimate(9, "1h"), "No such item")
+	item, err := todo.GetItem(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "1h30m", item.Estimate, "Estimates are stored normalized")
+	e, ok := item.Effort()
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, e.Amount)
+	assert.NoError(t, todo.SetEstimate(1, ""))
+	item, err = todo.GetItem(1)
+	assert.NoError(t, err)
+	_, ok = item.Effort()
+	assert.False(t, ok)
+
+	assert.NoError(t, todo.AddDependency(2, 1))
+	assert.NoError(t, todo.AddDependency(3, 2))
+	assert.Error(t, todo.AddDependency(3, 2), "Already depends on it")
+	assert.Error(t, todo.AddDependency(1, 3), "Cycle through 2")
+	assert.Error(t, todo.AddDependency(1, 1), "Depends on itself")
+	assert.Error(t, todo.AddDependency(1, 9), "No such item")
+	assert.NoError(t, todo.RemoveDependency(3, 2))
+	assert.Error(t, todo.RemoveDependency(3, 2), "No longer depends on it")
+	assert.NoError(t, todo.AddDependency(1, 3), "No cycle any more")
+
+	_, err = todo.JsonToItem(`{"id":5,"title":"Bad","done":false,"estimate":"lots"}`)
+	assert.Error(t, err)
+	_, err = todo.JsonToItem(`{"id":5,"title":"Bad","done":false,"depends_on":[5]}`)
+	assert.Error(t, err)
+	item, err = todo.JsonToItem(`{"id":5,"title":"Good","done":false,"estimate":"2pt","depends_on":[1]}`)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, item.DependsOn)
+
+	//Merging duplicates moves their dependents to the kept item
+	assert.NoError(t, todo.AddDependency(4, 2))
+	_, err = todo.MergeDuplicates(1, []int{2}, "carol")
+	assert.NoError(t, err)
+	item, err = todo.GetItem(4)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, item.DependsOn)
+	item, err = todo.GetItem(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3}, item.DependsOn, "The dependency of 2 on 1 is dropped")
+}
+
+func TestPropose(t *testing.T) {
+	now := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	soon, later := now.Add(24*time.Hour), now.Add(72*time.Hour)
+	items := []db.ToDoItem{
+		{Id: 1, Title: "Low priority", Estimate: "2h"},
+		{Id: 2, Title: "Due later", Priority: 1, Due: &later, Estimate: "3h"},
+		{Id: 3, Title: "Due soon", Priority: 1, Due: &soon, Estimate: "3h"},
+		{Id: 4, Title: "Needs 5", Priority: 1, Estimate: "1h", DependsOn: []int{5}},
+		{Id: 5, Title: "Prerequisite", Priority: 3, Estimate: "2h"},
+		{Id: 6, Title: "Too big", Priority: 2, Estimate: "20h"},
+		{Id: 7, Title: "Needs the big one", Priority: 1, Estimate: "1h", DependsOn: []int{6}},
+		{Id: 8, Title: "Needs a done item", Priority: 2, Estimate: "1h", DependsOn: []int{9, 42}},
+		{Id: 9, Title: "Done", IsDone: true, Estimate: "4h"},
+		{Id: 10, Title: "Not estimated", Priority: 1},
+		{Id: 11, Title: "Points", Priority: 1, Estimate: "3pt"},
+		{Id: 12, Title: "Snoozed", Priority: 1, Estimate: "1h", DeferUntil: &later},
+		{Id: 13, Title: "Cycle a", Estimate: "1h", DependsOn: []int{14}},
+		{Id: 14, Title: "Cycle b", Estimate: "1h", DependsOn: []int{13}},
+	}
+
+	p := plan.Propose(items, db.Estimate{Amount: 10, Unit: db.UnitHours}, now)
+	var ids []int
+	for _, item := range p.Items {
+		ids = append(ids, item.Id)
+	}
+	assert.Equal(t, []int{3, 2, 8, 5, 4}, ids, "By priority and due date, dependencies first")
+	assert.Equal(t, 10.0, p.Planned)
+	assert.Equal(t, 0.0, p.Left)
+
+	reasons := make(map[int]string)
+	for _, s := range p.Skipped {
+		reasons[s.Item.Id] = s.Reason
+	}
+	assert.Equal(t, map[int]string{
+		1:  "does not fit, 0m left",
+		6:  "does not fit, 4h left",
+		7:  "waits for #6, which is not planned",
+		10: "not estimated",
+		11: "estimated in points",
+		12: "snoozed",
+		13: "its dependencies form a cycle",
+		14: "its dependencies form a cycle",
+	}, reasons)
+	assert.Equal(t, 7, p.Skipped[0].Item.Id, "The most urgent skipped item first")
+
+	p = plan.Propose(items, db.Estimate{Amount: 4, Unit: db.UnitPoints}, now)
+	assert.Equal(t, 1, len(p.Items))
+	assert.Equal(t, 11, p.Items[0].Id)
+	assert.Equal(t, 1.0, p.Left)
+}
+
+func TestBurndown(t *testing.T) {
+	day := func(d int, hour int) *time.Time {
+		t := time.Date(2026, 6, d, hour, 0, 0, 0, time.Local)
+		return &t
+	}
+	items := []db.ToDoItem{
+		{Id: 1, Title: "Open from the start", Estimate: "4h", CreatedAt: day(1, 9)},
+		{Id: 2, Title: "Done on the 3rd", Estimate: "2h", CreatedAt: day(1, 9), IsDone: true, DoneAt: day(3, 15)},
+		{Id: 3, Title: "Added on the 4th", Estimate: "3h", CreatedAt: day(4, 10)},
+		{Id: 4, Title: "Done before", Estimate: "8h", CreatedAt: day(1, 9), IsDone: true, DoneAt: day(1, 17)},
+		{Id: 5, Title: "In points", Estimate: "5pt", CreatedAt: day(1, 9)},
+		{Id: 6, Title: "Not estimated", CreatedAt: day(1, 9)},
+	}
+
+	b := stats.ComputeBurndown(items, nil, db.UnitHours, *day(2, 12), *day(5, 12))
+	assert.Equal(t, 6.0, b.Start)
+	assert.Equal(t, []stats.BurndownDay{
+		{Date: "2026-06-02", Scope: 6, Completed: 0, Remaining: 6, Ideal: 4.5},
+		{Date: "2026-06-03", Scope: 6, Completed: 2, Remaining: 4, Ideal: 3},
+		{Date: "2026-06-04", Scope: 9, Completed: 2, Remaining: 7, Ideal: 1.5},
+		{Date: "2026-06-05", Scope: 9, Completed: 2, Remaining: 7, Ideal: 0},
+	}, b.Days)
+
+	b = stats.ComputeBurndown(items, nil, db.UnitPoints, *day(2, 12), *day(2, 12))
+	assert.Equal(t, 5.0, b.Start)
+	assert.Equal(t, 1, len(b.Days))
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// rankedIds returns the ids of the items in the order of their ranks
+func rankedIds(t *testing.T, todo *db.ToDo) []int {
+	items, err := todo.GetAllItems()
+	assert.NoError(t, err)
+	db.SortByRank(items)
+	var ids []int
+	for _, item := range items {
+		ids = append(ids, item.Id)
+	}
+	return ids
+}
+
+func TestRank(t *testing.T) {
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, todo.AddItem(db.ToDoItem{Id: i, Title: fmt.Sprint("Item ", i)}))
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, rankedIds(t, todo), "Unranked items are ordered by id")
+
+	assert.NoError(t, todo.MoveItemToTop(4))
+	assert.Equal(t, []int{4, 1, 2, 3, 5}, rankedIds(t, todo))
+	assert.NoError(t, todo.MoveItemBefore(5, 2))
+	assert.Equal(t, []int{4, 1, 5, 2, 3}, rankedIds(t, todo))
+	assert.NoError(t, todo.MoveItemAfter(4, 3))
+	assert.Equal(t, []int{1, 5, 2, 3, 4}, rankedIds(t, todo))
+	assert.NoError(t, todo.MoveItemToBottom(1))
+	assert.Equal(t, []int{5, 2, 3, 4, 1}, rankedIds(t, todo))
+
+	// Moving an item only changes its own rank
+	before, err := todo.GetAllItems()
+	assert.NoError(t, err)
+	assert.NoError(t, todo.MoveItemBefore(1, 2))
+	after, err := todo.GetAllItems()
+	assert.NoError(t, err)
+	ranks := make(map[int]string)
+	for _, item := range before {
+		ranks[item.Id] = item.Rank
+	}
+	for _, item := range after {
+		if item.Id != 1 {
+			assert.Equal(t, ranks[item.Id], item.Rank, "Item %d was renumbered", item.Id)
+		}
+	}
+	assert.Equal(t, []int{5, 1, 2, 3, 4}, rankedIds(t, todo))
+
+	// New items are added at the bottom
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 6, Title: "Item 6"}))
+	assert.Equal(t, []int{5, 1, 2, 3, 4, 6}, rankedIds(t, todo))
+
+	assert.Error(t, todo.MoveItemBefore(1, 1))
+	assert.Error(t, todo.MoveItemBefore(1, 99))
+	assert.Error(t, todo.MoveItemToTop(99))
+
+	// 0 is an id like any other, not "no item"
+	order := rankedIds(t, todo)
+	assert.Error(t, todo.MoveItemBefore(1, 0), "There is no item 0")
+	assert.Error(t, todo.MoveItemAfter(1, 0), "There is no item 0")
+	assert.Equal(t, order, rankedIds(t, todo), "Nothing moved")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 0, Title: "Item 0"}))
+	assert.NoError(t, todo.MoveItemAfter(1, 0))
+	assert.Equal(t, []int{5, 2, 3, 4, 0, 1, 6}, rankedIds(t, todo))
+	assert.NoError(t, todo.MoveItemBefore(0, 5))
+	assert.Equal(t, []int{0, 5, 2, 3, 4, 1, 6}, rankedIds(t, todo))
+
+	_, err = todo.JsonToItem(`{"id":7,"title":"Bad rank","done":false,"rank":"A0"}`)
+	assert.Error(t, err)
+	_, err = todo.JsonToItem(`{"id":7,"title":"Good rank","done":false,"rank":"h5"}`)
+	assert.NoError(t, err)
+}
+
+func TestRankManyMoves(t *testing.T) {
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+	var want []int
+	for i := 1; i <= 10; i++ {
+		assert.NoError(t, todo.AddItem(db.ToDoItem{Id: i, Title: fmt.Sprint("Item ", i)}))
+		want = append(want, i)
+	}
+
+	// Keep moving items to the same places, which makes the ranks longer,
+	// and compare with the expected order
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 200; n++ {
+		id := want[r.Intn(len(want))]
+		without := remove(want, id)
+		switch n % 4 {
+		case 0:
+			assert.NoError(t, todo.MoveItemToTop(id))
+			want = append([]int{id}, without...)
+		case 1:
+			assert.NoError(t, todo.MoveItemToBottom(id))
+			want = append(without, id)
+		case 2, 3:
+			at := 1 + r.Intn(len(without)-1)
+			if n%4 == 2 {
+				assert.NoError(t, todo.MoveItemBefore(id, without[at]))
+			} else {
+				assert.NoError(t, todo.MoveItemAfter(id, without[at-1]))
+			}
+			want = append(append(append([]int(nil), without[:at]...), id), without[at:]...)
+		}
+		assert.Equal(t, want, rankedIds(t, todo), "Move %d of item %d", n, id)
+	}
+}
+
+func remove(ids []int, id int) []int {
+	var rest []int
+	for _, i := range ids {
+		if i != id {
+			rest = append(rest, i)
+		}
+	}
+	return rest
+}
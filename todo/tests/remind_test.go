@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/remind"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgenda(t *testing.T) {
+	now := time.Date(2024, 3, 11, 12, 0, 0, 0, time.Local)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	items := []db.ToDoItem{
+		{Id: 1, Title: "Overdue since yesterday", Due: at(-24 * time.Hour)},
+		{Id: 2, Title: "Overdue since this morning", Due: at(-2 * time.Hour)},
+		{Id: 3, Title: "Due tonight", Due: at(8 * time.Hour)},
+		{Id: 4, Title: "Due in 3 days", Due: at(72 * time.Hour)},
+		{Id: 5, Title: "Due in 2 weeks", Due: at(14 * 24 * time.Hour)},
+		{Id: 6, Title: "Done", IsDone: true, Due: at(-time.Hour)},
+		{Id: 7, Title: "No due date"},
+	}
+
+	agenda := remind.NewAgenda(items, now)
+	ids := func(items []db.ToDoItem) []int {
+		var ids []int
+		for _, item := range items {
+			ids = append(ids, item.Id)
+		}
+		return ids
+	}
+	assert.Equal(t, []int{1, 2}, ids(agenda.Overdue))
+	assert.Equal(t, []int{3}, ids(agenda.Today))
+	assert.Equal(t, []int{4}, ids(agenda.ThisWeek))
+}
+
+func TestReminders(t *testing.T) {
+	now := time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	stateFile := filepath.Join(t.TempDir(), "todo.json"+remind.StateSuffix)
+
+	items := []db.ToDoItem{
+		{Id: 1, Title: "Due", Due: at(-time.Minute)},
+		{Id: 2, Title: "Early reminder", Due: at(time.Hour), RemindBefore: "2h"},
+		{Id: 3, Title: "Not yet", Due: at(3 * time.Hour), RemindBefore: "2h"},
+		{Id: 4, Title: "Done", IsDone: true, Due: at(-time.Hour)},
+	}
+
+	state, err := remind.LoadState(stateFile)
+	assert.NoError(t, err, "Error loading the reminder state")
+	pending, err := remind.Pending(items, state, now, 0)
+	assert.NoError(t, err, "Error finding the pending reminders")
+	assert.Equal(t, 2, len(pending))
+	// Oldest first, the early reminder of item 2 was due an hour ago
+	assert.Equal(t, 2, pending[0].Item.Id)
+	assert.Equal(t, remind.KindBefore, pending[0].Kind)
+	assert.Equal(t, 1, pending[1].Item.Id)
+	assert.Equal(t, remind.KindDue, pending[1].Kind)
+
+	// Fired reminders are remembered across runs
+	for _, r := range pending {
+		state.MarkFired(r.Key(), now)
+	}
+	assert.NoError(t, state.Save())
+	state, err = remind.LoadState(stateFile)
+	assert.NoError(t, err, "Error loading the reminder state")
+	pending, err = remind.Pending(items, state, now, 0)
+	assert.NoError(t, err, "Error finding the pending reminders")
+	assert.Equal(t, 0, len(pending))
+
+	// The default offset applies to items without remind_before, and
+	// moving the due date arms the reminder again
+	items[0].Due = at(30 * time.Minute)
+	pending, err = remind.Pending(items, state, now, time.Hour)
+	assert.NoError(t, err, "Error finding the pending reminders")
+	assert.Equal(t, 1, len(pending))
+	assert.Equal(t, 1, pending[0].Item.Id)
+	assert.Equal(t, remind.KindBefore, pending[0].Kind)
+
+	items[0].RemindBefore = "soon"
+	_, err = remind.Pending(items, state, now, 0)
+	assert.Error(t, err, "An invalid remind_before should be reported")
+}
+
+func TestNotify(t *testing.T) {
+	due := time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)
+	r := remind.Reminder{Item: db.ToDoItem{Id: 5, Title: "Pay rent", Due: &due}, Kind: remind.KindDue, At: due}
+
+	var log bytes.Buffer
+	assert.NoError(t, remind.Notify(r, "", &log))
+	assert.Contains(t, log.String(), `REMINDER item 5 "Pay rent" is due now`)
+
+	out := filepath.Join(t.TempDir(), "notified")
+	assert.NoError(t, remind.Notify(r, `echo "$TODO_ID $TODO_REMINDER $TODO_TITLE" > `+out, &log))
+	data, err := os.ReadFile(out)
+	assert.NoError(t, err, "The command did not run")
+	assert.Equal(t, "5 due Pay rent\n", string(data))
+
+	assert.Error(t, remind.Notify(r, "exit 1", &log), "A failing command should be reported")
+}
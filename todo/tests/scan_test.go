@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSourceFile(t *testing.T, path string, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestScanDir(t *testing.T) {
+	root := t.TempDir()
+	writeSourceFile(t, filepath.Join(root, "main.go"), `package main
+
+// TODO: write the main function
+func main() {
+	x := 1 // FIXME(ea673): x should not be hardcoded
+}
+`)
+	writeSourceFile(t, filepath.Join(root, "scripts", "build.sh"), "#!/bin/sh\n# XXX remove this hack\n")
+	writeSourceFile(t, filepath.Join(root, ".git", "hooks", "pre-commit"), "# TODO: hidden dirs are skipped\n")
+	writeSourceFile(t, filepath.Join(root, "notes.txt"), "todo list for later, not a comment\n")
+
+	comments, err := scan.Dir(root)
+	assert.NoError(t, err, "Error scanning directory")
+	assert.Equal(t, 3, len(comments))
+
+	byKind := make(map[string]scan.Comment)
+	for _, c := range comments {
+		byKind[c.Kind] = c
+	}
+	assert.Equal(t, "write the main function", byKind["TODO"].Text)
+	assert.Equal(t, 3, byKind["TODO"].Line)
+	assert.Equal(t, "x should not be hardcoded", byKind["FIXME"].Text)
+	assert.Equal(t, "scripts/build.sh", byKind["XXX"].File)
+	assert.Equal(t, "remove this hack", byKind["XXX"].Text)
+}
+
+func TestScanSync(t *testing.T) {
+	root := t.TempDir()
+	source := filepath.Join(root, "main.go")
+	writeSourceFile(t, source, "// TODO: first\n// TODO: second\n")
+
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+
+	comments, err := scan.Dir(root)
+	assert.NoError(t, err, "Error scanning directory")
+	result, err := scan.Sync(todo, root, comments)
+	assert.NoError(t, err, "Error syncing comments")
+	assert.Equal(t, 2, len(result.Added))
+
+	// Rescanning an unchanged tree does not change anything
+	result, err = scan.Sync(todo, root, comments)
+	assert.NoError(t, err, "Error syncing comments")
+	assert.Empty(t, result.Added)
+	assert.Empty(t, result.Updated)
+	assert.Empty(t, result.Closed)
+
+	// Moving a comment updates its line, removing one closes its item
+	writeSourceFile(t, source, "package main\n\n// TODO: second\n")
+	comments, err = scan.Dir(root)
+	assert.NoError(t, err, "Error scanning directory")
+	result, err = scan.Sync(todo, root, comments)
+	assert.NoError(t, err, "Error syncing comments")
+	assert.Empty(t, result.Added)
+	assert.Equal(t, 1, len(result.Updated))
+	assert.Equal(t, 1, len(result.Closed))
+
+	items, err := todo.GetAllItems()
+	assert.NoError(t, err, "Error getting all items from DB")
+	assert.Equal(t, 2, len(items))
+	for _, item := range items {
+		switch item.Title {
+		case "first":
+			assert.True(t, item.IsDone, "Removed comment should be done")
+		case "second":
+			assert.False(t, item.IsDone, "Existing comment should not be done")
+			assert.Equal(t, 3, item.Source.Line)
+		default:
+			t.Errorf("Unexpected item %v", item)
+		}
+	}
+}
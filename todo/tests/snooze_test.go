@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnooze(t *testing.T) {
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Now"}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Later"}))
+
+	now := time.Now()
+	assert.NoError(t, todo.Snooze(2, now.Add(72*time.Hour)))
+
+	items, err := todo.GetAllItems()
+	assert.NoError(t, err, "Error getting all items from DB")
+	assert.Equal(t, 2, len(items))
+
+	visible := db.VisibleItems(items, now)
+	assert.Equal(t, 1, len(visible))
+	assert.Equal(t, 1, visible[0].Id)
+
+	// Once the date passes the item is visible again by itself
+	assert.Equal(t, 2, len(db.VisibleItems(items, now.Add(73*time.Hour))))
+
+	// Clearing the snooze shows it right away
+	assert.NoError(t, todo.Snooze(2, time.Time{}))
+	item, err := todo.GetItem(2)
+	assert.NoError(t, err, "Error getting item from DB")
+	assert.Nil(t, item.DeferUntil)
+	assert.False(t, item.IsDeferred(now))
+
+	assert.Error(t, todo.Snooze(3, now), "Snoozing a missing item is an error")
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Write history"}))
+	assert.NoError(t, todo.ChangeItemDoneStatus(1, true))
+	assert.NoError(t, todo.DeleteItem(1))
+
+	history, err := todo.History()
+	assert.NoError(t, err, "Error reading the history")
+	assert.Equal(t, 3, len(history))
+
+	assert.Equal(t, db.OpAdd, history[0].Op)
+	assert.Nil(t, history[0].Before)
+	assert.Equal(t, "Write history", history[0].After.Title)
+
+	assert.Equal(t, db.OpUpdate, history[1].Op)
+	assert.False(t, history[1].Before.IsDone)
+	assert.True(t, history[1].After.IsDone)
+
+	assert.Equal(t, db.OpDelete, history[2].Op)
+	assert.Equal(t, 1, history[2].Id)
+	assert.Nil(t, history[2].After)
+}
+
+func TestStats(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	at := func(daysAgo int) *time.Time {
+		t := now.AddDate(0, 0, -daysAgo)
+		return &t
+	}
+
+	items := []db.ToDoItem{
+		{Id: 1, Title: "Overdue", Tags: []string{"work"}, Priority: 1, Due: at(2), CreatedAt: at(5)},
+		{Id: 2, Title: "Not due yet", Tags: []string{"home"}, Due: at(-2), CreatedAt: at(1)},
+		{Id: 3, Title: "Done in 2 days", IsDone: true, Tags: []string{"work"}, Priority: 1, CreatedAt: at(4), DoneAt: at(2)},
+		{Id: 4, Title: "Done, created per history", IsDone: true, Priority: 2, DoneAt: at(0)},
+		{Id: 5, Title: "Done, no timestamps", IsDone: true},
+	}
+	// Item 4 has no CreatedAt, the history knows when it was added
+	history := []db.HistoryEntry{
+		{Time: *at(4), Op: db.OpAdd, Id: 4, After: &db.ToDoItem{Id: 4}},
+	}
+
+	report := stats.Compute(items, history, now, 7)
+
+	assert.Equal(t, 5, report.Total)
+	assert.Equal(t, 2, report.Open)
+	assert.Equal(t, 3, report.Done)
+	assert.InDelta(t, 0.6, report.CompletionRate, 0.001)
+
+	// Items 3 and 4 took 2 and 4 days
+	assert.Equal(t, 2, report.Completed)
+	assert.Equal(t, 3*24*time.Hour, report.AverageTimeToComplete)
+
+	assert.Equal(t, 1, len(report.Overdue))
+	assert.Equal(t, 1, report.Overdue[0].Id)
+
+	assert.Equal(t, []string{"home", "work"}, report.Tags())
+	assert.Equal(t, stats.Counts{Open: 1, Done: 1}, report.ByTag["work"])
+	assert.Equal(t, []int{1, 2, 0}, report.Priorities())
+	assert.Equal(t, stats.Counts{Open: 1, Done: 1}, report.ByPriority[1])
+	assert.Equal(t, stats.Counts{Open: 1, Done: 1}, report.ByPriority[0])
+
+	assert.Equal(t, 7, len(report.Days))
+	assert.Equal(t, "2024-03-10", report.Days[6].Date)
+	assert.Equal(t, 1, report.Days[6].Completed)
+	assert.Equal(t, 1, report.Days[4].Completed)
+	assert.Equal(t, 2, report.Days[2].Created)
+
+	var html bytes.Buffer
+	assert.NoError(t, report.WriteHTML(&html))
+	assert.Contains(t, html.String(), "<svg")
+	assert.Contains(t, html.String(), "Overdue")
+	assert.NotContains(t, html.String(), "<script")
+}
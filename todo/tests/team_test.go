@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"drexel.edu/todo/config"
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssignees(t *testing.T) {
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Fix login"}))
+
+	assert.NoError(t, todo.Assign(1, "alice", "carol"))
+	assert.NoError(t, todo.Assign(1, "bob", "carol"))
+	assert.Error(t, todo.Assign(1, "bob", "carol"), "Already assigned")
+	assert.Error(t, todo.Assign(1, "bob smith", "carol"), "Not a user name")
+	assert.Error(t, todo.Assign(2, "bob", "carol"), "No such item")
+
+	item, err := todo.GetItem(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, item.Assignees, "The first assignee owns the item")
+	assert.True(t, item.IsAssignedTo("bob"))
+	assert.False(t, item.IsAssignedTo("carol"))
+
+	assert.NoError(t, todo.Unassign(1, "alice", "bob"))
+	assert.Error(t, todo.Unassign(1, "alice", "bob"), "Not assigned")
+	assert.NoError(t, todo.Comment(1, "bob", "  Looking into it "))
+	assert.Error(t, todo.Comment(1, "bob", " "), "Empty comment")
+
+	item, err = todo.GetItem(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, item.Assignees)
+	assert.Equal(t, 4, len(item.Activity))
+	kinds := []string{db.ActivityAssign, db.ActivityAssign, db.ActivityUnassign, db.ActivityComment}
+	for i, entry := range item.Activity {
+		assert.Equal(t, kinds[i], entry.Kind)
+		assert.False(t, entry.At.IsZero())
+	}
+	assert.Equal(t, "carol", item.Activity[0].Author)
+	assert.Equal(t, "alice", item.Activity[2].Text)
+	assert.Equal(t, db.Activity{At: item.Activity[3].At, Author: "bob", Kind: db.ActivityComment, Text: "Looking into it"}, item.Activity[3])
+
+	assert.NoError(t, config.Validate("user.name", "alice"))
+	assert.NoError(t, config.Validate("user.name", ""))
+	assert.Error(t, config.Validate("user.name", "alice smith"))
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/templates"
+	"github.com/stretchr/testify/assert"
+)
+
+const releaseTemplate = `description: Release checklist
+vars:
+  version: ""
+  branch: main
+items:
+  - title: Release {{version}}
+    tags: [release, "v{{version}}"]
+    priority: 1
+    due: 7d
+    subtasks:
+      - title: Merge {{ branch }} and tag v{{version}}
+      - title: Publish the release notes
+  - title: Announce {{version}}
+`
+
+func TestTemplates(t *testing.T) {
+	dir := t.TempDir()
+	tplDir := filepath.Join(dir, "templates")
+	assert.NoError(t, os.Mkdir(tplDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(tplDir, "release.yaml"), []byte(releaseTemplate), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tplDir, "README.md"), []byte("not a template"), 0644))
+
+	names, err := templates.List(tplDir)
+	assert.NoError(t, err, "Error listing templates")
+	assert.Equal(t, []string{"release"}, names)
+
+	tpl, err := templates.Load(tplDir, "release")
+	assert.NoError(t, err, "Error loading template")
+	assert.Equal(t, []string{"branch", "version"}, tpl.Placeholders())
+
+	now := time.Now()
+	_, err = tpl.Instantiate(nil, 1, now)
+	assert.Error(t, err, "version has no default")
+	_, err = tpl.Instantiate(map[string]string{"version": "1.2", "typo": "x"}, 1, now)
+	assert.Error(t, err, "Unknown variables are an error")
+
+	todo, err := db.New(filepath.Join(dir, "todo.json"))
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Existing"}))
+	firstId, err := todo.NextId()
+	assert.NoError(t, err, "Error getting the next id")
+
+	items, err := tpl.Instantiate(map[string]string{"version": "1.2"}, firstId, now)
+	assert.NoError(t, err, "Error instantiating template")
+	assert.NoError(t, todo.AddItems(items))
+
+	all, err := todo.GetAllItems()
+	assert.NoError(t, err, "Error getting all items from DB")
+	assert.Equal(t, 5, len(all))
+
+	release, err := todo.GetItem(2)
+	assert.NoError(t, err, "Error getting item from DB")
+	assert.Equal(t, "Release 1.2", release.Title)
+	assert.Equal(t, []string{"release", "v1.2"}, release.Tags)
+	assert.Equal(t, 1, release.Priority)
+	assert.WithinDuration(t, now.Add(7*24*time.Hour), *release.Due, time.Second)
+
+	merge, err := todo.GetItem(3)
+	assert.NoError(t, err, "Error getting item from DB")
+	assert.Equal(t, "Merge main and tag v1.2", merge.Title)
+	assert.Equal(t, 2, merge.ParentId)
+
+	announce, err := todo.GetItem(5)
+	assert.NoError(t, err, "Error getting item from DB")
+	assert.Equal(t, "Announce 1.2", announce.Title)
+	assert.Equal(t, 0, announce.ParentId)
+
+	// A batch with an existing id adds nothing
+	items[0].Id = 1
+	assert.Error(t, todo.AddItems(items[:2]))
+	all, err = todo.GetAllItems()
+	assert.NoError(t, err, "Error getting all items from DB")
+	assert.Equal(t, 5, len(all))
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimer(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	todo, err := db.New(dbFile)
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Write report"}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Read mail"}))
+
+	_, _, err = todo.StopTimer()
+	assert.True(t, errors.Is(err, db.ErrNoTimer))
+
+	assert.NoError(t, todo.StartTimer(1))
+	assert.Error(t, todo.StartTimer(2), "Only one timer can run at a time")
+
+	// The running timer is stored in the DB file, a new process sees it
+	reopened, err := db.New(dbFile)
+	assert.NoError(t, err, "Error opening DB")
+	running, ok, err := reopened.ActiveTimer()
+	assert.NoError(t, err, "Error getting the active timer")
+	assert.True(t, ok)
+	assert.Equal(t, 1, running.Id)
+
+	item, entry, err := reopened.StopTimer()
+	assert.NoError(t, err, "Error stopping the timer")
+	assert.Equal(t, 1, item.Id)
+	assert.Nil(t, item.TimerStart)
+	assert.Equal(t, 1, len(item.TimeEntries))
+	assert.False(t, entry.End.Before(entry.Start))
+
+	assert.NoError(t, todo.LogTime(2, 25*time.Minute, time.Now()))
+	assert.NoError(t, todo.LogTime(2, time.Hour, time.Now()))
+	assert.Error(t, todo.LogTime(2, 0, time.Now()), "Logging no time is an error")
+	item, err = todo.GetItem(2)
+	assert.NoError(t, err, "Error getting item from DB")
+	assert.Equal(t, 85*time.Minute, item.TimeSpent())
+
+	// Completing an item stops its timer
+	assert.NoError(t, todo.StartTimer(2))
+	assert.NoError(t, todo.ChangeItemDoneStatus(2, true))
+	item, err = todo.GetItem(2)
+	assert.NoError(t, err, "Error getting item from DB")
+	assert.Nil(t, item.TimerStart)
+	assert.Equal(t, 3, len(item.TimeEntries))
+}
+
+func TestTimesheet(t *testing.T) {
+	day1 := time.Date(2024, 3, 11, 9, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	entry := func(start time.Time, d time.Duration) db.TimeEntry {
+		return db.TimeEntry{Start: start, End: start.Add(d)}
+	}
+
+	items := []db.ToDoItem{
+		{Id: 1, Title: "Report, final", Tags: []string{"work"}, TimeEntries: []db.TimeEntry{
+			entry(day1, time.Hour),
+			entry(day1.Add(2*time.Hour), 30*time.Minute),
+			entry(day2, 15*time.Minute),
+		}},
+		{Id: 2, Title: "Taxes", TimeEntries: []db.TimeEntry{
+			entry(day2.Add(time.Hour), 45*time.Minute),
+			// Outside of the period
+			entry(day2.AddDate(0, 0, 1), time.Hour),
+		}},
+	}
+
+	ts := stats.NewTimesheet(items, day1.Add(-9*time.Hour), day2.Add(15*time.Hour))
+	assert.Equal(t, 150*time.Minute, ts.Total)
+	assert.Equal(t, 3, len(ts.Rows))
+	assert.Equal(t, stats.TimesheetRow{Date: "2024-03-11", Id: 1, Title: "Report, final", Tags: []string{"work"}, Duration: 90 * time.Minute}, ts.Rows[0])
+	assert.Equal(t, []stats.Total{
+		{Name: "2024-03-11", Duration: 90 * time.Minute},
+		{Name: "2024-03-12", Duration: time.Hour},
+	}, ts.ByDay)
+	assert.Equal(t, []stats.Total{
+		{Name: stats.Untagged, Duration: 45 * time.Minute},
+		{Name: "work", Duration: 105 * time.Minute},
+	}, ts.ByTag)
+
+	var csv bytes.Buffer
+	assert.NoError(t, ts.WriteCSV(&csv))
+	assert.Equal(t, "date,id,title,tags,minutes\n"+
+		"2024-03-11,1,\"Report, final\",work,90\n"+
+		"2024-03-12,1,\"Report, final\",work,15\n"+
+		"2024-03-12,2,Taxes,,45\n", csv.String())
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/tui"
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// runTui runs the interface on a simulated screen, feeding it the keys
+// and finally q to quit
+func runTui(t *testing.T, todo *db.ToDo, keys ...*tcell.EventKey) {
+	screen := tcell.NewSimulationScreen("")
+	assert.NoError(t, screen.Init())
+	screen.SetSize(80, 24)
+
+	done := make(chan error)
+	go func() {
+		done <- tui.New(todo, screen).Run()
+	}()
+	for _, key := range append(keys, tuiRunes("q")...) {
+		screen.PostEventWait(key)
+	}
+	assert.NoError(t, <-done)
+}
+
+func tuiRunes(text string) []*tcell.EventKey {
+	var keys []*tcell.EventKey
+	for _, r := range text {
+		keys = append(keys, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+	return keys
+}
+
+func tuiKey(key tcell.Key) *tcell.EventKey {
+	return tcell.NewEventKey(key, 0, tcell.ModNone)
+}
+
+func TestTui(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	todo, err := db.New(dbFile)
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Buy milk"}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Call mom"}))
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 3, Title: "Buy bread"}))
+
+	var keys []*tcell.EventKey
+	// Toggle item 2
+	keys = append(keys, tuiRunes("j ")...)
+	// Filter on "bread" and rename the only match
+	keys = append(keys, tuiRunes("/bread")...)
+	keys = append(keys, tuiKey(tcell.KeyEnter), tuiRunes("e")[0], tuiKey(tcell.KeyCtrlU))
+	keys = append(keys, tuiRunes("Buy rye bread")...)
+	keys = append(keys, tuiKey(tcell.KeyEnter), tuiKey(tcell.KeyEscape))
+	// Delete item 1, a no at the prompt keeps it
+	keys = append(keys, tuiRunes("gdn")...)
+	keys = append(keys, tuiRunes("dy")...)
+	// Add a new item
+	keys = append(keys, tuiRunes("aWalk the dog")...)
+	keys = append(keys, tuiKey(tcell.KeyEnter))
+	runTui(t, todo, keys...)
+
+	items, err := todo.GetAllItems()
+	assert.NoError(t, err, "Error getting all items from DB")
+	sort.Slice(items, func(i, j int) bool { return items[i].Id < items[j].Id })
+	assert.Equal(t, 3, len(items))
+
+	assert.Equal(t, 2, items[0].Id)
+	assert.True(t, items[0].IsDone)
+	assert.Equal(t, "Buy rye bread", items[1].Title)
+	assert.False(t, items[1].IsDone)
+	assert.Equal(t, 4, items[2].Id)
+	assert.Equal(t, "Walk the dog", items[2].Title)
+	assert.NotNil(t, items[2].CreatedAt)
+}
+
+func TestTuiReload(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	todo, err := db.New(dbFile)
+	assert.NoError(t, err, "Error creating DB")
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "First"}))
+
+	screen := tcell.NewSimulationScreen("")
+	assert.NoError(t, screen.Init())
+	done := make(chan error)
+	go func() {
+		done <- tui.New(todo, screen).Run()
+	}()
+	// Make sure the interface has loaded the DB before it is changed
+	screen.PostEventWait(tuiKey(tcell.KeyHome))
+
+	// Another program adds an item, the interface has to see it before it
+	// handles the next key, so G moves to the new item and space toggles it
+	other, err := db.New(dbFile)
+	assert.NoError(t, err, "Error opening DB")
+	assert.NoError(t, other.AddItem(db.ToDoItem{Id: 2, Title: "Added elsewhere"}))
+	for _, key := range tuiRunes("G q") {
+		screen.PostEventWait(key)
+	}
+	assert.NoError(t, <-done)
+
+	item, err := todo.GetItem(2)
+	assert.NoError(t, err, "Error getting item from DB")
+	assert.True(t, item.IsDone)
+	item, err = todo.GetItem(1)
+	assert.NoError(t, err, "Error getting item from DB")
+	assert.False(t, item.IsDone)
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWorkflow(t *testing.T) {
+	w, err := db.ParseWorkflow("todo, doing, done", "todo>doing, doing>done, *>todo")
+	assert.NoError(t, err, "Error parsing workflow")
+	assert.Equal(t, []string{"todo", "doing", "done"}, w.States)
+	assert.Equal(t, "todo", w.Initial())
+	assert.Equal(t, "done", w.Terminal())
+
+	assert.True(t, w.Allowed("todo", "doing"))
+	assert.True(t, w.Allowed("done", "todo"))
+	assert.False(t, w.Allowed("todo", "done"))
+	assert.False(t, w.Allowed("todo", "todo"))
+	assert.False(t, w.Allowed("todo", "nowhere"))
+
+	// Without transitions every move is allowed
+	w, err = db.ParseWorkflow("a,b,c", "")
+	assert.NoError(t, err, "Error parsing workflow")
+	assert.True(t, w.Allowed("c", "a"))
+
+	_, err = db.ParseWorkflow("only", "")
+	assert.Error(t, err, "A single state is not a workflow")
+	_, err = db.ParseWorkflow("a,b,a", "")
+	assert.Error(t, err, "States must be unique")
+	_, err = db.ParseWorkflow("a,b", "a>c")
+	assert.Error(t, err, "Transitions must use known states")
+}
+
+func TestMoveItem(t *testing.T) {
+	w, err := db.ParseWorkflow("todo,in_progress,review,done", "todo>in_progress,in_progress>review,review>in_progress,review>done")
+	assert.NoError(t, err, "Error parsing workflow")
+	todo, err := db.NewWithOptions(filepath.Join(t.TempDir(), "todo.json"), db.Options{Workflow: w})
+	assert.NoError(t, err, "Error creating DB")
+
+	// Items without a status are in the initial state
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 1, Title: "Review me"}))
+	item, _ := todo.GetItem(1)
+	assert.Equal(t, "todo", w.StatusOf(item))
+
+	assert.Error(t, todo.MoveItem(1, "review"), "todo>review is not allowed")
+	assert.Error(t, todo.MoveItem(1, "shipped"), "Unknown states are rejected")
+	assert.NoError(t, todo.MoveItem(1, "in_progress"))
+	assert.NoError(t, todo.MoveItem(1, "review"))
+
+	item, _ = todo.GetItem(1)
+	assert.Equal(t, "review", item.Status)
+	assert.False(t, item.IsDone)
+
+	// Moving to the terminal state marks the item done
+	assert.NoError(t, todo.MoveItem(1, "done"))
+	item, _ = todo.GetItem(1)
+	assert.True(t, item.IsDone)
+	assert.NotNil(t, item.DoneAt)
+	assert.Equal(t, 3, len(item.Transitions))
+	assert.Equal(t, db.Transition{From: "review", To: "done", At: item.Transitions[2].At}, item.Transitions[2])
+
+	// -s keeps working: it moves between the initial and terminal state
+	// regardless of the transitions
+	assert.NoError(t, todo.ChangeItemDoneStatus(1, false))
+	item, _ = todo.GetItem(1)
+	assert.Equal(t, "todo", item.Status)
+	assert.False(t, item.IsDone)
+	assert.Nil(t, item.DoneAt)
+	assert.NoError(t, todo.ChangeItemDoneStatus(1, true))
+	item, _ = todo.GetItem(1)
+	assert.Equal(t, "done", w.StatusOf(item))
+	assert.Equal(t, 5, len(item.Transitions))
+
+	// Items marked done without a status (old DB files, -u) are done
+	assert.NoError(t, todo.AddItem(db.ToDoItem{Id: 2, Title: "Old", IsDone: true, Status: "review"}))
+	item, _ = todo.GetItem(2)
+	assert.Equal(t, "done", w.StatusOf(item))
+}
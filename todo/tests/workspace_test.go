@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"drexel.edu/todo/workspace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkspaceFindFromSubdirectory(t *testing.T) {
+	root := t.TempDir()
+	loc, err := workspace.Init(root)
+	assert.NoError(t, err, "Error creating workspace")
+	assert.FileExists(t, loc.Path)
+
+	nested := filepath.Join(root, "src", "pkg")
+	assert.NoError(t, os.MkdirAll(nested, 0755))
+
+	found, err := workspace.Find(nested)
+	assert.NoError(t, err, "Error finding workspace")
+	assert.Equal(t, workspace.FromWorkspace, found.Source)
+	assert.Equal(t, loc.Path, found.Path)
+	assert.Equal(t, loc.Root, found.Root)
+
+	// A second init in the same directory fails
+	_, err = workspace.Init(root)
+	assert.Error(t, err, "Workspace should not be initialized twice")
+}
+
+func TestWorkspaceFindMarker(t *testing.T) {
+	root := t.TempDir()
+	marker := filepath.Join(root, workspace.DbFileName)
+	assert.NoError(t, os.WriteFile(marker, []byte("[]"), 0644))
+	nested := filepath.Join(root, "docs")
+	assert.NoError(t, os.Mkdir(nested, 0755))
+
+	found, err := workspace.Find(nested)
+	assert.NoError(t, err, "Error finding workspace")
+	assert.Equal(t, workspace.FromMarker, found.Source)
+	assert.Equal(t, marker, found.Path)
+}
+
+func TestWorkspaceFindGlobal(t *testing.T) {
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+
+	found, err := workspace.Find(t.TempDir())
+	assert.NoError(t, err, "Error finding workspace")
+	assert.Equal(t, workspace.FromGlobal, found.Source)
+	assert.Equal(t, filepath.Join(dataHome, "todo", workspace.DbFileName), found.Path)
+	assert.NoDirExists(t, filepath.Dir(found.Path), "A lookup doesn't create anything")
+}
+
+func TestWorkspaceFindLegacyFromSubdirectory(t *testing.T) {
+	root := t.TempDir()
+	legacy := filepath.Join(root, "data", workspace.DbFileName)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(legacy), 0755))
+	assert.NoError(t, os.WriteFile(legacy, []byte("[]"), 0644))
+	nested := filepath.Join(root, "cmd", "tool")
+	assert.NoError(t, os.MkdirAll(nested, 0755))
+
+	found, err := workspace.Find(nested)
+	assert.NoError(t, err, "Error finding workspace")
+	assert.Equal(t, workspace.FromLegacy, found.Source)
+	assert.Equal(t, legacy, found.Path)
+	assert.Equal(t, root, found.Root)
+}
+
+func TestWorkspaceFindPrefersWorkspaceOverLegacy(t *testing.T) {
+	root := t.TempDir()
+	legacy := filepath.Join(root, "data", workspace.DbFileName)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(legacy), 0755))
+	assert.NoError(t, os.WriteFile(legacy, []byte("[]"), 0644))
+
+	// A marker next to the legacy database wins
+	marker := filepath.Join(root, workspace.DbFileName)
+	assert.NoError(t, os.WriteFile(marker, []byte("[]"), 0644))
+	found, err := workspace.Find(root)
+	assert.NoError(t, err, "Error finding workspace")
+	assert.Equal(t, workspace.FromMarker, found.Source)
+
+	// And so does a workspace
+	assert.NoError(t, os.Remove(marker))
+	loc, err := workspace.Init(root)
+	assert.NoError(t, err, "Error creating workspace")
+	found, err = workspace.Find(root)
+	assert.NoError(t, err, "Error finding workspace")
+	assert.Equal(t, workspace.FromWorkspace, found.Source)
+	assert.Equal(t, loc.Path, found.Path)
+}
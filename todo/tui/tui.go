@@ -0,0 +1,503 @@
+// Package tui implements a full-screen terminal interface for a todo
+// database, for triage sessions where running one command per change is
+// too slow.
+package tui
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+
+	"drexel.edu/todo/db"
+)
+
+// What the keyboard currently controls
+type mode int
+
+const (
+	modeList mode = iota
+	modeFilter
+	modeAdd
+	modeEdit
+	modeConfirmDelete
+)
+
+const helpText = "j/k move  space done  a add  e edit  d delete  / filter  r reload  q quit"
+
+// DefaultPollInterval is how often the DB file is checked for changes made
+// by other programs while the interface is idle
+const DefaultPollInterval = time.Second
+
+var (
+	styleDefault  = tcell.StyleDefault
+	styleHeader   = tcell.StyleDefault.Bold(true)
+	styleDone     = tcell.StyleDefault.Dim(true)
+	styleSelected = tcell.StyleDefault.Reverse(true)
+	styleStatus   = tcell.StyleDefault.Foreground(tcell.ColorYellow)
+)
+
+// App is the state of the terminal interface
+type App struct {
+	// PollInterval is how often the DB file is checked for changes while
+	// no key is pressed, the file is also checked before every key press
+	PollInterval time.Duration
+
+	todo   *db.ToDo
+	screen tcell.Screen
+
+	items   []db.ToDoItem // all items, sorted by id
+	visible []db.ToDoItem // the items matching the filter
+	cursor  int           // index into visible
+	offset  int           // index of the first visible item on screen
+
+	filter string
+	mode   mode
+	input  []rune
+	status string
+
+	//Size and modification time of the DB file when it was last loaded
+	fileSize    int64
+	fileModTime time.Time
+
+	quit bool
+}
+
+// New returns the interface for a DB, drawn on an initialized screen
+func New(todo *db.ToDo, screen tcell.Screen) *App {
+	return &App{
+		PollInterval: DefaultPollInterval,
+		todo:         todo,
+		screen:       screen,
+	}
+}
+
+// Run shows the interface until the user quits, then restores the
+// terminal with screen.Fini
+func (a *App) Run() error {
+	defer a.screen.Fini()
+
+	if err := a.reload(); err != nil {
+		return err
+	}
+
+	//Wake up the event loop regularly to look for changes of the DB file
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(a.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				a.screen.PostEvent(tcell.NewEventInterrupt(nil))
+			}
+		}
+	}()
+
+	for !a.quit {
+		a.draw()
+		switch ev := a.screen.PollEvent().(type) {
+		case nil:
+			return nil
+		case *tcell.EventResize:
+			a.screen.Sync()
+		case *tcell.EventInterrupt:
+			a.reloadIfChanged()
+			//Snoozed items show up again when their time has come
+			a.applyFilter()
+		case *tcell.EventKey:
+			//Never act on a stale list, another program may have
+			//changed the DB since it was drawn
+			a.reloadIfChanged()
+			a.handleKey(ev)
+		}
+	}
+	return nil
+}
+
+// reload reads all items from the DB and reapplies the filter, keeping
+// the cursor on the same item if it still exists
+func (a *App) reload() error {
+	selected := -1
+	if item, ok := a.selected(); ok {
+		selected = item.Id
+	}
+
+	if info, err := os.Stat(a.todo.FileName()); err == nil {
+		a.fileSize, a.fileModTime = info.Size(), info.ModTime()
+	}
+	items, err := a.todo.GetAllItems()
+	if err != nil {
+		return err
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Id < items[j].Id
+	})
+	a.items = items
+	a.applyFilter()
+
+	for i, item := range a.visible {
+		if item.Id == selected {
+			a.cursor = i
+		}
+	}
+	a.clampCursor()
+	return nil
+}
+
+// reloadIfChanged reloads the items if the DB file changed since it was
+// last loaded
+func (a *App) reloadIfChanged() {
+	info, err := os.Stat(a.todo.FileName())
+	if err != nil || (info.Size() == a.fileSize && info.ModTime().Equal(a.fileModTime)) {
+		return
+	}
+	if err := a.reload(); err != nil {
+		a.status = err.Error()
+	}
+}
+
+// applyFilter selects the items whose title or tags contain the filter
+// text, ignoring case.  Snoozed items are left out.
+func (a *App) applyFilter() {
+	filter := strings.ToLower(a.filter)
+	now := time.Now()
+	a.visible = a.visible[:0]
+	for _, item := range a.items {
+		if item.IsDeferred(now) {
+			continue
+		}
+		if filter == "" || strings.Contains(strings.ToLower(item.Title), filter) || hasTag(item, filter) {
+			a.visible = append(a.visible, item)
+		}
+	}
+	a.clampCursor()
+}
+
+func hasTag(item db.ToDoItem, filter string) bool {
+	filter = strings.TrimPrefix(filter, "#")
+	for _, tag := range item.Tags {
+		if strings.Contains(strings.ToLower(tag), filter) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *App) selected() (db.ToDoItem, bool) {
+	if a.cursor < 0 || a.cursor >= len(a.visible) {
+		return db.ToDoItem{}, false
+	}
+	return a.visible[a.cursor], true
+}
+
+func (a *App) clampCursor() {
+	if a.cursor >= len(a.visible) {
+		a.cursor = len(a.visible) - 1
+	}
+	if a.cursor < 0 {
+		a.cursor = 0
+	}
+}
+
+func (a *App) handleKey(ev *tcell.EventKey) {
+	if ev.Key() == tcell.KeyCtrlC {
+		a.quit = true
+		return
+	}
+
+	switch a.mode {
+	case modeList:
+		a.handleListKey(ev)
+	case modeConfirmDelete:
+		a.mode = modeList
+		if ev.Key() == tcell.KeyRune && (ev.Rune() == 'y' || ev.Rune() == 'Y') {
+			a.deleteSelected()
+		} else {
+			a.status = "Not deleted"
+		}
+	default:
+		a.handleInputKey(ev)
+	}
+}
+
+func (a *App) handleListKey(ev *tcell.EventKey) {
+	a.status = ""
+	_, height := a.screen.Size()
+	page := max(height-3, 1)
+
+	switch ev.Key() {
+	case tcell.KeyUp:
+		a.cursor--
+	case tcell.KeyDown:
+		a.cursor++
+	case tcell.KeyPgUp:
+		a.cursor -= page
+	case tcell.KeyPgDn:
+		a.cursor += page
+	case tcell.KeyHome:
+		a.cursor = 0
+	case tcell.KeyEnd:
+		a.cursor = len(a.visible) - 1
+	case tcell.KeyEnter:
+		a.startEdit()
+	case tcell.KeyDelete:
+		a.confirmDelete()
+	case tcell.KeyEscape:
+		if a.filter == "" {
+			a.quit = true
+		}
+		a.filter = ""
+		a.applyFilter()
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'q':
+			a.quit = true
+		case 'k':
+			a.cursor--
+		case 'j':
+			a.cursor++
+		case 'g':
+			a.cursor = 0
+		case 'G':
+			a.cursor = len(a.visible) - 1
+		case ' ', 'x':
+			a.toggleSelected()
+		case 'a':
+			a.mode = modeAdd
+			a.input = nil
+		case 'e':
+			a.startEdit()
+		case 'd':
+			a.confirmDelete()
+		case '/':
+			a.mode = modeFilter
+			a.input = []rune(a.filter)
+		case 'r':
+			if err := a.reload(); err != nil {
+				a.status = err.Error()
+			}
+		}
+	}
+	a.clampCursor()
+}
+
+// handleInputKey edits the input line of the filter, add and edit modes
+func (a *App) handleInputKey(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		if a.mode == modeFilter {
+			a.filter = ""
+			a.applyFilter()
+		}
+		a.mode = modeList
+		return
+	case tcell.KeyEnter:
+		a.submitInput()
+		return
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(a.input) > 0 {
+			a.input = a.input[:len(a.input)-1]
+		}
+	case tcell.KeyCtrlU:
+		a.input = nil
+	case tcell.KeyRune:
+		a.input = append(a.input, ev.Rune())
+	default:
+		return
+	}
+
+	//Filter as you type
+	if a.mode == modeFilter {
+		a.filter = string(a.input)
+		a.applyFilter()
+	}
+}
+
+func (a *App) submitInput() {
+	text := strings.TrimSpace(string(a.input))
+	mode := a.mode
+	a.mode = modeList
+
+	switch mode {
+	case modeFilter:
+		a.filter = text
+		a.applyFilter()
+	case modeAdd:
+		if text == "" {
+			return
+		}
+		id, err := a.todo.NextId()
+		if err != nil {
+			a.status = err.Error()
+			return
+		}
+		now := time.Now().UTC()
+		if err := a.todo.AddItem(db.ToDoItem{Id: id, Title: text, CreatedAt: &now}); err != nil {
+			a.status = err.Error()
+			return
+		}
+		a.afterChange(fmt.Sprintf("Added item %d", id))
+		//Put the cursor on the new item, unless the filter hides it
+		for i, item := range a.visible {
+			if item.Id == id {
+				a.cursor = i
+			}
+		}
+	case modeEdit:
+		item, ok := a.selected()
+		if !ok || text == "" || text == item.Title {
+			return
+		}
+		item.Title = text
+		if err := a.todo.UpdateItem(item); err != nil {
+			a.status = err.Error()
+			return
+		}
+		a.afterChange(fmt.Sprintf("Updated item %d", item.Id))
+	}
+}
+
+func (a *App) startEdit() {
+	item, ok := a.selected()
+	if !ok {
+		return
+	}
+	a.mode = modeEdit
+	a.input = []rune(item.Title)
+}
+
+func (a *App) confirmDelete() {
+	item, ok := a.selected()
+	if !ok {
+		return
+	}
+	a.mode = modeConfirmDelete
+	a.status = fmt.Sprintf("Delete item %d? (y/n)", item.Id)
+}
+
+func (a *App) toggleSelected() {
+	item, ok := a.selected()
+	if !ok {
+		return
+	}
+	if err := a.todo.ChangeItemDoneStatus(item.Id, !item.IsDone); err != nil {
+		a.status = err.Error()
+		return
+	}
+	a.afterChange("")
+}
+
+func (a *App) deleteSelected() {
+	item, ok := a.selected()
+	if !ok {
+		return
+	}
+	if err := a.todo.DeleteItem(item.Id); err != nil {
+		a.status = err.Error()
+		return
+	}
+	a.afterChange(fmt.Sprintf("Moved item %d to the trash", item.Id))
+}
+
+// afterChange reloads the items after the interface changed the DB
+func (a *App) afterChange(status string) {
+	a.status = status
+	if err := a.reload(); err != nil {
+		a.status = err.Error()
+	}
+}
+
+func (a *App) draw() {
+	a.screen.Clear()
+	width, height := a.screen.Size()
+
+	open := 0
+	for _, item := range a.items {
+		if !item.IsDone {
+			open++
+		}
+	}
+	header := fmt.Sprintf("todo  %s  %d items, %d open", a.todo.FileName(), len(a.items), open)
+	if a.filter != "" {
+		header += fmt.Sprintf("  filter %q: %d shown", a.filter, len(a.visible))
+	}
+	drawText(a.screen, 0, 0, width, styleHeader, header)
+
+	//Scroll so the cursor stays on screen
+	rows := max(height-2, 0)
+	if a.cursor < a.offset {
+		a.offset = a.cursor
+	}
+	if rows > 0 && a.cursor >= a.offset+rows {
+		a.offset = a.cursor - rows + 1
+	}
+
+	for row := 0; row < rows && a.offset+row < len(a.visible); row++ {
+		i := a.offset + row
+		item := a.visible[i]
+		style := styleDefault
+		if item.IsDone {
+			style = styleDone
+		}
+		if i == a.cursor {
+			style = styleSelected
+		}
+		line := formatItem(item)
+		drawText(a.screen, 0, row+1, width, style, line+strings.Repeat(" ", max(width-runewidth.StringWidth(line), 0)))
+	}
+
+	footer, style := helpText, styleDefault
+	switch {
+	case a.mode == modeFilter:
+		footer = "/" + string(a.input)
+	case a.mode == modeAdd:
+		footer = "Add: " + string(a.input)
+	case a.mode == modeEdit:
+		footer = "Edit: " + string(a.input)
+	case a.status != "":
+		footer, style = a.status, styleStatus
+	}
+	drawText(a.screen, 0, height-1, width, style, footer)
+	if a.mode == modeFilter || a.mode == modeAdd || a.mode == modeEdit {
+		a.screen.ShowCursor(min(runewidth.StringWidth(footer), width-1), height-1)
+	} else {
+		a.screen.HideCursor()
+	}
+
+	a.screen.Show()
+}
+
+func formatItem(item db.ToDoItem) string {
+	check := "[ ]"
+	if item.IsDone {
+		check = "[x]"
+	}
+	line := fmt.Sprintf("%s %4d  %s", check, item.Id, item.Title)
+	if item.Priority > 0 {
+		line += fmt.Sprintf("  P%d", item.Priority)
+	}
+	for _, tag := range item.Tags {
+		line += "  #" + tag
+	}
+	return line
+}
+
+// drawText draws a single line of text, cut off at width columns
+func drawText(screen tcell.Screen, x, y, width int, style tcell.Style, text string) {
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if x+w > width {
+			return
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+}
@@ -0,0 +1,153 @@
+// Package workspace finds the todo database to use, the same way git finds
+// the repository a command runs in.
+//
+// Starting from the current directory each parent directory is checked
+// for a .todo directory (the database is .todo/todo.json) or a todo.json
+// file, and for compatibility with the original layout of this project a
+// data/todo.json file, which is only used when the directory has neither
+// of the others.  The first match wins.  When nothing is found the
+// global database in $XDG_DATA_HOME/todo (~/.local/share/todo) is used.
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"drexel.edu/todo/config"
+)
+
+const (
+	// DirName is the name of the directory that marks a workspace
+	DirName = ".todo"
+
+	// DbFileName is the name of the database file inside of a workspace
+	DbFileName = "todo.json"
+)
+
+// Source describes how the database of a Location was found
+type Source string
+
+const (
+	FromFlag      Source = "flag"
+	FromConfig    Source = "config"
+	FromWorkspace Source = "workspace"
+	FromMarker    Source = "marker"
+	FromLegacy    Source = "legacy"
+	FromGlobal    Source = "global"
+)
+
+// Location is the outcome of the database discovery
+type Location struct {
+	// Path of the database file
+	Path string
+
+	// Root is the directory the database belongs to: the directory that
+	// contains the .todo directory or todo.json marker.  It is empty for
+	// the global database and databases set with --db or in the config.
+	Root string
+
+	Source Source
+}
+
+// Find looks for the database that belongs to startDir, see the package
+// documentation for the search order.  The global database is returned
+// when no workspace is found, Find doesn't create it or its directory.
+func Find(startDir string) (Location, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return Location{}, err
+	}
+
+	for {
+		// A todo.json marker wins over a .todo directory that has no
+		// database in it, as the .todo directory may only hold the
+		// workspace config file of the marker database.  The legacy
+		// layout comes last, a workspace set up next to it replaces it.
+		inWorkspace := filepath.Join(dir, DirName, DbFileName)
+		marker := filepath.Join(dir, DbFileName)
+		legacy := filepath.Join(dir, "data", DbFileName)
+		switch {
+		case isFile(inWorkspace):
+			return Location{Path: inWorkspace, Root: dir, Source: FromWorkspace}, nil
+		case isFile(marker):
+			return Location{Path: marker, Root: dir, Source: FromMarker}, nil
+		case isDir(filepath.Join(dir, DirName)):
+			return Location{Path: inWorkspace, Root: dir, Source: FromWorkspace}, nil
+		case isFile(legacy):
+			return Location{Path: legacy, Root: dir, Source: FromLegacy}, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	globalDir, err := GlobalDir()
+	if err != nil {
+		return Location{}, err
+	}
+	return Location{Path: filepath.Join(globalDir, DbFileName), Source: FromGlobal}, nil
+}
+
+// Init creates a new workspace in dir by creating the .todo directory and
+// an empty database in it.  It fails if dir already is a workspace.
+func Init(dir string) (Location, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Location{}, err
+	}
+
+	todoDir := filepath.Join(dir, DirName)
+	if isFile(filepath.Join(todoDir, DbFileName)) {
+		return Location{}, fmt.Errorf("%s is already a todo workspace", dir)
+	}
+	if err := os.MkdirAll(todoDir, 0755); err != nil {
+		return Location{}, err
+	}
+
+	loc := Location{
+		Path:   filepath.Join(todoDir, DbFileName),
+		Root:   dir,
+		Source: FromWorkspace,
+	}
+	if err := os.WriteFile(loc.Path, []byte("[]"), 0644); err != nil {
+		return Location{}, err
+	}
+	return loc, nil
+}
+
+// GlobalDir returns the directory of the global database, honoring
+// $XDG_DATA_HOME
+func GlobalDir() (string, error) {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "todo"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.New("cannot find the global todo database: " + err.Error())
+	}
+	return filepath.Join(home, ".local", "share", "todo"), nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// ConfigFile returns the path of the workspace config file, or an empty
+// string if loc does not belong to a workspace
+func (loc Location) ConfigFile() string {
+	if loc.Root == "" {
+		return ""
+	}
+	return filepath.Join(loc.Root, DirName, config.FileName)
+}